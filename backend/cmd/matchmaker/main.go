@@ -0,0 +1,54 @@
+// Command matchmaker runs the matchmaker and the queue and presence sweepers
+// outside the API server. Run the server with MATCHMAKER=off when using it.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/scruffyprodigy/playhub/database"
+	"github.com/scruffyprodigy/playhub/internal/jobs"
+	"github.com/scruffyprodigy/playhub/internal/matchmaker"
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/store/postgres"
+)
+
+func main() {
+	interval, err := matchmaker.IntervalFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sweepInterval, err := matchmaker.SweepIntervalFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	heartbeatTimeout, err := matchmaker.HeartbeatTimeoutFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	m := matchmaker.New(postgres.New(db), notify.NewPostgresPublisher(db))
+	log.Printf("matchmaker polling every %s, sweeping every %s", interval, sweepInterval)
+	go jobs.Every(ctx, "queue sweep", sweepInterval, func(ctx context.Context) error {
+		_, err := m.Sweep(ctx)
+		return err
+	})
+	go jobs.Every(ctx, "presence sweep", sweepInterval, func(ctx context.Context) error {
+		_, err := m.SweepPresence(ctx, heartbeatTimeout)
+		return err
+	})
+	jobs.Every(ctx, "matchmaker", interval, func(ctx context.Context) error {
+		_, err := m.Run(ctx)
+		return err
+	})
+}
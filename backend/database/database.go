@@ -10,62 +10,51 @@ import (
 	_ "github.com/lib/pq"
 )
 
-var DB *sql.DB
-
-// Init initializes the database connection
-func Init() error {
+// Open connects to the database at DATABASE_URL. The caller owns the
+// returned pool and hands it to the repositories that need it.
+func Open() (*sql.DB, error) {
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
-		return fmt.Errorf("DATABASE_URL environment variable is required")
+		return nil, fmt.Errorf("DATABASE_URL environment variable is required")
 	}
 
-	var err error
-	DB, err = sql.Open("postgres", databaseURL)
+	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to open database connection: %w", err)
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
 	// Test the connection
-	if err := DB.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	log.Println("Database connection established successfully")
-	return nil
+	return db, nil
 }
 
-// InitWithMigrations initializes the database connection and runs migrations
-func InitWithMigrations() error {
-	// Initialize database connection
-	if err := Init(); err != nil {
-		return err
+// OpenWithMigrations runs pending migrations and then connects to the
+// database. Migrations use their own connection because the migrate driver
+// closes the pool it was given.
+func OpenWithMigrations() (*sql.DB, error) {
+	migrationDB, err := Open()
+	if err != nil {
+		return nil, err
 	}
 
 	// Run migrations
-	migrator, err := migrate.NewMigrator(DB)
+	migrator, err := migrate.NewMigrator(migrationDB)
 	if err != nil {
-		return fmt.Errorf("failed to create migrator: %w", err)
+		migrationDB.Close()
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
 	}
 	defer migrator.Close()
 
 	// Run all pending migrations
 	if err := migrator.Up(); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
-
 	log.Println("Database migrations completed successfully")
-	return nil
-}
-
-// Close closes the database connection
-func Close() error {
-	if DB != nil {
-		return DB.Close()
-	}
-	return nil
-}
 
-// GetDB returns the database connection
-func GetDB() *sql.DB {
-	return DB
+	return Open()
 }
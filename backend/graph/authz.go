@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/scruffyprodigy/playhub/graph/generated"
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// NewConfig returns the executable schema config for r with all schema
+// directives wired up
+func NewConfig(r *Resolver) generated.Config {
+	return generated.Config{
+		Resolvers: r,
+		Directives: generated.DirectiveRoot{
+			HasRole: hasRole,
+		},
+	}
+}
+
+// hasRole implements the @hasRole directive. Anonymous callers get
+// UNAUTHORIZED, signed-in callers without any of roles get FORBIDDEN. Game
+// principals pass only when the field names a gameScope their key grants.
+func hasRole(ctx context.Context, obj any, next graphql.Resolver, roles []model.Role, gameScope *model.APIKeyScope) (any, error) {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	if p.Kind == auth.PrincipalGame {
+		if gameScope == nil || !p.HasScope(toAuthScope(*gameScope)) {
+			return nil, errForbidden(ctx)
+		}
+		return next(ctx)
+	}
+	if !p.HasAnyRole(toAuthRoles(roles)...) {
+		return nil, errForbidden(ctx)
+	}
+	return next(ctx)
+}
+
+// requireGameManager checks that the caller may manage gameID, owned by
+// ownerID. Support staff and admins act on every game, game servers only on
+// their own.
+func requireGameManager(ctx context.Context, gameID, ownerID string) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthorized(ctx)
+	}
+	if p.Kind == auth.PrincipalGame {
+		if gameID != "" && p.GameID == gameID {
+			return nil
+		}
+		return errForbidden(ctx)
+	}
+	if p.HasAnyRole(auth.RoleSupport, auth.RoleAdmin) || p.CanManageGame(ownerID) {
+		return nil
+	}
+	return errForbidden(ctx)
+}
+
+// requireHistoryReader checks that the caller may read userID's match
+// history of game, or of every game when game is nil: the user themselves,
+// support staff and admins, or whoever manages game
+func requireHistoryReader(ctx context.Context, userID string, game *store.Game) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthorized(ctx)
+	}
+	if (p.Kind == auth.PrincipalUser && p.UserID == userID) || p.HasAnyRole(auth.RoleSupport, auth.RoleAdmin) {
+		return nil
+	}
+	if game != nil {
+		return requireGameManager(ctx, game.ID, game.OwnerID)
+	}
+	return errForbidden(ctx)
+}
+
+// requireGoodManager checks that the caller may grant or revoke goodID:
+// publishers and game servers only for goods of their own games
+func (r *Resolver) requireGoodManager(ctx context.Context, goodID string) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthorized(ctx)
+	}
+	if p.HasAnyRole(auth.RoleSupport, auth.RoleAdmin) {
+		return nil
+	}
+
+	good, err := r.Store.Goods.Get(ctx, goodID)
+	if errors.Is(err, store.ErrNotFound) {
+		return newError(ctx, codeValidationError, "good not found")
+	}
+	if err != nil {
+		log.Printf("requireGoodManager: %v", err)
+		return errors.New("failed to load good")
+	}
+
+	var ownerID string
+	if good.GameID != "" {
+		game, err := r.Store.Games.Get(ctx, good.GameID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("requireGoodManager: %v", err)
+			return errors.New("failed to load game")
+		}
+		if game != nil {
+			ownerID = game.OwnerID
+		}
+	}
+	return requireGameManager(ctx, good.GameID, ownerID)
+}
+
+// requireGameOwner looks up gameID and checks that the caller may manage it
+func (r *Resolver) requireGameOwner(ctx context.Context, gameID string) (*store.Game, error) {
+	game, err := r.Store.Games.Get(ctx, gameID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("requireGameOwner: %v", err)
+		return nil, errors.New("failed to load game")
+	}
+	if err := requireGameManager(ctx, game.ID, game.OwnerID); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func toAuthRole(role model.Role) auth.Role {
+	return auth.Role(strings.ToLower(string(role)))
+}
+
+func toAuthRoles(roles []model.Role) []auth.Role {
+	out := make([]auth.Role, len(roles))
+	for i, r := range roles {
+		out[i] = toAuthRole(r)
+	}
+	return out
+}
+
+func toModelRoles(roles []auth.Role) []model.Role {
+	out := []model.Role{model.RolePlayer}
+	for _, r := range roles {
+		if r != auth.RolePlayer {
+			out = append(out, model.Role(strings.ToUpper(string(r))))
+		}
+	}
+	return out
+}
+
+// toAuthScope maps GOODS_WRITE to goods:write
+func toAuthScope(scope model.APIKeyScope) string {
+	kind, action, _ := strings.Cut(strings.ToLower(string(scope)), "_")
+	return kind + ":" + action
+}
+
+func toModelScope(scope string) model.APIKeyScope {
+	return model.APIKeyScope(strings.ToUpper(strings.Replace(scope, ":", "_", 1)))
+}
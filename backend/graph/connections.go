@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// Bounds of the first argument of connection fields
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// cursorData is the JSON inside an opaque cursor
+type cursorData struct {
+	Time  *time.Time `json:"t,omitempty"`
+	Name  string     `json:"n,omitempty"`
+	Count int        `json:"c,omitempty"`
+	AsOf  *time.Time `json:"a,omitempty"`
+	ID    string     `json:"i"`
+}
+
+// encodeCursor makes c opaque to clients
+func encodeCursor(c store.Cursor) string {
+	data := cursorData{Name: c.Name, Count: c.Count, ID: c.ID}
+	if !c.Time.IsZero() {
+		data.Time = &c.Time
+	}
+	if !c.AsOf.IsZero() {
+		data.AsOf = &c.AsOf
+	}
+	b, _ := json.Marshal(data)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(s string) (store.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return store.Cursor{}, err
+	}
+	var data cursorData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return store.Cursor{}, err
+	}
+	if data.ID == "" {
+		return store.Cursor{}, fmt.Errorf("cursor without id")
+	}
+	c := store.Cursor{Name: data.Name, Count: data.Count, ID: data.ID}
+	if data.Time != nil {
+		c.Time = *data.Time
+	}
+	if data.AsOf != nil {
+		c.AsOf = *data.AsOf
+	}
+	return c, nil
+}
+
+// connectionArgs validates the first and after arguments of a connection
+// field and returns the page to load with the number of rows requested. The
+// page holds one extra row to tell whether another page follows.
+func connectionArgs(ctx context.Context, first *int, after *string) (store.Page, int, error) {
+	n := defaultPageSize
+	if first != nil {
+		n = *first
+	}
+	if n < 1 || n > maxPageSize {
+		return store.Page{}, 0, newError(ctx, codeValidationError, fmt.Sprintf("first must be 1-%d", maxPageSize))
+	}
+
+	page := store.Page{Limit: n + 1}
+	if after != nil && *after != "" {
+		c, err := decodeCursor(*after)
+		if err != nil {
+			return store.Page{}, 0, newError(ctx, codeValidationError, "invalid cursor")
+		}
+		page.After = &c
+	}
+	return page, n, nil
+}
+
+// trimPage drops the extra row loaded by connectionArgs and reports whether
+// there was one
+func trimPage[T any](rows []T, n int) ([]T, bool) {
+	if len(rows) > n {
+		return rows[:n], true
+	}
+	return rows, false
+}
+
+// newPageInfo describes a page whose edges carry cursors. A page loaded after
+// a cursor always has a previous page.
+func newPageInfo(page store.Page, hasNext bool, cursors []string) *model.PageInfo {
+	info := &model.PageInfo{HasNextPage: hasNext, HasPreviousPage: page.After != nil}
+	if len(cursors) > 0 {
+		info.StartCursor = &cursors[0]
+		info.EndCursor = &cursors[len(cursors)-1]
+	}
+	return info
+}
+
+// toGameConnection pages games loaded for page, whose cursors are made by cursor
+func toGameConnection(games []*store.Game, n int, page store.Page, cursor func(*store.Game) store.Cursor) *model.GameConnection {
+	games, hasNext := trimPage(games, n)
+	conn := &model.GameConnection{Edges: make([]*model.GameEdge, len(games))}
+	cursors := make([]string, len(games))
+	for i, g := range games {
+		cursors[i] = encodeCursor(cursor(g))
+		conn.Edges[i] = &model.GameEdge{Cursor: cursors[i], Node: toModelGame(g)}
+	}
+	conn.PageInfo = newPageInfo(page, hasNext, cursors)
+	return conn
+}
+
+func gameCursor(g *store.Game) store.Cursor {
+	return store.Cursor{Time: g.CreatedAt, ID: g.ID}
+}
+
+// searchCursor returns the cursor function of search
+func searchCursor(search store.GameSearch) func(*store.Game) store.Cursor {
+	switch search.Sort {
+	case store.SortName:
+		return func(g *store.Game) store.Cursor { return store.Cursor{Name: g.Name, ID: g.ID} }
+	case store.SortPopular:
+		return func(g *store.Game) store.Cursor {
+			return store.Cursor{Count: g.Popularity, Time: g.CreatedAt, AsOf: search.AsOf, ID: g.ID}
+		}
+	}
+	return gameCursor
+}
+
+func sessionCursor(s *store.Session) store.Cursor {
+	return store.Cursor{Time: s.CreatedAt, ID: s.ID}
+}
+
+func goodCursor(g *store.Good) store.Cursor {
+	return store.Cursor{Name: g.Name, ID: g.ID}
+}
+
+func inventoryCursor(item *store.InventoryItem) store.Cursor {
+	return store.Cursor{Time: item.AcquiredAt, ID: item.Good.ID}
+}
@@ -6,83 +6,697 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/scruffyprodigy/playhub/graph/generated"
 	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/rating"
+	"github.com/scruffyprodigy/playhub/internal/store"
 )
 
 // LoginMagic is the resolver for the loginMagic field.
 func (r *mutationResolver) LoginMagic(ctx context.Context, email string) (bool, error) {
-	// TODO: Implement proper magic link authentication
-	// For now, just return true to simulate successful email send
+	if r.MagicLinks == nil || r.Mailer == nil || r.MailTemplates == nil {
+		return false, errors.New("magic link login is not available")
+	}
+
+	email, err := auth.NormalizeEmail(email)
+	if err != nil {
+		return false, err
+	}
+
+	// Every outcome below that depends on the address itself (rate limited,
+	// unknown, disabled) returns true so accounts cannot be enumerated
+	allowed, err := r.allowLogin(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		return true, nil
+	}
+
+	token, err := r.MagicLinks.Create(ctx, email)
+	if errors.Is(err, auth.ErrAccountDisabled) {
+		return true, nil
+	}
+	if err != nil {
+		log.Printf("loginMagic: %v", err)
+		return false, errors.New("failed to start login")
+	}
+
+	msg, err := r.MailTemplates.Login(email, r.MagicLinks.LinkURL(token), r.MagicLinks.TTL())
+	if err != nil {
+		log.Printf("loginMagic: %v", err)
+		return false, errors.New("failed to start login")
+	}
+	if err := r.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("loginMagic: %v", err)
+		return false, errors.New("failed to send login email")
+	}
+
 	return true, nil
 }
 
 // CompleteMagic is the resolver for the completeMagic field.
 func (r *mutationResolver) CompleteMagic(ctx context.Context, token string) (*model.User, error) {
-	// TODO: Implement proper token validation and user creation/retrieval
-	// For now, return a mock user
-	return &model.User{
-		ID:          uuid.New().String(),
-		Email:       &[]string{"user@example.com"}[0],
-		DisplayName: &[]string{"Authenticated User"}[0],
-		CreatedAt:   time.Now(),
-	}, nil
+	if r.MagicLinks == nil || r.AuthSessions == nil || r.Signer == nil {
+		return nil, errors.New("magic link login is not available")
+	}
+
+	user, err := r.MagicLinks.Complete(ctx, token)
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrAccountDisabled) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("completeMagic: %v", err)
+		return nil, errors.New("failed to complete login")
+	}
+
+	userAgent, ip := auth.ClientInfo(ctx)
+	session, refreshToken, err := r.AuthSessions.Create(ctx, user.ID, userAgent, ip)
+	if err != nil {
+		log.Printf("completeMagic: %v", err)
+		return nil, errors.New("failed to complete login")
+	}
+	roles, err := r.issueSessionCookies(ctx, user, session, refreshToken)
+	if err != nil {
+		log.Printf("completeMagic: %v", err)
+		return nil, errors.New("failed to complete login")
+	}
+
+	return toModelUser(user, roles), nil
+}
+
+// RefreshSession is the resolver for the refreshSession field.
+func (r *mutationResolver) RefreshSession(ctx context.Context) (*model.User, error) {
+	if r.AuthSessions == nil || r.Signer == nil {
+		return nil, errors.New("session refresh is not available")
+	}
+
+	session, user, refreshToken, err := r.AuthSessions.Refresh(ctx, auth.RefreshTokenFromContext(ctx))
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		auth.ClearAuthCookies(ctx)
+		return nil, newError(ctx, codeUnauthorized, err.Error())
+	}
+	if err != nil {
+		log.Printf("refreshSession: %v", err)
+		return nil, errors.New("failed to refresh session")
+	}
+	roles, err := r.issueSessionCookies(ctx, user, session, refreshToken)
+	if err != nil {
+		log.Printf("refreshSession: %v", err)
+		return nil, errors.New("failed to refresh session")
+	}
+
+	return toModelUser(user, roles), nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	if r.AuthSessions == nil {
+		return false, errors.New("sessions are not available")
+	}
+
+	var err error
+	if p, ok := auth.UserFromContext(ctx); ok && p.SessionID != "" {
+		err = r.AuthSessions.Revoke(ctx, p.SessionID)
+	} else if refreshToken := auth.RefreshTokenFromContext(ctx); refreshToken != "" {
+		err = r.AuthSessions.RevokeByRefreshToken(ctx, refreshToken)
+	}
+	if err != nil {
+		log.Printf("logout: %v", err)
+		return false, errors.New("failed to log out")
+	}
+
+	auth.ClearAuthCookies(ctx)
+	return true, nil
+}
+
+// LogoutEverywhere is the resolver for the logoutEverywhere field.
+func (r *mutationResolver) LogoutEverywhere(ctx context.Context) (bool, error) {
+	p, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return false, errUnauthorized(ctx)
+	}
+	if r.AuthSessions == nil {
+		return false, errors.New("sessions are not available")
+	}
+
+	if _, err := r.AuthSessions.RevokeAllForUser(ctx, p.UserID); err != nil {
+		log.Printf("logoutEverywhere: %v", err)
+		return false, errors.New("failed to log out")
+	}
+
+	auth.ClearAuthCookies(ctx)
+	return true, nil
 }
 
 // CreateGame is the resolver for the createGame field.
 func (r *mutationResolver) CreateGame(ctx context.Context, input model.CreateGameInput) (*model.Game, error) {
-	// TODO: Implement proper database insertion and validation
-	// For now, return a mock game
-	return &model.Game{
-		ID:        uuid.New().String(),
-		Name:      input.Name,
-		CreatedAt: time.Now(),
-	}, nil
+	game := &store.Game{MinPlayers: 1, MaxPlayers: 4, Status: store.GameActive}
+	applyGameInput(game, model.UpdateGameInput{
+		Name:                     &input.Name,
+		Description:              input.Description,
+		Version:                  input.Version,
+		MinPlayers:               input.MinPlayers,
+		MaxPlayers:               input.MaxPlayers,
+		EstimatedDurationMinutes: input.EstimatedDurationMinutes,
+		Category:                 input.Category,
+		MaxQueueWaitSeconds:      input.MaxQueueWaitSeconds,
+		JoinURLTemplate:          input.JoinURLTemplate,
+		Backfill:                 input.Backfill,
+	})
+	if err := validateGame(ctx, game); err != nil {
+		return nil, err
+	}
+
+	// The publisher creating the game owns it
+	if p, ok := auth.UserFromContext(ctx); ok {
+		game.OwnerID = p.UserID
+	}
+
+	err := r.Store.Games.Create(ctx, game)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, errDuplicateGameName(ctx)
+	}
+	if err != nil {
+		log.Printf("createGame: %v", err)
+		return nil, errors.New("failed to create game")
+	}
+	return toModelGame(game), nil
+}
+
+// UpdateGame is the resolver for the updateGame field.
+func (r *mutationResolver) UpdateGame(ctx context.Context, id string, input model.UpdateGameInput) (*model.Game, error) {
+	game, err := r.requireGameOwner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyGameInput(game, input)
+	if err := validateGame(ctx, game); err != nil {
+		return nil, err
+	}
+	return r.saveGame(ctx, game, "updateGame")
+}
+
+// SetGameStatus is the resolver for the setGameStatus field.
+func (r *mutationResolver) SetGameStatus(ctx context.Context, id string, status model.GameStatus) (*model.Game, error) {
+	game, err := r.requireGameOwner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	game.Status = toStoreGameStatus(status)
+	return r.saveGame(ctx, game, "setGameStatus")
 }
 
 // JoinGame is the resolver for the joinGame field.
-func (r *mutationResolver) JoinGame(ctx context.Context, gameID string) (*model.JoinResult, error) {
-	// TODO: Implement proper game joining logic
-	// For now, return a mock join result
-	return &model.JoinResult{
-		Queued:    true,
-		SessionID: &[]string{uuid.New().String()}[0],
-		JoinURL:   &[]string{fmt.Sprintf("https://game.example.com/join/%s", gameID)}[0],
-	}, nil
+func (r *mutationResolver) JoinGame(ctx context.Context, gameID string, preferences *model.QueuePreferencesInput, party *bool) (*model.JoinResult, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	game, err := r.loadGame(ctx, gameID, "joinGame")
+	if err != nil {
+		return nil, err
+	}
+
+	// A matched player joining again gets a fresh ticket for their session
+	session, err := r.Store.Sessions.Open(ctx, gameID, principal.UserID)
+	if err == nil {
+		return r.joinSession(ctx, game, session.ID, principal.UserID, "joinGame")
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("joinGame: %v", err)
+		return nil, errors.New("failed to join game")
+	}
+
+	if err := requireJoinable(ctx, game); err != nil {
+		return nil, err
+	}
+	prefs, err := queuePreferences(ctx, preferences)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(r.queueMaxWait(game))
+	var entry *store.QueueEntry
+	if party != nil && *party {
+		entry, err = r.enqueueParty(ctx, game, principal.UserID, prefs, expires)
+	} else if prefs, err = r.withRating(ctx, gameID, principal.UserID, prefs); err == nil {
+		entry, err = r.enqueue(ctx, &store.QueueEntry{GameID: gameID, UserID: principal.UserID, Preferences: prefs, ExpiresAt: &expires})
+	}
+	if err != nil {
+		return nil, err
+	}
+	status, err := r.queueStatus(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	return &model.JoinResult{Queued: true, Queue: status}, nil
 }
 
 // LeaveQueue is the resolver for the leaveQueue field.
 func (r *mutationResolver) LeaveQueue(ctx context.Context, gameID string) (bool, error) {
-	// TODO: Implement proper queue leaving logic
-	// For now, return true to simulate successful leave
-	return true, nil
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return false, errUnauthorized(ctx)
+	}
+	if _, err := r.loadGame(ctx, gameID, "leaveQueue"); err != nil {
+		return false, err
+	}
+
+	left, err := r.Store.Queues.Cancel(ctx, gameID, principal.UserID)
+	if err != nil {
+		log.Printf("leaveQueue: %v", err)
+		return false, errors.New("failed to leave queue")
+	}
+	return left, nil
+}
+
+// SetQueuePriority is the resolver for the setQueuePriority field.
+func (r *mutationResolver) SetQueuePriority(ctx context.Context, gameID string, userID string, priority int) (bool, error) {
+	game, err := r.loadGame(ctx, gameID, "setQueuePriority")
+	if err != nil {
+		return false, err
+	}
+	if err := requireGameManager(ctx, game.ID, game.OwnerID); err != nil {
+		return false, err
+	}
+	if priority < -maxQueuePriority || priority > maxQueuePriority {
+		return false, newError(ctx, codeValidationError, fmt.Sprintf("priority must be between -%d and %d", maxQueuePriority, maxQueuePriority))
+	}
+
+	ok, err := r.Store.Queues.SetPriority(ctx, gameID, userID, priority)
+	if err != nil {
+		log.Printf("setQueuePriority: %v", err)
+		return false, errors.New("failed to set queue priority")
+	}
+	return ok, nil
+}
+
+// StartSession is the resolver for the startSession field.
+func (r *mutationResolver) StartSession(ctx context.Context, id string) (*model.Session, error) {
+	return r.transitionSession(ctx, id, store.SessionActive, "startSession")
+}
+
+// EndSession is the resolver for the endSession field.
+func (r *mutationResolver) EndSession(ctx context.Context, id string, status *model.SessionStatus) (*model.Session, error) {
+	to := store.SessionCompleted
+	if status != nil {
+		to = strings.ToLower(string(*status))
+	}
+	if to != store.SessionCompleted && to != store.SessionCancelled {
+		return nil, newError(ctx, codeValidationError, "status must be COMPLETED or CANCELLED")
+	}
+	return r.transitionSession(ctx, id, to, "endSession")
+}
+
+// ReportSessionResult is the resolver for the reportSessionResult field.
+func (r *mutationResolver) ReportSessionResult(ctx context.Context, id string, result model.SessionResultInput) (*model.Session, error) {
+	session, game, err := r.loadSession(ctx, id, "reportSessionResult")
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameManager(ctx, game.ID, game.OwnerID); err != nil {
+		return nil, err
+	}
+	outcome, err := r.sessionResult(ctx, session.ID, result)
+	if err != nil {
+		return nil, err
+	}
+
+	// The result and the ratings it earns are saved together
+	completed, err := r.Store.Sessions.Complete(ctx, session.ID, outcome, rating.Rater(game.ID, outcome.Placements()))
+	if err != nil {
+		return nil, sessionUpdateError(ctx, err, session, store.SessionCompleted, "reportSessionResult")
+	}
+	return toModelSession(completed, toModelGame(game)), nil
+}
+
+// ReportPresence is the resolver for the reportPresence field.
+func (r *mutationResolver) ReportPresence(ctx context.Context, sessionID string, event model.PresenceEvent, userIds []string) ([]*model.SessionPlayer, error) {
+	session, game, err := r.loadSession(ctx, sessionID, "reportPresence")
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameManager(ctx, game.ID, game.OwnerID); err != nil {
+		return nil, err
+	}
+	if len(userIds) == 0 || len(userIds) > maxPresenceBatch {
+		return nil, newError(ctx, codeValidationError, fmt.Sprintf("userIds must list 1-%d players", maxPresenceBatch))
+	}
+	participants, err := r.loadParticipants(ctx, session.ID, "reportPresence")
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range userIds {
+		if findParticipant(participants, id) == nil {
+			return nil, errNotParticipant(ctx, id)
+		}
+	}
+
+	err = r.Store.Sessions.Presence(ctx, session.ID, strings.ToLower(string(event)), userIds)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeSessionNotFound, "session not found")
+	}
+	if errors.Is(err, store.ErrInvalidTransition) {
+		return nil, newError(ctx, codeValidationError, "the session has ended")
+	}
+	if errors.Is(err, store.ErrConflict) {
+		return nil, errSessionFull(ctx)
+	}
+	if err != nil {
+		log.Printf("reportPresence: %v", err)
+		return nil, errors.New("failed to record presence")
+	}
+
+	if participants, err = r.loadParticipants(ctx, session.ID, "reportPresence"); err != nil {
+		return nil, err
+	}
+	var out []*model.SessionPlayer
+	for _, p := range participants {
+		if slices.Contains(userIds, p.User.ID) {
+			out = append(out, toModelSessionPlayer(p))
+		}
+	}
+	return out, nil
+}
+
+// RejoinSession is the resolver for the rejoinSession field.
+func (r *mutationResolver) RejoinSession(ctx context.Context, sessionID string) (*model.JoinResult, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	session, game, err := r.loadSession(ctx, sessionID, "rejoinSession")
+	if err != nil {
+		return nil, err
+	}
+	participants, err := r.loadParticipants(ctx, session.ID, "rejoinSession")
+	if err != nil {
+		return nil, err
+	}
+	player := findParticipant(participants, principal.UserID)
+	if player == nil {
+		return nil, newError(ctx, codeForbidden, "you are not a player of this session")
+	}
+	if session.Status != store.SessionActive {
+		return nil, newError(ctx, codeValidationError, fmt.Sprintf("a %s session cannot be rejoined", session.Status))
+	}
+	// A backfilled player may have taken the place of one who left
+	if player.LeftAt != nil && session.OpenSlots < 1 {
+		return nil, errSessionFull(ctx)
+	}
+	return r.joinSession(ctx, game, session.ID, principal.UserID, "rejoinSession")
+}
+
+// CreateParty is the resolver for the createParty field.
+func (r *mutationResolver) CreateParty(ctx context.Context) (*model.Party, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+
+	party := &store.Party{LeaderID: principal.UserID}
+	err := r.Store.Parties.Create(ctx, party)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, newError(ctx, codeValidationError, "you are already in a party")
+	}
+	if err != nil {
+		log.Printf("createParty: %v", err)
+		return nil, errors.New("failed to create party")
+	}
+	return toModelParty(party), nil
+}
+
+// InviteToParty is the resolver for the inviteToParty field.
+func (r *mutationResolver) InviteToParty(ctx context.Context, partyID string, userID string) (*model.Party, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	party, err := r.loadParty(ctx, partyID, "inviteToParty")
+	if err != nil {
+		return nil, err
+	}
+	if party.LeaderID != principal.UserID {
+		return nil, errForbidden(ctx)
+	}
+	if len(party.Members) >= maxPartySize {
+		return nil, newError(ctx, codeValidationError, fmt.Sprintf("a party has at most %d members and invitees", maxPartySize))
+	}
+
+	err = r.Store.Parties.Invite(ctx, partyID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeValidationError, "user not found")
+	}
+	if errors.Is(err, store.ErrConflict) {
+		return nil, newError(ctx, codeValidationError, "user is already in or invited to the party")
+	}
+	if err != nil {
+		log.Printf("inviteToParty: %v", err)
+		return nil, errors.New("failed to invite to party")
+	}
+	return r.partyResult(ctx, partyID, "inviteToParty")
+}
+
+// AcceptPartyInvite is the resolver for the acceptPartyInvite field.
+func (r *mutationResolver) AcceptPartyInvite(ctx context.Context, partyID string) (*model.Party, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	if _, err := r.loadParty(ctx, partyID, "acceptPartyInvite"); err != nil {
+		return nil, err
+	}
+
+	err := r.Store.Parties.Accept(ctx, partyID, principal.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeValidationError, "you have no invite to this party")
+	}
+	if errors.Is(err, store.ErrConflict) {
+		return nil, newError(ctx, codeValidationError, "you are already in a party")
+	}
+	if err != nil {
+		log.Printf("acceptPartyInvite: %v", err)
+		return nil, errors.New("failed to accept party invite")
+	}
+	return r.partyResult(ctx, partyID, "acceptPartyInvite")
+}
+
+// LeaveParty is the resolver for the leaveParty field.
+func (r *mutationResolver) LeaveParty(ctx context.Context, partyID string) (bool, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return false, errUnauthorized(ctx)
+	}
+
+	left, err := r.Store.Parties.Leave(ctx, partyID, principal.UserID)
+	if err != nil {
+		log.Printf("leaveParty: %v", err)
+		return false, errors.New("failed to leave party")
+	}
+	return left, nil
+}
+
+// CreateGood is the resolver for the createGood field.
+func (r *mutationResolver) CreateGood(ctx context.Context, input model.CreateGoodInput) (*model.DigitalGood, error) {
+	if _, err := r.requireGameOwner(ctx, input.GameID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > 100 {
+		return nil, newError(ctx, codeValidationError, "name must be 1-100 characters")
+	}
+	code, err := store.NormalizeGoodCode(input.Code)
+	if err != nil {
+		return nil, newError(ctx, codeValidationError, err.Error())
+	}
+	good := &store.Good{GameID: input.GameID, Code: code, Name: name}
+	if input.Description != nil {
+		good.Description = strings.TrimSpace(*input.Description)
+	}
+
+	err = r.Store.Goods.Create(ctx, good)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, newError(ctx, codeValidationError, "a good with this code already exists for the game")
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("createGood: %v", err)
+		return nil, errors.New("failed to create good")
+	}
+
+	return toModelGood(good), nil
 }
 
 // GrantGood is the resolver for the grantGood field.
 func (r *mutationResolver) GrantGood(ctx context.Context, userID string, goodID string, quantity *int) (bool, error) {
-	// TODO: Implement proper entitlement granting logic
-	// For now, return true to simulate successful grant
-
-	// TODO: Validate userID and goodID exist
-	// TODO: Create entitlement record in database
-	// TODO: Use quantity parameter for entitlement amount
+	if err := r.requireGoodManager(ctx, goodID); err != nil {
+		return false, err
+	}
+	n, err := quantityArg(ctx, quantity)
+	if err != nil {
+		return false, err
+	}
 
+	_, err = r.Store.Inventory.Grant(ctx, userID, goodID, n)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, newError(ctx, codeValidationError, "user or good not found")
+	}
+	if err != nil {
+		log.Printf("grantGood: %v", err)
+		return false, errors.New("failed to grant good")
+	}
 	return true, nil
 }
 
 // RevokeGood is the resolver for the revokeGood field.
 func (r *mutationResolver) RevokeGood(ctx context.Context, userID string, goodID string, quantity *int) (bool, error) {
-	// TODO: Implement proper entitlement revocation logic
-	// For now, return true to simulate successful revocation
+	if err := r.requireGoodManager(ctx, goodID); err != nil {
+		return false, err
+	}
+	n, err := quantityArg(ctx, quantity)
+	if err != nil {
+		return false, err
+	}
 
-	// TODO: Validate userID and goodID exist
-	// TODO: Update or remove entitlement record in database
-	// TODO: Use quantity parameter for revocation amount
+	_, err = r.Store.Inventory.Revoke(ctx, userID, goodID, n)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, newError(ctx, codeValidationError, "user does not own this good")
+	}
+	if err != nil {
+		log.Printf("revokeGood: %v", err)
+		return false, errors.New("failed to revoke good")
+	}
+	return true, nil
+}
 
+// GrantRole is the resolver for the grantRole field.
+func (r *mutationResolver) GrantRole(ctx context.Context, userID string, role model.Role) (bool, error) {
+	if err := userIDArg(ctx, userID); err != nil {
+		return false, err
+	}
+	if r.Roles == nil {
+		return false, errors.New("roles are not available")
+	}
+
+	var grantedBy string
+	if p, ok := auth.UserFromContext(ctx); ok {
+		grantedBy = p.UserID
+	}
+	err := r.Roles.Grant(ctx, userID, toAuthRole(role), grantedBy)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return false, newError(ctx, codeUserNotFound, "user not found")
+	}
+	if err != nil {
+		log.Printf("grantRole: %v", err)
+		return false, errors.New("failed to grant role")
+	}
+	return true, nil
+}
+
+// RevokeRole is the resolver for the revokeRole field.
+func (r *mutationResolver) RevokeRole(ctx context.Context, userID string, role model.Role) (bool, error) {
+	if err := userIDArg(ctx, userID); err != nil {
+		return false, err
+	}
+	if r.Roles == nil {
+		return false, errors.New("roles are not available")
+	}
+
+	if err := r.Roles.Revoke(ctx, userID, toAuthRole(role)); err != nil {
+		log.Printf("revokeRole: %v", err)
+		return false, errors.New("failed to revoke role")
+	}
+	return true, nil
+}
+
+// RevokeUserSessions is the resolver for the revokeUserSessions field.
+func (r *mutationResolver) RevokeUserSessions(ctx context.Context, userID string) (int, error) {
+	if err := userIDArg(ctx, userID); err != nil {
+		return 0, err
+	}
+	if r.AuthSessions == nil {
+		return 0, errors.New("sessions are not available")
+	}
+
+	n, err := r.AuthSessions.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		log.Printf("revokeUserSessions: %v", err)
+		return 0, errors.New("failed to revoke sessions")
+	}
+	return n, nil
+}
+
+// CreateAPIKey is the resolver for the createApiKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, gameID string, name string, scopes []model.APIKeyScope) (*model.CreatedAPIKey, error) {
+	if r.GameKeys == nil {
+		return nil, errors.New("API keys are not available")
+	}
+	if _, err := r.requireGameOwner(ctx, gameID); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, newError(ctx, codeValidationError, "name must be 1-100 characters")
+	}
+	if len(scopes) == 0 {
+		return nil, newError(ctx, codeValidationError, "at least one scope is required")
+	}
+	authScopes := make([]string, len(scopes))
+	for i, scope := range scopes {
+		authScopes[i] = toAuthScope(scope)
+	}
+
+	var createdBy string
+	if p, ok := auth.UserFromContext(ctx); ok {
+		createdBy = p.UserID
+	}
+	key, raw, err := r.GameKeys.Create(ctx, gameID, name, authScopes, createdBy)
+	if err != nil {
+		log.Printf("createApiKey: %v", err)
+		return nil, errors.New("failed to create API key")
+	}
+
+	return &model.CreatedAPIKey{APIKey: toModelAPIKey(key), Key: raw}, nil
+}
+
+// RevokeAPIKey is the resolver for the revokeApiKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (bool, error) {
+	if r.GameKeys == nil {
+		return false, errors.New("API keys are not available")
+	}
+
+	key, err := r.GameKeys.Get(ctx, id)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		return false, newError(ctx, codeValidationError, err.Error())
+	}
+	if err != nil {
+		log.Printf("revokeApiKey: %v", err)
+		return false, errors.New("failed to revoke API key")
+	}
+	if _, err := r.requireGameOwner(ctx, key.GameID); err != nil {
+		return false, err
+	}
+
+	if err := r.GameKeys.Revoke(ctx, id); err != nil {
+		log.Printf("revokeApiKey: %v", err)
+		return false, errors.New("failed to revoke API key")
+	}
 	return true, nil
 }
 
@@ -98,132 +712,310 @@ func (r *queryResolver) Healthz(ctx context.Context) (string, error) {
 
 // Me is the resolver for the me field.
 func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
-	// TODO: Implement proper authentication and user retrieval
-	// For now, return a mock user
-	return &model.User{
-		ID:          uuid.New().String(),
-		Email:       &[]string{"user@example.com"}[0],
-		DisplayName: &[]string{"Test User"}[0],
-		CreatedAt:   time.Now(),
-	}, nil
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	user, err := r.Store.Users.Get(ctx, principal.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("me: %v", err)
+		return nil, errors.New("failed to load user")
+	}
+	var roles []auth.Role
+	if r.Roles != nil {
+		if roles, err = r.Roles.ForUser(ctx, user.ID); err != nil {
+			log.Printf("me: %v", err)
+			return nil, errors.New("failed to load user")
+		}
+	}
+
+	return toModelUser(user, roles), nil
 }
 
 // Games is the resolver for the games field.
-func (r *queryResolver) Games(ctx context.Context, limit *int, offset *int) ([]*model.Game, error) {
-	// TODO: Implement proper database query with pagination
-	// For now, return mock games
-	games := []*model.Game{
-		{
-			ID:        uuid.New().String(),
-			Name:      "Sample Game 1",
-			CreatedAt: time.Now().Add(-24 * time.Hour),
-		},
-		{
-			ID:        uuid.New().String(),
-			Name:      "Sample Game 2",
-			CreatedAt: time.Now().Add(-12 * time.Hour),
-		},
+func (r *queryResolver) Games(ctx context.Context, first *int, after *string) (*model.GameConnection, error) {
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply pagination
-	start := 0
-	if offset != nil {
-		start = *offset
+	games, err := r.Store.Games.List(ctx, page)
+	if err != nil {
+		log.Printf("games: %v", err)
+		return nil, errors.New("failed to list games")
 	}
+	return toGameConnection(games, n, page, gameCursor), nil
+}
 
-	end := len(games)
-	if limit != nil && start+*limit < len(games) {
-		end = start + *limit
+// SearchGames is the resolver for the searchGames field.
+func (r *queryResolver) SearchGames(ctx context.Context, filter *model.GameFilter, sort *model.GameSort, first *int, after *string) (*model.GameConnection, error) {
+	search, err := gameSearch(ctx, filter, sort)
+	if err != nil {
+		return nil, err
+	}
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
 	}
 
-	if start >= len(games) {
-		return []*model.Game{}, nil
+	if search.Sort == store.SortPopular {
+		// Later pages count popularity when the first page did
+		search.AsOf = time.Now()
+		if page.After != nil && !page.After.AsOf.IsZero() {
+			search.AsOf = page.After.AsOf
+		}
 	}
 
-	return games[start:end], nil
+	games, err := r.Store.Games.Search(ctx, search, page)
+	if err != nil {
+		log.Printf("searchGames: %v", err)
+		return nil, errors.New("failed to search games")
+	}
+	return toGameConnection(games, n, page, searchCursor(search)), nil
 }
 
 // Game is the resolver for the game field.
 func (r *queryResolver) Game(ctx context.Context, id string) (*model.Game, error) {
-	// TODO: Implement proper database query
-	// For now, return a mock game if ID matches
-	if id == "test-game-id" {
-		return &model.Game{
-			ID:        id,
-			Name:      "Test Game",
-			CreatedAt: time.Now().Add(-1 * time.Hour),
-		}, nil
+	game, err := r.Store.Games.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("game: %v", err)
+		return nil, errors.New("failed to load game")
 	}
-	return nil, fmt.Errorf("game not found")
+	return toModelGame(game), nil
 }
 
 // Session is the resolver for the session field.
 func (r *queryResolver) Session(ctx context.Context, id string) (*model.Session, error) {
-	// TODO: Implement proper database query
-	// For now, return a mock session if ID matches
-	if id == "test-session-id" {
-		return &model.Session{
-			ID:        id,
-			Game:      &model.Game{ID: "test-game-id", Name: "Test Game", CreatedAt: time.Now()},
-			Status:    model.SessionStatusActive,
-			CreatedAt: time.Now().Add(-30 * time.Minute),
-		}, nil
+	session, err := r.Store.Sessions.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("session: %v", err)
+		return nil, errors.New("failed to load session")
+	}
+	game, err := r.loadGame(ctx, session.GameID, "session")
+	if err != nil {
+		return nil, err
+	}
+	return toModelSession(session, toModelGame(game)), nil
+}
+
+// MatchHistory is the resolver for the matchHistory field.
+func (r *queryResolver) MatchHistory(ctx context.Context, userID string, gameID *string, first *int, after *string) (*model.SessionConnection, error) {
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
+	}
+	games := make(map[string]*model.Game)
+	var game *store.Game
+	var id string
+	if gameID != nil {
+		if game, err = r.loadGame(ctx, *gameID, "matchHistory"); err != nil {
+			return nil, err
+		}
+		id = game.ID
+		games[id] = toModelGame(game)
+	}
+	if err := requireHistoryReader(ctx, userID, game); err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.Store.Sessions.History(ctx, userID, id, page)
+	if err != nil {
+		log.Printf("matchHistory: %v", err)
+		return nil, errors.New("failed to load match history")
+	}
+	sessions, hasNext := trimPage(sessions, n)
+
+	conn := &model.SessionConnection{Edges: make([]*model.SessionEdge, len(sessions))}
+	cursors := make([]string, len(sessions))
+	for i, s := range sessions {
+		game, ok := games[s.GameID]
+		if !ok {
+			g, err := r.loadGame(ctx, s.GameID, "matchHistory")
+			if err != nil {
+				return nil, err
+			}
+			game = toModelGame(g)
+			games[s.GameID] = game
+		}
+		cursors[i] = encodeCursor(sessionCursor(s))
+		conn.Edges[i] = &model.SessionEdge{Cursor: cursors[i], Node: toModelSession(s, game)}
+	}
+	conn.PageInfo = newPageInfo(page, hasNext, cursors)
+	return conn, nil
+}
+
+// MyQueueStatus is the resolver for the myQueueStatus field.
+func (r *queryResolver) MyQueueStatus(ctx context.Context, gameID string) (*model.QueueStatus, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+
+	entry, err := r.Store.Queues.Waiting(ctx, gameID, principal.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("myQueueStatus: %v", err)
+		return nil, errors.New("failed to load queue status")
+	}
+	return r.queueStatus(ctx, entry)
+}
+
+// MyParty is the resolver for the myParty field.
+func (r *queryResolver) MyParty(ctx context.Context) (*model.Party, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+
+	party, err := r.Store.Parties.ForUser(ctx, principal.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("myParty: %v", err)
+		return nil, errors.New("failed to load party")
 	}
-	return nil, fmt.Errorf("session not found")
+	return toModelParty(party), nil
+}
+
+// MyPartyInvites is the resolver for the myPartyInvites field.
+func (r *queryResolver) MyPartyInvites(ctx context.Context) ([]*model.Party, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+
+	parties, err := r.Store.Parties.Invites(ctx, principal.UserID)
+	if err != nil {
+		log.Printf("myPartyInvites: %v", err)
+		return nil, errors.New("failed to load party invites")
+	}
+	out := make([]*model.Party, len(parties))
+	for i, p := range parties {
+		out[i] = toModelParty(p)
+	}
+	return out, nil
 }
 
 // Goods is the resolver for the goods field.
-func (r *queryResolver) Goods(ctx context.Context, gameID *string) ([]*model.DigitalGood, error) {
-	// TODO: Implement proper database query
-	// For now, return mock goods
-	goods := []*model.DigitalGood{
-		{
-			ID:          uuid.New().String(),
-			Code:        "SKIN_001",
-			Name:        "Cool Skin",
-			Description: &[]string{"A really cool skin for your character"}[0],
-		},
-		{
-			ID:          uuid.New().String(),
-			Code:        "WEAPON_001",
-			Name:        "Epic Weapon",
-			Description: &[]string{"An epic weapon with special abilities"}[0],
-		},
-	}
-
-	// If gameID is specified, filter goods for that game
+func (r *queryResolver) Goods(ctx context.Context, gameID *string, first *int, after *string) (*model.DigitalGoodConnection, error) {
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
+	}
+	var id string
 	if gameID != nil {
-		// TODO: Implement proper filtering
-		// For now, return all goods
+		id = *gameID
+	}
+
+	goods, err := r.Store.Goods.List(ctx, id, page)
+	if err != nil {
+		log.Printf("goods: %v", err)
+		return nil, errors.New("failed to list goods")
 	}
+	goods, hasNext := trimPage(goods, n)
 
-	return goods, nil
+	conn := &model.DigitalGoodConnection{Edges: make([]*model.DigitalGoodEdge, len(goods))}
+	cursors := make([]string, len(goods))
+	for i, g := range goods {
+		cursors[i] = encodeCursor(goodCursor(g))
+		conn.Edges[i] = &model.DigitalGoodEdge{Cursor: cursors[i], Node: toModelGood(g)}
+	}
+	conn.PageInfo = newPageInfo(page, hasNext, cursors)
+	return conn, nil
 }
 
 // MyInventory is the resolver for the myInventory field.
-func (r *queryResolver) MyInventory(ctx context.Context, gameID *string) ([]*model.Entitlement, error) {
-	// TODO: Implement proper database query and authentication
-	// For now, return mock inventory
-	entitlements := []*model.Entitlement{
-		{
-			Good: &model.DigitalGood{
-				ID:          uuid.New().String(),
-				Code:        "SKIN_001",
-				Name:        "Cool Skin",
-				Description: &[]string{"A really cool skin for your character"}[0],
-			},
-			Quantity:  1,
-			GrantedAt: time.Now().Add(-1 * time.Hour),
-		},
-	}
-
-	// If gameID is specified, filter entitlements for that game
+func (r *queryResolver) MyInventory(ctx context.Context, gameID *string, first *int, after *string) (*model.EntitlementConnection, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
+	}
+	var id string
 	if gameID != nil {
-		// TODO: Implement proper filtering
-		// For now, return all entitlements
+		id = *gameID
 	}
 
-	return entitlements, nil
+	items, err := r.Store.Inventory.List(ctx, principal.UserID, id, page)
+	if err != nil {
+		log.Printf("myInventory: %v", err)
+		return nil, errors.New("failed to load inventory")
+	}
+	items, hasNext := trimPage(items, n)
+
+	conn := &model.EntitlementConnection{Edges: make([]*model.EntitlementEdge, len(items))}
+	cursors := make([]string, len(items))
+	for i, item := range items {
+		cursors[i] = encodeCursor(inventoryCursor(item))
+		conn.Edges[i] = &model.EntitlementEdge{Cursor: cursors[i], Node: toModelEntitlement(item)}
+	}
+	conn.PageInfo = newPageInfo(page, hasNext, cursors)
+	return conn, nil
+}
+
+// APIKeys is the resolver for the apiKeys field.
+func (r *queryResolver) APIKeys(ctx context.Context, gameID string) ([]*model.APIKey, error) {
+	if r.GameKeys == nil {
+		return nil, errors.New("API keys are not available")
+	}
+	if _, err := r.requireGameOwner(ctx, gameID); err != nil {
+		return nil, err
+	}
+
+	keys, err := r.GameKeys.List(ctx, gameID)
+	if err != nil {
+		log.Printf("apiKeys: %v", err)
+		return nil, errors.New("failed to list API keys")
+	}
+
+	result := make([]*model.APIKey, len(keys))
+	for i, k := range keys {
+		result[i] = toModelAPIKey(k)
+	}
+	return result, nil
+}
+
+// QueueExpired is the resolver for the queueExpired field.
+func (r *subscriptionResolver) QueueExpired(ctx context.Context) (<-chan *model.QueueExpiredEvent, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return nil, errUnauthorized(ctx)
+	}
+	if r.Events == nil {
+		return nil, errors.New("subscriptions are not available")
+	}
+
+	events := r.Events.Subscribe(ctx, principal.UserID)
+	out := make(chan *model.QueueExpiredEvent)
+	go func() {
+		defer close(out)
+		for e := range events {
+			if e.Type != notify.QueueExpired {
+				continue
+			}
+			select {
+			case out <- &model.QueueExpiredEvent{GameID: e.GameID, ExpiredAt: e.At}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
 }
 
 // Mutation returns generated.MutationResolver implementation.
@@ -232,5 +1024,9 @@ func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResol
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes returned in the "code" extension of GraphQL errors
+const (
+	codeUnauthorized    = "UNAUTHORIZED"
+	codeForbidden       = "FORBIDDEN"
+	codeGameNotFound    = "GAME_NOT_FOUND"
+	codeGameUnavailable = "GAME_UNAVAILABLE"
+	codePartyNotFound   = "PARTY_NOT_FOUND"
+	codeSessionNotFound = "SESSION_NOT_FOUND"
+	codeUserNotFound    = "USER_NOT_FOUND"
+	codeValidationError = "VALIDATION_ERROR"
+	codeRateLimited     = "RATE_LIMITED"
+)
+
+// newError builds a GraphQL error for the current field carrying code
+func newError(ctx context.Context, code, message string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    message,
+		Extensions: map[string]any{"code": code},
+	}
+}
+
+// errUnauthorized is returned when a field requires a signed-in caller
+func errUnauthorized(ctx context.Context) *gqlerror.Error {
+	return newError(ctx, codeUnauthorized, "authentication required")
+}
+
+// errForbidden is returned when the caller lacks the role or ownership a
+// field requires
+func errForbidden(ctx context.Context) *gqlerror.Error {
+	return newError(ctx, codeForbidden, "insufficient permissions")
+}
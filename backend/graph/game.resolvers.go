@@ -0,0 +1,62 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/scruffyprodigy/playhub/graph/generated"
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// ActiveSessions is the resolver for the activeSessions field.
+func (r *gameResolver) ActiveSessions(ctx context.Context, obj *model.Game, first *int, after *string) (*model.SessionConnection, error) {
+	page, n, err := connectionArgs(ctx, first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.Store.Sessions.ListByGame(ctx, obj.ID, store.SessionActive, page)
+	if err != nil {
+		log.Printf("activeSessions: %v", err)
+		return nil, errors.New("failed to list sessions")
+	}
+	sessions, hasNext := trimPage(sessions, n)
+
+	conn := &model.SessionConnection{Edges: make([]*model.SessionEdge, len(sessions))}
+	cursors := make([]string, len(sessions))
+	for i, s := range sessions {
+		cursors[i] = encodeCursor(sessionCursor(s))
+		conn.Edges[i] = &model.SessionEdge{Cursor: cursors[i], Node: toModelSession(s, obj)}
+	}
+	conn.PageInfo = newPageInfo(page, hasNext, cursors)
+	return conn, nil
+}
+
+// Players is the resolver for the players field.
+func (r *sessionResolver) Players(ctx context.Context, obj *model.Session) ([]*model.SessionPlayer, error) {
+	participants, err := r.loadParticipants(ctx, obj.ID, "players")
+	if err != nil {
+		return nil, err
+	}
+
+	players := make([]*model.SessionPlayer, len(participants))
+	for i, p := range participants {
+		players[i] = toModelSessionPlayer(p)
+	}
+	return players, nil
+}
+
+// Game returns generated.GameResolver implementation.
+func (r *Resolver) Game() generated.GameResolver { return &gameResolver{r} }
+
+// Session returns generated.SessionResolver implementation.
+func (r *Resolver) Session() generated.SessionResolver { return &sessionResolver{r} }
+
+type gameResolver struct{ *Resolver }
+type sessionResolver struct{ *Resolver }
@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// maxQueueWaitSeconds caps the queue time a game may allow
+const maxQueueWaitSeconds = 24 * 60 * 60
+
+// applyGameInput copies the fields set in input onto g, trimming text
+func applyGameInput(g *store.Game, input model.UpdateGameInput) {
+	setText := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	setText(&g.Name, input.Name)
+	setText(&g.Description, input.Description)
+	setText(&g.Version, input.Version)
+	setText(&g.Category, input.Category)
+	setText(&g.JoinURLTemplate, input.JoinURLTemplate)
+	if input.MinPlayers != nil {
+		g.MinPlayers = *input.MinPlayers
+	}
+	if input.MaxPlayers != nil {
+		g.MaxPlayers = *input.MaxPlayers
+	}
+	if input.EstimatedDurationMinutes != nil {
+		g.EstimatedDurationMinutes = *input.EstimatedDurationMinutes
+	}
+	if input.MaxQueueWaitSeconds != nil {
+		g.MaxQueueWaitSeconds = *input.MaxQueueWaitSeconds
+	}
+	if input.Backfill != nil {
+		g.Backfill = *input.Backfill
+	}
+}
+
+// validateGame checks the editable fields of g against the games table
+func validateGame(ctx context.Context, g *store.Game) error {
+	switch {
+	case g.Name == "" || len(g.Name) > 100:
+		return newError(ctx, codeValidationError, "name must be 1-100 characters")
+	case len(g.Version) > 20:
+		return newError(ctx, codeValidationError, "version must be at most 20 characters")
+	case len(g.Category) > 50:
+		return newError(ctx, codeValidationError, "category must be at most 50 characters")
+	case g.MinPlayers < 1:
+		return newError(ctx, codeValidationError, "minPlayers must be at least 1")
+	case g.MaxPlayers < g.MinPlayers:
+		return newError(ctx, codeValidationError, "maxPlayers must be at least minPlayers")
+	case g.EstimatedDurationMinutes < 0:
+		return newError(ctx, codeValidationError, "estimatedDurationMinutes must not be negative")
+	case g.MaxQueueWaitSeconds < 0 || g.MaxQueueWaitSeconds > maxQueueWaitSeconds:
+		return newError(ctx, codeValidationError, fmt.Sprintf("maxQueueWaitSeconds must be 0-%d", maxQueueWaitSeconds))
+	case g.JoinURLTemplate != "" && !validJoinURLTemplate(g.JoinURLTemplate):
+		return newError(ctx, codeValidationError, "joinUrlTemplate must be an http(s) URL of at most 500 characters containing "+store.JoinURLTicket)
+	}
+	return nil
+}
+
+// validJoinURLTemplate reports whether template is an absolute http(s) URL
+// with a ticket placeholder
+func validJoinURLTemplate(template string) bool {
+	if len(template) > 500 || !strings.Contains(template, store.JoinURLTicket) {
+		return false
+	}
+	u, err := url.Parse(template)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// saveGame writes the edited game g and maps store failures to GraphQL errors
+func (r *Resolver) saveGame(ctx context.Context, g *store.Game, op string) (*model.Game, error) {
+	err := r.Store.Games.Update(ctx, g)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if errors.Is(err, store.ErrConflict) {
+		return nil, errDuplicateGameName(ctx)
+	}
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, errors.New("failed to update game")
+	}
+	return toModelGame(g), nil
+}
+
+// loadGame loads gameID, failing with GAME_NOT_FOUND when it does not exist
+func (r *Resolver) loadGame(ctx context.Context, gameID, op string) (*store.Game, error) {
+	game, err := r.Store.Games.Get(ctx, gameID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, errors.New("failed to load game")
+	}
+	return game, nil
+}
+
+// requireJoinable checks that game accepts new players
+func requireJoinable(ctx context.Context, game *store.Game) error {
+	if game.Status != store.GameActive {
+		return newError(ctx, codeGameUnavailable, "game is not accepting players ("+game.Status+")")
+	}
+	return nil
+}
+
+// gameSearch validates the arguments of searchGames
+func gameSearch(ctx context.Context, filter *model.GameFilter, sort *model.GameSort) (store.GameSearch, error) {
+	search := store.GameSearch{Sort: store.SortNewest}
+	if sort != nil {
+		search.Sort = store.GameSort(strings.ToLower(string(*sort)))
+	}
+	if filter == nil {
+		return search, nil
+	}
+
+	if filter.Query != nil {
+		search.Query = strings.TrimSpace(*filter.Query)
+		if len(search.Query) > 100 {
+			return search, newError(ctx, codeValidationError, "query must be at most 100 characters")
+		}
+	}
+	if filter.Category != nil {
+		search.Category = strings.TrimSpace(*filter.Category)
+	}
+	if filter.Status != nil {
+		search.Status = toStoreGameStatus(*filter.Status)
+	}
+	if filter.MinPlayers != nil {
+		if *filter.MinPlayers < 1 {
+			return search, newError(ctx, codeValidationError, "minPlayers must be at least 1")
+		}
+		search.MinPlayers = *filter.MinPlayers
+	}
+	if filter.MaxPlayers != nil {
+		if *filter.MaxPlayers < max(search.MinPlayers, 1) {
+			return search, newError(ctx, codeValidationError, "maxPlayers must be at least minPlayers")
+		}
+		search.MaxPlayers = *filter.MaxPlayers
+	}
+	return search, nil
+}
+
+// errDuplicateGameName is returned when another game already has the name
+func errDuplicateGameName(ctx context.Context) error {
+	return newError(ctx, codeValidationError, "a game with this name already exists")
+}
+
+func toStoreGameStatus(status model.GameStatus) string {
+	return strings.ToLower(string(status))
+}
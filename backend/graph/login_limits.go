@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// allowLogin applies the per-IP and per-email magic link limits and reports
+// whether a link may be sent. An exhausted IP limit fails with RATE_LIMITED.
+// An exhausted email limit silently suppresses the mail, so the response does
+// not reveal anything about the address. Limiter failures are logged and let
+// the request through.
+func (r *Resolver) allowLogin(ctx context.Context, email string) (bool, error) {
+	if r.LoginIPLimiter != nil {
+		if _, ip := auth.ClientInfo(ctx); ip != "" {
+			d, err := r.LoginIPLimiter.Allow(ctx, ip)
+			if err != nil {
+				log.Printf("loginMagic: %v", err)
+			} else if !d.Allowed {
+				return false, errRateLimited(ctx, d)
+			}
+		}
+	}
+
+	if r.LoginEmailLimiter != nil {
+		d, err := r.LoginEmailLimiter.Allow(ctx, email)
+		if err != nil {
+			log.Printf("loginMagic: %v", err)
+		} else if !d.Allowed {
+			log.Printf("loginMagic: email limit reached, suppressing login mail")
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// errRateLimited is returned when the caller exceeded a rate limit
+func errRateLimited(ctx context.Context, d ratelimit.Decision) *gqlerror.Error {
+	err := newError(ctx, codeRateLimited, "too many requests, try again later")
+	err.Extensions["retryAfter"] = int(math.Ceil(d.RetryAfter.Seconds()))
+	return err
+}
@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/ratelimit"
+)
+
+func TestAllowLoginEmailLimitIsSilent(t *testing.T) {
+	r := &Resolver{
+		LoginEmailLimiter: ratelimit.New(ratelimit.NewMemoryStore(), "login-email", 1, time.Hour),
+	}
+	ctx := context.Background()
+
+	if ok, err := r.allowLogin(ctx, "player@example.com"); !ok || err != nil {
+		t.Fatalf("Expected first login to be allowed, got %v (%v)", ok, err)
+	}
+
+	// The second attempt is suppressed without an error so the caller cannot
+	// tell it apart from a sent link
+	if ok, err := r.allowLogin(ctx, "player@example.com"); ok || err != nil {
+		t.Errorf("Expected second login to be silently suppressed, got %v (%v)", ok, err)
+	}
+
+	if ok, _ := r.allowLogin(ctx, "other@example.com"); !ok {
+		t.Error("Expected a different address to be allowed")
+	}
+}
@@ -0,0 +1,214 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// toModelUser converts a stored user holding roles into its GraphQL
+// representation
+func toModelUser(u *store.User, roles []auth.Role) *model.User {
+	email := u.Email
+	displayName := u.DisplayName
+	return &model.User{
+		ID:          u.ID,
+		Email:       &email,
+		DisplayName: &displayName,
+		Roles:       toModelRoles(roles),
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// toModelGame converts a stored game into its GraphQL representation
+func toModelGame(g *store.Game) *model.Game {
+	return &model.Game{
+		ID:                       g.ID,
+		Name:                     g.Name,
+		Description:              optionalString(g.Description),
+		Version:                  optionalString(g.Version),
+		MinPlayers:               g.MinPlayers,
+		MaxPlayers:               g.MaxPlayers,
+		EstimatedDurationMinutes: optionalInt(g.EstimatedDurationMinutes),
+		Category:                 optionalString(g.Category),
+		Status:                   model.GameStatus(strings.ToUpper(g.Status)),
+		MaxQueueWaitSeconds:      optionalInt(g.MaxQueueWaitSeconds),
+		JoinURLTemplate:          optionalString(g.JoinURLTemplate),
+		Backfill:                 g.Backfill,
+		CreatedAt:                g.CreatedAt,
+		UpdatedAt:                g.UpdatedAt,
+	}
+}
+
+// toModelSession converts a stored session of game into its GraphQL
+// representation. Players are resolved separately.
+func toModelSession(s *store.Session, game *model.Game) *model.Session {
+	return &model.Session{
+		ID:        s.ID,
+		Game:      game,
+		Status:    model.SessionStatus(strings.ToUpper(s.Status)),
+		CreatedAt: s.CreatedAt,
+		StartedAt: s.StartedAt,
+		EndedAt:   s.EndedAt,
+		OpenSlots: s.OpenSlots,
+		Result:    toModelSessionResult(s.Result),
+	}
+}
+
+// toModelSessionResult converts a reported session result, if any, into its
+// GraphQL representation
+func toModelSessionResult(r *store.SessionResult) *model.SessionResult {
+	if r == nil {
+		return nil
+	}
+	out := &model.SessionResult{Players: make([]*model.PlayerResult, len(r.Players)), Stats: r.Stats}
+	for i, p := range r.Players {
+		out.Players[i] = &model.PlayerResult{UserID: p.UserID, Placement: p.Placement, Score: p.Score, Stats: p.Stats}
+	}
+	return out
+}
+
+// toModelPlayer converts a user taking part in a session into its GraphQL
+// representation. Other players' email addresses are not exposed.
+func toModelPlayer(u *store.User) *model.User {
+	displayName := u.DisplayName
+	return &model.User{
+		ID:          u.ID,
+		DisplayName: &displayName,
+		Roles:       toModelRoles(nil),
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// toModelSessionPlayer converts a session participant into its GraphQL
+// representation
+func toModelSessionPlayer(p *store.Participant) *model.SessionPlayer {
+	return &model.SessionPlayer{
+		User:       toModelPlayer(p.User),
+		Status:     model.ParticipantStatus(strings.ToUpper(p.Status())),
+		Role:       optionalString(p.Role),
+		JoinedAt:   p.JoinedAt,
+		LastSeenAt: p.LastSeenAt,
+		LeftAt:     p.LeftAt,
+	}
+}
+
+// toModelGood converts a stored good into its GraphQL representation
+func toModelGood(g *store.Good) *model.DigitalGood {
+	good := &model.DigitalGood{
+		ID:   g.ID,
+		Code: g.Code,
+		Name: g.Name,
+	}
+	if g.Description != "" {
+		description := g.Description
+		good.Description = &description
+	}
+	return good
+}
+
+// toModelEntitlement converts an inventory item into its GraphQL representation
+func toModelEntitlement(item *store.InventoryItem) *model.Entitlement {
+	return &model.Entitlement{
+		Good:      toModelGood(item.Good),
+		Quantity:  item.Quantity,
+		GrantedAt: item.AcquiredAt,
+	}
+}
+
+// toModelAPIKey converts a game API key into its GraphQL representation
+func toModelAPIKey(k *store.APIKey) *model.APIKey {
+	scopes := make([]model.APIKeyScope, len(k.Scopes))
+	for i, scope := range k.Scopes {
+		scopes[i] = toModelScope(scope)
+	}
+	return &model.APIKey{
+		ID:         k.ID,
+		GameID:     k.GameID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// quantityArg validates the optional quantity argument of grantGood and
+// revokeGood, which defaults to 1
+func quantityArg(ctx context.Context, quantity *int) (int, error) {
+	if quantity == nil {
+		return 1, nil
+	}
+	if *quantity <= 0 {
+		return 0, newError(ctx, codeValidationError, "quantity must be positive")
+	}
+	return *quantity, nil
+}
+
+// userIDArg validates a user id argument. Ids that are not UUIDs cannot name
+// any user.
+func userIDArg(ctx context.Context, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return newError(ctx, codeValidationError, "invalid user id")
+	}
+	return nil
+}
+
+func toModelQueuePreferences(p store.QueuePreferences) *model.QueuePreferences {
+	return &model.QueuePreferences{
+		Region:      optionalString(p.Region),
+		Language:    optionalString(p.Language),
+		Mode:        optionalString(p.Mode),
+		SkillRating: p.SkillRating,
+	}
+}
+
+func toModelRating(r *store.Rating) *model.Rating {
+	return &model.Rating{
+		GameID:      r.GameID,
+		Rating:      r.Value,
+		Deviation:   r.Deviation,
+		Volatility:  r.Volatility,
+		GamesPlayed: r.GamesPlayed,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// toModelParty converts a stored party into its GraphQL representation.
+// Members are shown as players, without email addresses.
+func toModelParty(p *store.Party) *model.Party {
+	party := &model.Party{ID: p.ID, CreatedAt: p.CreatedAt, Members: make([]*model.PartyMember, len(p.Members))}
+	for i, m := range p.Members {
+		party.Members[i] = &model.PartyMember{
+			User:      toModelPlayer(m.User),
+			Status:    model.PartyMemberStatus(strings.ToUpper(m.Status)),
+			InvitedAt: m.InvitedAt,
+			JoinedAt:  m.JoinedAt,
+		}
+		if m.User.ID == p.LeaderID {
+			party.Leader = party.Members[i].User
+		}
+	}
+	return party
+}
+
+// optionalString maps an unset text column to null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalInt maps an unset integer column to null
+func optionalInt(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
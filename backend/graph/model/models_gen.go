@@ -10,8 +10,40 @@ import (
 	"time"
 )
 
+type APIKey struct {
+	ID         string        `json:"id"`
+	GameID     string        `json:"gameId"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time    `json:"revokedAt,omitempty"`
+}
+
 type CreateGameInput struct {
-	Name string `json:"name"`
+	Name                     string  `json:"name"`
+	Description              *string `json:"description,omitempty"`
+	Version                  *string `json:"version,omitempty"`
+	MinPlayers               *int    `json:"minPlayers,omitempty"`
+	MaxPlayers               *int    `json:"maxPlayers,omitempty"`
+	EstimatedDurationMinutes *int    `json:"estimatedDurationMinutes,omitempty"`
+	Category                 *string `json:"category,omitempty"`
+	MaxQueueWaitSeconds      *int    `json:"maxQueueWaitSeconds,omitempty"`
+	JoinURLTemplate          *string `json:"joinUrlTemplate,omitempty"`
+	Backfill                 *bool   `json:"backfill,omitempty"`
+}
+
+type CreateGoodInput struct {
+	GameID      string  `json:"gameId"`
+	Code        string  `json:"code"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+}
+
+type CreatedAPIKey struct {
+	APIKey *APIKey `json:"apiKey"`
+	Key    string  `json:"key"`
 }
 
 type DigitalGood struct {
@@ -22,63 +54,639 @@ type DigitalGood struct {
 	Game        *Game   `json:"game,omitempty"`
 }
 
+type DigitalGoodConnection struct {
+	Edges    []*DigitalGoodEdge `json:"edges"`
+	PageInfo *PageInfo          `json:"pageInfo"`
+}
+
+type DigitalGoodEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *DigitalGood `json:"node"`
+}
+
 type Entitlement struct {
 	Good      *DigitalGood `json:"good"`
 	Quantity  int          `json:"quantity"`
 	GrantedAt time.Time    `json:"grantedAt"`
 }
 
+type EntitlementConnection struct {
+	Edges    []*EntitlementEdge `json:"edges"`
+	PageInfo *PageInfo          `json:"pageInfo"`
+}
+
+type EntitlementEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *Entitlement `json:"node"`
+}
+
 type Game struct {
-	ID             string     `json:"id"`
-	Name           string     `json:"name"`
-	CreatedAt      time.Time  `json:"createdAt"`
-	ActiveSessions []*Session `json:"activeSessions"`
+	ID                       string             `json:"id"`
+	Name                     string             `json:"name"`
+	Description              *string            `json:"description,omitempty"`
+	Version                  *string            `json:"version,omitempty"`
+	MinPlayers               int                `json:"minPlayers"`
+	MaxPlayers               int                `json:"maxPlayers"`
+	EstimatedDurationMinutes *int               `json:"estimatedDurationMinutes,omitempty"`
+	Category                 *string            `json:"category,omitempty"`
+	Status                   GameStatus         `json:"status"`
+	MaxQueueWaitSeconds      *int               `json:"maxQueueWaitSeconds,omitempty"`
+	JoinURLTemplate          *string            `json:"joinUrlTemplate,omitempty"`
+	Backfill                 bool               `json:"backfill"`
+	CreatedAt                time.Time          `json:"createdAt"`
+	UpdatedAt                time.Time          `json:"updatedAt"`
+	ActiveSessions           *SessionConnection `json:"activeSessions"`
+}
+
+type GameConnection struct {
+	Edges    []*GameEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type GameEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Game  `json:"node"`
+}
+
+type GameFilter struct {
+	Query      *string     `json:"query,omitempty"`
+	Category   *string     `json:"category,omitempty"`
+	Status     *GameStatus `json:"status,omitempty"`
+	MinPlayers *int        `json:"minPlayers,omitempty"`
+	MaxPlayers *int        `json:"maxPlayers,omitempty"`
 }
 
 type JoinResult struct {
-	Queued    bool    `json:"queued"`
-	SessionID *string `json:"sessionId,omitempty"`
-	JoinURL   *string `json:"joinUrl,omitempty"`
+	Queued          bool         `json:"queued"`
+	Queue           *QueueStatus `json:"queue,omitempty"`
+	SessionID       *string      `json:"sessionId,omitempty"`
+	Ticket          *string      `json:"ticket,omitempty"`
+	TicketExpiresAt *time.Time   `json:"ticketExpiresAt,omitempty"`
+	JoinURL         *string      `json:"joinUrl,omitempty"`
 }
 
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type Party struct {
+	ID        string         `json:"id"`
+	Leader    *User          `json:"leader"`
+	Members   []*PartyMember `json:"members"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type PartyMember struct {
+	User      *User             `json:"user"`
+	Status    PartyMemberStatus `json:"status"`
+	InvitedAt time.Time         `json:"invitedAt"`
+	JoinedAt  *time.Time        `json:"joinedAt,omitempty"`
+}
+
+type PlayerResult struct {
+	UserID    string         `json:"userId"`
+	Placement int            `json:"placement"`
+	Score     *float64       `json:"score,omitempty"`
+	Stats     map[string]any `json:"stats,omitempty"`
+}
+
+type PlayerResultInput struct {
+	UserID    string         `json:"userId"`
+	Placement int            `json:"placement"`
+	Score     *float64       `json:"score,omitempty"`
+	Stats     map[string]any `json:"stats,omitempty"`
+}
+
 type Query struct {
 }
 
+type QueueExpiredEvent struct {
+	GameID    string    `json:"gameId"`
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+type QueuePreferences struct {
+	Region      *string `json:"region,omitempty"`
+	Language    *string `json:"language,omitempty"`
+	Mode        *string `json:"mode,omitempty"`
+	SkillRating *int    `json:"skillRating,omitempty"`
+}
+
+type QueuePreferencesInput struct {
+	Region      *string `json:"region,omitempty"`
+	Language    *string `json:"language,omitempty"`
+	Mode        *string `json:"mode,omitempty"`
+	SkillRating *int    `json:"skillRating,omitempty"`
+}
+
+type QueueStatus struct {
+	GameID      string            `json:"gameId"`
+	Position    int               `json:"position"`
+	Priority    int               `json:"priority"`
+	Preferences *QueuePreferences `json:"preferences"`
+	JoinedAt    time.Time         `json:"joinedAt"`
+	WaitSeconds int               `json:"waitSeconds"`
+	ExpiresAt   *time.Time        `json:"expiresAt,omitempty"`
+	PartyID     *string           `json:"partyId,omitempty"`
+}
+
+type Rating struct {
+	GameID      string    `json:"gameId"`
+	Rating      float64   `json:"rating"`
+	Deviation   float64   `json:"deviation"`
+	Volatility  float64   `json:"volatility"`
+	GamesPlayed int       `json:"gamesPlayed"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
 type Session struct {
-	ID        string        `json:"id"`
-	Game      *Game         `json:"game"`
-	Status    SessionStatus `json:"status"`
-	CreatedAt time.Time     `json:"createdAt"`
-	Players   []*User       `json:"players"`
+	ID        string           `json:"id"`
+	Game      *Game            `json:"game"`
+	Status    SessionStatus    `json:"status"`
+	CreatedAt time.Time        `json:"createdAt"`
+	StartedAt *time.Time       `json:"startedAt,omitempty"`
+	EndedAt   *time.Time       `json:"endedAt,omitempty"`
+	Players   []*SessionPlayer `json:"players"`
+	OpenSlots int              `json:"openSlots"`
+	Result    *SessionResult   `json:"result,omitempty"`
+}
+
+type SessionConnection struct {
+	Edges    []*SessionEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type SessionEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Session `json:"node"`
+}
+
+type SessionPlayer struct {
+	User       *User             `json:"user"`
+	Status     ParticipantStatus `json:"status"`
+	Role       *string           `json:"role,omitempty"`
+	JoinedAt   time.Time         `json:"joinedAt"`
+	LastSeenAt *time.Time        `json:"lastSeenAt,omitempty"`
+	LeftAt     *time.Time        `json:"leftAt,omitempty"`
+}
+
+type SessionResult struct {
+	Players []*PlayerResult `json:"players"`
+	Stats   map[string]any  `json:"stats,omitempty"`
+}
+
+type SessionResultInput struct {
+	Players []*PlayerResultInput `json:"players"`
+	Stats   map[string]any       `json:"stats,omitempty"`
+}
+
+type Subscription struct {
+}
+
+type UpdateGameInput struct {
+	Name                     *string `json:"name,omitempty"`
+	Description              *string `json:"description,omitempty"`
+	Version                  *string `json:"version,omitempty"`
+	MinPlayers               *int    `json:"minPlayers,omitempty"`
+	MaxPlayers               *int    `json:"maxPlayers,omitempty"`
+	EstimatedDurationMinutes *int    `json:"estimatedDurationMinutes,omitempty"`
+	Category                 *string `json:"category,omitempty"`
+	MaxQueueWaitSeconds      *int    `json:"maxQueueWaitSeconds,omitempty"`
+	JoinURLTemplate          *string `json:"joinUrlTemplate,omitempty"`
+	Backfill                 *bool   `json:"backfill,omitempty"`
 }
 
 type User struct {
 	ID          string    `json:"id"`
 	Email       *string   `json:"email,omitempty"`
 	DisplayName *string   `json:"displayName,omitempty"`
+	Roles       []Role    `json:"roles"`
 	CreatedAt   time.Time `json:"createdAt"`
+	Rating      *Rating   `json:"rating,omitempty"`
+}
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeGoodsWrite    APIKeyScope = "GOODS_WRITE"
+	APIKeyScopeSessionsWrite APIKeyScope = "SESSIONS_WRITE"
+)
+
+var AllAPIKeyScope = []APIKeyScope{
+	APIKeyScopeGoodsWrite,
+	APIKeyScopeSessionsWrite,
+}
+
+func (e APIKeyScope) IsValid() bool {
+	switch e {
+	case APIKeyScopeGoodsWrite, APIKeyScopeSessionsWrite:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyScope) String() string {
+	return string(e)
+}
+
+func (e *APIKeyScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ApiKeyScope", str)
+	}
+	return nil
+}
+
+func (e APIKeyScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *APIKeyScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e APIKeyScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type GameSort string
+
+const (
+	GameSortName    GameSort = "NAME"
+	GameSortNewest  GameSort = "NEWEST"
+	GameSortPopular GameSort = "POPULAR"
+)
+
+var AllGameSort = []GameSort{
+	GameSortName,
+	GameSortNewest,
+	GameSortPopular,
+}
+
+func (e GameSort) IsValid() bool {
+	switch e {
+	case GameSortName, GameSortNewest, GameSortPopular:
+		return true
+	}
+	return false
+}
+
+func (e GameSort) String() string {
+	return string(e)
+}
+
+func (e *GameSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GameSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GameSort", str)
+	}
+	return nil
+}
+
+func (e GameSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *GameSort) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e GameSort) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type GameStatus string
+
+const (
+	GameStatusActive      GameStatus = "ACTIVE"
+	GameStatusInactive    GameStatus = "INACTIVE"
+	GameStatusMaintenance GameStatus = "MAINTENANCE"
+)
+
+var AllGameStatus = []GameStatus{
+	GameStatusActive,
+	GameStatusInactive,
+	GameStatusMaintenance,
+}
+
+func (e GameStatus) IsValid() bool {
+	switch e {
+	case GameStatusActive, GameStatusInactive, GameStatusMaintenance:
+		return true
+	}
+	return false
+}
+
+func (e GameStatus) String() string {
+	return string(e)
+}
+
+func (e *GameStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GameStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GameStatus", str)
+	}
+	return nil
+}
+
+func (e GameStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *GameStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e GameStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ParticipantStatus string
+
+const (
+	ParticipantStatusMatched   ParticipantStatus = "MATCHED"
+	ParticipantStatusConnected ParticipantStatus = "CONNECTED"
+	ParticipantStatusLeft      ParticipantStatus = "LEFT"
+)
+
+var AllParticipantStatus = []ParticipantStatus{
+	ParticipantStatusMatched,
+	ParticipantStatusConnected,
+	ParticipantStatusLeft,
+}
+
+func (e ParticipantStatus) IsValid() bool {
+	switch e {
+	case ParticipantStatusMatched, ParticipantStatusConnected, ParticipantStatusLeft:
+		return true
+	}
+	return false
+}
+
+func (e ParticipantStatus) String() string {
+	return string(e)
+}
+
+func (e *ParticipantStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ParticipantStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ParticipantStatus", str)
+	}
+	return nil
+}
+
+func (e ParticipantStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ParticipantStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ParticipantStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PartyMemberStatus string
+
+const (
+	PartyMemberStatusInvited PartyMemberStatus = "INVITED"
+	PartyMemberStatusJoined  PartyMemberStatus = "JOINED"
+)
+
+var AllPartyMemberStatus = []PartyMemberStatus{
+	PartyMemberStatusInvited,
+	PartyMemberStatusJoined,
+}
+
+func (e PartyMemberStatus) IsValid() bool {
+	switch e {
+	case PartyMemberStatusInvited, PartyMemberStatusJoined:
+		return true
+	}
+	return false
+}
+
+func (e PartyMemberStatus) String() string {
+	return string(e)
+}
+
+func (e *PartyMemberStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PartyMemberStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PartyMemberStatus", str)
+	}
+	return nil
+}
+
+func (e PartyMemberStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PartyMemberStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PartyMemberStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PresenceEvent string
+
+const (
+	PresenceEventJoin      PresenceEvent = "JOIN"
+	PresenceEventHeartbeat PresenceEvent = "HEARTBEAT"
+	PresenceEventLeave     PresenceEvent = "LEAVE"
+)
+
+var AllPresenceEvent = []PresenceEvent{
+	PresenceEventJoin,
+	PresenceEventHeartbeat,
+	PresenceEventLeave,
+}
+
+func (e PresenceEvent) IsValid() bool {
+	switch e {
+	case PresenceEventJoin, PresenceEventHeartbeat, PresenceEventLeave:
+		return true
+	}
+	return false
+}
+
+func (e PresenceEvent) String() string {
+	return string(e)
+}
+
+func (e *PresenceEvent) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PresenceEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PresenceEvent", str)
+	}
+	return nil
+}
+
+func (e PresenceEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PresenceEvent) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PresenceEvent) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RolePlayer    Role = "PLAYER"
+	RolePublisher Role = "PUBLISHER"
+	RoleSupport   Role = "SUPPORT"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RolePlayer,
+	RolePublisher,
+	RoleSupport,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RolePlayer, RolePublisher, RoleSupport, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
 
 type SessionStatus string
 
 const (
-	SessionStatusPending SessionStatus = "PENDING"
-	SessionStatusActive  SessionStatus = "ACTIVE"
-	SessionStatusEnded   SessionStatus = "ENDED"
+	SessionStatusPending   SessionStatus = "PENDING"
+	SessionStatusActive    SessionStatus = "ACTIVE"
+	SessionStatusCompleted SessionStatus = "COMPLETED"
+	SessionStatusCancelled SessionStatus = "CANCELLED"
 )
 
 var AllSessionStatus = []SessionStatus{
 	SessionStatusPending,
 	SessionStatusActive,
-	SessionStatusEnded,
+	SessionStatusCompleted,
+	SessionStatusCancelled,
 }
 
 func (e SessionStatus) IsValid() bool {
 	switch e {
-	case SessionStatusPending, SessionStatusActive, SessionStatusEnded:
+	case SessionStatusPending, SessionStatusActive, SessionStatusCompleted, SessionStatusCancelled:
 		return true
 	}
 	return false
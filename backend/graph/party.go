@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// maxPartySize caps the members and invitees of a party
+const maxPartySize = 8
+
+// loadParty loads partyID, failing with PARTY_NOT_FOUND when it does not exist
+func (r *Resolver) loadParty(ctx context.Context, partyID, op string) (*store.Party, error) {
+	party, err := r.Store.Parties.Get(ctx, partyID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codePartyNotFound, "party not found")
+	}
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, errors.New("failed to load party")
+	}
+	return party, nil
+}
+
+// partyResult reloads partyID after a change made by op
+func (r *Resolver) partyResult(ctx context.Context, partyID, op string) (*model.Party, error) {
+	party, err := r.loadParty(ctx, partyID, op)
+	if err != nil {
+		return nil, err
+	}
+	return toModelParty(party), nil
+}
+
+// enqueueParty queues the party led by leaderID for game, with one entry per
+// joined member carrying the leader's preferences with the member's own
+// rating, and returns the leader's
+// entry. A party already waiting for the game keeps its entries.
+func (r *Resolver) enqueueParty(ctx context.Context, game *store.Game, leaderID string, prefs store.QueuePreferences, expires time.Time) (*store.QueueEntry, error) {
+	party, err := r.Store.Parties.ForUser(ctx, leaderID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeValidationError, "you are not in a party")
+	}
+	if err != nil {
+		log.Printf("joinGame: %v", err)
+		return nil, errors.New("failed to load party")
+	}
+	if party.LeaderID != leaderID {
+		return nil, newError(ctx, codeForbidden, "only the party leader can queue the party")
+	}
+	if e, err := r.Store.Queues.Waiting(ctx, game.ID, leaderID); err == nil && e.PartyID == party.ID {
+		return e, nil
+	}
+	joined := party.Joined()
+	if len(joined) > game.MaxPlayers {
+		return nil, newError(ctx, codeValidationError,
+			fmt.Sprintf("the party has %d members but the game allows at most %d players", len(joined), game.MaxPlayers))
+	}
+
+	// The leader comes first among the members. Each member is matched on
+	// their own rating.
+	entries := make([]*store.QueueEntry, len(joined))
+	for i, m := range joined {
+		memberPrefs, err := r.withRating(ctx, game.ID, m.User.ID, prefs)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = &store.QueueEntry{GameID: game.ID, UserID: m.User.ID, PartyID: party.ID, Preferences: memberPrefs, ExpiresAt: &expires}
+	}
+	err = r.Store.Queues.EnqueueParty(ctx, entries)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, newError(ctx, codeValidationError, "a party member is already queued for this game")
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("joinGame: %v", err)
+		return nil, errors.New("failed to join queue")
+	}
+	return entries[0], nil
+}
@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// maxQueuePriority bounds setQueuePriority in both directions
+const maxQueuePriority = 1000
+
+// DefaultQueueMaxWait is how long players wait for a match in games without
+// their own limit unless Resolver.QueueMaxWait says otherwise
+const DefaultQueueMaxWait = 10 * time.Minute
+
+// queueMaxWait returns how long players may wait in the queue of game
+func (r *Resolver) queueMaxWait(game *store.Game) time.Duration {
+	if game.MaxQueueWaitSeconds > 0 {
+		return time.Duration(game.MaxQueueWaitSeconds) * time.Second
+	}
+	if r.QueueMaxWait > 0 {
+		return r.QueueMaxWait
+	}
+	return DefaultQueueMaxWait
+}
+
+// queuePreferences validates the preferences of joinGame. Text preferences
+// are compared ignoring case.
+func queuePreferences(ctx context.Context, input *model.QueuePreferencesInput) (store.QueuePreferences, error) {
+	var prefs store.QueuePreferences
+	if input == nil {
+		return prefs, nil
+	}
+	for _, f := range []struct {
+		name string
+		dst  *string
+		src  *string
+	}{
+		{"region", &prefs.Region, input.Region},
+		{"language", &prefs.Language, input.Language},
+		{"mode", &prefs.Mode, input.Mode},
+	} {
+		if f.src == nil {
+			continue
+		}
+		*f.dst = strings.ToLower(strings.TrimSpace(*f.src))
+		if len(*f.dst) > 32 {
+			return prefs, newError(ctx, codeValidationError, f.name+" must be at most 32 characters")
+		}
+	}
+	if input.SkillRating != nil {
+		if *input.SkillRating < 0 {
+			return prefs, newError(ctx, codeValidationError, "skillRating must not be negative")
+		}
+		prefs.SkillRating = input.SkillRating
+	}
+	return prefs, nil
+}
+
+// withRating returns prefs with the skill rating set to the user's rating
+// for the game, rounded. Unrated users keep the rating they asked for.
+func (r *Resolver) withRating(ctx context.Context, gameID, userID string, prefs store.QueuePreferences) (store.QueuePreferences, error) {
+	rating, err := r.Store.Ratings.Get(ctx, userID, gameID)
+	if errors.Is(err, store.ErrNotFound) {
+		return prefs, nil
+	}
+	if err != nil {
+		log.Printf("joinGame: %v", err)
+		return prefs, errors.New("failed to load rating")
+	}
+	skill := int(math.Round(rating.Value))
+	prefs.SkillRating = &skill
+	return prefs, nil
+}
+
+// enqueue adds entry to its game's queue. A user already waiting keeps their
+// entry, and with it their place and preferences.
+func (r *Resolver) enqueue(ctx context.Context, entry *store.QueueEntry) (*store.QueueEntry, error) {
+	err := r.Store.Queues.Enqueue(ctx, entry)
+	if errors.Is(err, store.ErrConflict) {
+		entry, err = r.Store.Queues.Waiting(ctx, entry.GameID, entry.UserID)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, newError(ctx, codeGameNotFound, "game not found")
+	}
+	if err != nil {
+		log.Printf("joinGame: %v", err)
+		return nil, errors.New("failed to join queue")
+	}
+	return entry, nil
+}
+
+// queueStatus describes the waiting entry e
+func (r *Resolver) queueStatus(ctx context.Context, e *store.QueueEntry) (*model.QueueStatus, error) {
+	pos, err := r.Store.Queues.Position(ctx, e)
+	if err != nil {
+		log.Printf("queueStatus: %v", err)
+		return nil, errors.New("failed to load queue status")
+	}
+	return &model.QueueStatus{
+		GameID:      e.GameID,
+		PartyID:     optionalString(e.PartyID),
+		Position:    pos,
+		Priority:    e.Priority,
+		Preferences: toModelQueuePreferences(e.Preferences),
+		JoinedAt:    e.JoinedAt,
+		WaitSeconds: int(time.Since(e.JoinedAt).Seconds()),
+		ExpiresAt:   e.ExpiresAt,
+	}, nil
+}
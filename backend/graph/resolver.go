@@ -1,7 +1,30 @@
 package graph
 
+import (
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/mail"
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/ratelimit"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
-type Resolver struct{}
+type Resolver struct {
+	Store             store.Store
+	MagicLinks        *auth.MagicLinks
+	LoginIPLimiter    *ratelimit.Limiter // loginMagic attempts per client IP; nil disables
+	LoginEmailLimiter *ratelimit.Limiter // loginMagic attempts per email address; nil disables
+	AuthSessions      *auth.Sessions
+	Roles             *auth.RoleStore
+	GameKeys          *auth.APIKeys
+	Signer            *auth.Signer
+	Mailer            mail.Mailer
+	MailTemplates     *mail.Templates
+	Events            *notify.Hub   // feeds subscriptions; nil disables them
+	QueueMaxWait      time.Duration // queue time of games without their own limit; 0 uses DefaultQueueMaxWait
+}
@@ -2,91 +2,256 @@ package graph
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/99designs/gqlgen/client"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/scruffyprodigy/playhub/graph/generated"
 	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/matchmaker"
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/rating"
+	"github.com/scruffyprodigy/playhub/internal/store"
+	"github.com/scruffyprodigy/playhub/internal/store/memory"
 )
 
-func TestMeResolver(t *testing.T) {
+// fixture holds the rows seeded by newTestResolver
+type fixture struct {
+	player    *store.User
+	publisher *store.User
+	game      *store.Game
+	good      *store.Good
+}
+
+// newTestResolver returns a resolver backed by an in-memory store holding a
+// player, a publisher owning one game and one good of that game
+func newTestResolver(t *testing.T) (*Resolver, *fixture) {
+	t.Helper()
+	ctx := context.Background()
+	st := memory.New()
+	f := &fixture{
+		player:    &store.User{Email: "player@example.com", Username: "player", DisplayName: "Player"},
+		publisher: &store.User{Email: "publisher@example.com", Username: "publisher", DisplayName: "Publisher"},
+	}
+	for _, u := range []*store.User{f.player, f.publisher} {
+		if err := st.Users.Create(ctx, u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	f.game = &store.Game{Name: "Chess", OwnerID: f.publisher.ID}
+	if err := st.Games.Create(ctx, f.game); err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	f.good = &store.Good{GameID: f.game.ID, Code: "SKIN_001", Name: "Cool Skin", Description: "A really cool skin"}
+	if err := st.Goods.Create(ctx, f.good); err != nil {
+		t.Fatalf("Failed to create good: %v", err)
+	}
+	return &Resolver{
+		Store:        st,
+		MagicLinks:   auth.NewMagicLinks(st.MagicLinks, 0, "http://localhost/auth/magic"),
+		AuthSessions: auth.NewSessions(st.AuthSessions, 0),
+		Roles:        auth.NewRoleStore(st.Roles),
+		GameKeys:     auth.NewAPIKeys(st.APIKeys),
+	}, f
+}
+
+// withSigner gives resolver a fresh signing key for tokens and join tickets
+func withSigner(t *testing.T, resolver *Resolver) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	resolver.Signer = auth.NewSigner("k1", key, auth.TokenConfig{Issuer: auth.DefaultIssuer, Audience: auth.DefaultAudience})
+}
+
+// asUser authenticates a test request as the given user holding roles
+func asUser(userID string, roles ...auth.Role) client.Option {
+	return func(bd *client.Request) {
+		bd.HTTP = bd.HTTP.WithContext(auth.WithPrincipal(bd.HTTP.Context(), &auth.Principal{
+			Kind:   auth.PrincipalUser,
+			UserID: userID,
+			Roles:  roles,
+		}))
+	}
+}
+
+// asGame authenticates a test request as a game server holding scopes
+func asGame(gameID string, scopes ...string) client.Option {
+	return func(bd *client.Request) {
+		bd.HTTP = bd.HTTP.WithContext(auth.WithPrincipal(bd.HTTP.Context(), &auth.Principal{
+			Kind:   auth.PrincipalGame,
+			GameID: gameID,
+			Scopes: scopes,
+		}))
+	}
+}
+
+func TestMeResolverAnonymous(t *testing.T) {
 	resolver := &Resolver{}
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
 	c := client.New(srv)
 
 	var resp struct {
-		Me struct {
-			ID          string
-			Email       *string
-			DisplayName *string
-			CreatedAt   string
+		Me *struct {
+			ID string
 		}
 	}
 
 	err := c.Post(`query { 
 		me { 
 			id 
-			email 
-			displayName 
-			createdAt 
 		} 
 	}`, &resp)
 	if err != nil {
 		t.Fatalf("GraphQL query failed: %v", err)
 	}
 
-	// Verify the response structure
-	if resp.Me.ID == "" {
-		t.Error("Expected me.id to be non-empty")
+	// Without a signed-in caller there is no current user
+	if resp.Me != nil {
+		t.Errorf("Expected me to be null for anonymous caller, got %+v", resp.Me)
+	}
+}
+
+func TestMeResolver(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		Me *struct {
+			ID          string
+			DisplayName string
+			Roles       []string
+		}
+	}
+	if err := c.Post(`query { me { id displayName roles } }`, &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+	if resp.Me == nil || resp.Me.ID != f.player.ID || resp.Me.DisplayName != "Player" {
+		t.Fatalf("Expected me to be the seeded player, got %+v", resp.Me)
+	}
+	if len(resp.Me.Roles) != 1 || resp.Me.Roles[0] != "PLAYER" {
+		t.Errorf("Expected roles [PLAYER], got %v", resp.Me.Roles)
+	}
+
+	// A token for a deleted account resolves to no user
+	resp.Me = nil
+	if err := c.Post(`query { me { id } }`, &resp, asUser("deleted-user")); err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+	if resp.Me != nil {
+		t.Errorf("Expected me to be null for unknown user, got %+v", resp.Me)
+	}
+}
+
+func TestRefreshSessionWithRevokedAccessCookie(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	withSigner(t, resolver)
+	ctx := context.Background()
+
+	// The access cookie still names a session that has since been revoked,
+	// while the refresh cookie belongs to a live one
+	stale, _, err := resolver.AuthSessions.Create(ctx, f.player.ID, "", "")
+	if err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+	access, _, err := resolver.Signer.IssueAccessToken(f.player, nil, stale.ID)
+	if err != nil {
+		t.Fatalf("IssueAccessToken failed: %v", err)
+	}
+	if err := resolver.AuthSessions.Revoke(ctx, stale.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	_, refreshToken, err := resolver.AuthSessions.Create(ctx, f.player.ID, "", "")
+	if err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	h := auth.Middleware(resolver.Signer.Verifier(), resolver.AuthSessions, resolver.GameKeys, srv)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"mutation { refreshSession { id } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: auth.AccessCookieName, Value: access})
+	req.AddCookie(&http.Cookie{Name: auth.RefreshCookieName, Value: refreshToken})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data   struct{ RefreshSession struct{ ID string } }
+		Errors []map[string]any
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response %q: %v", rec.Body.String(), err)
 	}
-	if resp.Me.Email == nil || *resp.Me.Email == "" {
-		t.Error("Expected me.email to be non-empty")
+	if rec.Code != http.StatusOK || len(resp.Errors) > 0 || resp.Data.RefreshSession.ID != f.player.ID {
+		t.Fatalf("Expected refreshSession to succeed, got %d: %s", rec.Code, rec.Body.String())
 	}
-	if resp.Me.DisplayName == nil || *resp.Me.DisplayName == "" {
-		t.Error("Expected me.displayName to be non-empty")
+	var fresh string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == auth.AccessCookieName {
+			fresh = c.Value
+		}
 	}
-	if resp.Me.CreatedAt == "" {
-		t.Error("Expected me.createdAt to be non-empty")
+	if fresh == "" || fresh == access {
+		t.Errorf("Expected a new access cookie, got %q", fresh)
 	}
 }
 
 func TestGamesResolver(t *testing.T) {
-	resolver := &Resolver{}
+	resolver, f := newTestResolver(t)
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
 	c := client.New(srv)
 
 	var resp struct {
-		Games []struct {
-			ID        string
-			Name      string
-			CreatedAt string
+		Games struct {
+			Edges []struct {
+				Cursor string
+				Node   struct {
+					ID        string
+					Name      string
+					CreatedAt string
+				}
+			}
 		}
 	}
 
 	err := c.Post(`query { 
 		games { 
-			id 
-			name 
-			createdAt 
+			edges { 
+				cursor 
+				node { id name createdAt } 
+			} 
 		} 
 	}`, &resp)
 	if err != nil {
 		t.Fatalf("GraphQL query failed: %v", err)
 	}
 
-	// Verify we get some games back
-	if len(resp.Games) == 0 {
-		t.Error("Expected to get at least one game")
+	// Verify we get the seeded game back
+	if len(resp.Games.Edges) != 1 {
+		t.Fatalf("Expected exactly one game, got %d", len(resp.Games.Edges))
 	}
-
-	// Verify the structure of the first game
-	game := resp.Games[0]
-	if game.ID == "" {
-		t.Error("Expected game.id to be non-empty")
+	edge := resp.Games.Edges[0]
+	if edge.Cursor == "" {
+		t.Error("Expected edge.cursor to be non-empty")
+	}
+	game := edge.Node
+	if game.ID != f.game.ID {
+		t.Errorf("Expected game.id %q, got %q", f.game.ID, game.ID)
 	}
-	if game.Name == "" {
-		t.Error("Expected game.name to be non-empty")
+	if game.Name != "Chess" {
+		t.Errorf("Expected game.name to be 'Chess', got %q", game.Name)
 	}
 	if game.CreatedAt == "" {
 		t.Error("Expected game.createdAt to be non-empty")
@@ -94,39 +259,217 @@ func TestGamesResolver(t *testing.T) {
 }
 
 func TestGamesWithPagination(t *testing.T) {
-	resolver := &Resolver{}
+	resolver, f := newTestResolver(t)
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
 	c := client.New(srv)
 
-	var resp struct {
-		Games []struct {
-			ID   string
-			Name string
+	newer := &store.Game{Name: "Go"}
+	if err := resolver.Store.Games.Create(context.Background(), newer); err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	type page struct {
+		Games struct {
+			Edges []struct {
+				Node struct{ ID string }
+			}
+			PageInfo struct {
+				HasNextPage     bool
+				HasPreviousPage bool
+				EndCursor       *string
+			}
 		}
 	}
+	query := `query($after: String) { games(first: 1, after: $after) {
+		edges { node { id } }
+		pageInfo { hasNextPage hasPreviousPage endCursor }
+	} }`
 
-	// Test with limit
-	err := c.Post(`query { 
-		games(limit: 1) { 
-			id 
-			name 
-		} 
-	}`, &resp)
-	if err != nil {
+	// Games are listed newest first
+	var first page
+	if err := c.Post(query, &first); err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+	if len(first.Games.Edges) != 1 || first.Games.Edges[0].Node.ID != newer.ID {
+		t.Fatalf("Expected the first page to hold game %q, got %+v", newer.ID, first.Games.Edges)
+	}
+	if !first.Games.PageInfo.HasNextPage || first.Games.PageInfo.HasPreviousPage || first.Games.PageInfo.EndCursor == nil {
+		t.Fatalf("Unexpected first page info %+v", first.Games.PageInfo)
+	}
+
+	// A game inserted between requests does not shift the next page
+	if err := resolver.Store.Games.Create(context.Background(), &store.Game{Name: "Shogi"}); err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	var second page
+	if err := c.Post(query, &second, client.Var("after", *first.Games.PageInfo.EndCursor)); err != nil {
 		t.Fatalf("GraphQL query failed: %v", err)
 	}
+	if len(second.Games.Edges) != 1 || second.Games.Edges[0].Node.ID != f.game.ID {
+		t.Errorf("Expected the second page to hold game %q, got %+v", f.game.ID, second.Games.Edges)
+	}
+	if second.Games.PageInfo.HasNextPage || !second.Games.PageInfo.HasPreviousPage {
+		t.Errorf("Unexpected second page info %+v", second.Games.PageInfo)
+	}
 
-	// Should get at most 1 game
-	if len(resp.Games) > 1 {
-		t.Errorf("Expected at most 1 game with limit=1, got %d", len(resp.Games))
+	var resp map[string]any
+	err := c.Post(`query { games(first: 1000) { pageInfo { hasNextPage } } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for oversized page, got: %v", err)
+	}
+	err = c.Post(`query { games(after: "not a cursor") { pageInfo { hasNextPage } } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for malformed cursor, got: %v", err)
 	}
 }
 
-func TestCreateGameMutation(t *testing.T) {
-	resolver := &Resolver{}
+func TestSearchGames(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	ctx := context.Background()
+	games := []*store.Game{
+		{Name: "Checkers", Category: "Board", MinPlayers: 2, MaxPlayers: 2},
+		{Name: "Space Raiders", Description: "Co-op shooter in deep space", Category: "Action", MinPlayers: 1, MaxPlayers: 8},
+		{Name: "Chess Puzzles", Category: "Board", MinPlayers: 1, MaxPlayers: 1, Status: store.GameMaintenance},
+	}
+	for _, g := range games {
+		if err := resolver.Store.Games.Create(ctx, g); err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+	}
+	for range 2 {
+		if err := resolver.Store.Sessions.Create(ctx, &store.Session{GameID: games[1].ID}, nil); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+	if err := resolver.Store.Sessions.Create(ctx, &store.Session{GameID: games[0].ID}, nil); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	search := func(args string) []string {
+		t.Helper()
+		var resp struct {
+			SearchGames struct {
+				Edges []struct {
+					Node struct{ Name string }
+				}
+			}
+		}
+		if err := c.Post(`query { searchGames(`+args+`) { edges { node { name } } } }`, &resp); err != nil {
+			t.Fatalf("searchGames(%s) failed: %v", args, err)
+		}
+		names := make([]string, len(resp.SearchGames.Edges))
+		for i, e := range resp.SearchGames.Edges {
+			names[i] = e.Node.Name
+		}
+		return names
+	}
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`filter: { query: "ch" }, sort: NAME`, []string{"Checkers", "Chess", "Chess Puzzles"}},
+		{`filter: { query: "deep spa" }`, []string{"Space Raiders"}},
+		{`filter: { query: "CO-OP" }`, []string{"Space Raiders"}},
+		{`filter: { query: "board puz" }`, []string{"Chess Puzzles"}},
+		{`filter: { category: "board", status: ACTIVE }`, []string{"Checkers"}},
+		{`filter: { minPlayers: 5 }`, []string{"Space Raiders"}},
+		{`filter: { minPlayers: 2, maxPlayers: 2 }, sort: NAME`, []string{"Checkers", "Chess", "Space Raiders"}},
+		{`sort: POPULAR`, []string{"Space Raiders", "Checkers", "Chess Puzzles", "Chess"}},
+		{`first: 10`, []string{"Chess Puzzles", "Space Raiders", "Checkers", "Chess"}},
+	}
+	for _, tt := range tests {
+		if got := search(tt.args); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("searchGames(%s) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+	if got := search(`filter: { query: "zzz" }`); len(got) != 0 {
+		t.Errorf("Expected no match, got %v", got)
+	}
+
+	var resp map[string]any
+	for _, args := range []string{
+		`filter: { minPlayers: 0 }`,
+		`filter: { minPlayers: 4, maxPlayers: 2 }`,
+		fmt.Sprintf(`filter: { query: %q }`, strings.Repeat("a", 101)),
+	} {
+		err := c.Post(`query { searchGames(`+args+`) { edges { cursor } } }`, &resp)
+		if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+			t.Errorf("Expected VALIDATION_ERROR for %s, got: %v", args, err)
+		}
+	}
+}
+
+func TestSearchGamesPagination(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	ctx := context.Background()
+	gameIDs := []string{f.game.ID}
+	for _, name := range []string{"Backgammon", "Go", "Shogi"} {
+		g := &store.Game{Name: name}
+		if err := resolver.Store.Games.Create(ctx, g); err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		gameIDs = append(gameIDs, g.ID)
+	}
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
 	c := client.New(srv)
 
+	for _, sort := range []string{"NAME", "NEWEST", "POPULAR"} {
+		var names []string
+		var after *string
+		for {
+			var resp struct {
+				SearchGames struct {
+					Edges []struct {
+						Node struct{ Name string }
+					}
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+			err := c.Post(`query($after: String) { searchGames(sort: `+sort+`, first: 3, after: $after) {
+				edges { node { name } }
+				pageInfo { hasNextPage endCursor }
+			} }`, &resp, client.Var("after", after))
+			if err != nil {
+				t.Fatalf("searchGames(sort: %s) failed: %v", sort, err)
+			}
+			for _, e := range resp.SearchGames.Edges {
+				names = append(names, e.Node.Name)
+			}
+			if !resp.SearchGames.PageInfo.HasNextPage {
+				break
+			}
+			after = resp.SearchGames.PageInfo.EndCursor
+
+			// Sessions started between pages must not move a game that is
+			// still to come ahead of the cursor
+			if sort == "POPULAR" && len(names) == 3 {
+				for _, id := range gameIDs {
+					if err := resolver.Store.Sessions.Create(ctx, &store.Session{GameID: id}, nil); err != nil {
+						t.Fatalf("Failed to create session: %v", err)
+					}
+				}
+			}
+		}
+		if len(names) != 4 || len(slices.Compact(slices.Sorted(slices.Values(names)))) != 4 {
+			t.Errorf("Expected every game once sorted by %s, got %v", sort, names)
+		}
+		if sort == "NAME" && strings.Join(names, ",") != "Backgammon,Chess,Go,Shogi" {
+			t.Errorf("Expected games by name, got %v", names)
+		}
+	}
+}
+
+func TestCreateGameMutation(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
 	var resp struct {
 		CreateGame struct {
 			ID        string
@@ -141,7 +484,7 @@ func TestCreateGameMutation(t *testing.T) {
 			name 
 			createdAt 
 		} 
-	}`, &resp)
+	}`, &resp, asUser("publisher-1", auth.RolePublisher))
 	if err != nil {
 		t.Fatalf("GraphQL mutation failed: %v", err)
 	}
@@ -156,185 +499,880 @@ func TestCreateGameMutation(t *testing.T) {
 	if resp.CreateGame.CreatedAt == "" {
 		t.Error("Expected createGame.createdAt to be non-empty")
 	}
+
+	// The creating publisher owns the game
+	game, err := resolver.Store.Games.Get(context.Background(), resp.CreateGame.ID)
+	if err != nil {
+		t.Fatalf("Expected game to be stored: %v", err)
+	}
+	if game.OwnerID != "publisher-1" {
+		t.Errorf("Expected owner to be publisher-1, got %q", game.OwnerID)
+	}
+
+	err = c.Post(`mutation { createGame(input: { name: " test game " }) { id } }`, &resp, asUser("publisher-2", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for duplicate name, got: %v", err)
+	}
+	err = c.Post(`mutation { createGame(input: { name: "  " }) { id } }`, &resp, asUser("publisher-1", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for blank name, got: %v", err)
+	}
 }
 
-func TestJoinGameMutation(t *testing.T) {
-	resolver := &Resolver{}
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+func TestHasRoleDirective(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
 	c := client.New(srv)
 
 	var resp struct {
-		JoinGame struct {
-			Queued    bool
-			SessionID *string
-			JoinURL   *string
+		CreateGame struct {
+			ID string
 		}
 	}
+	query := `mutation { createGame(input: { name: "Test Game" }) { id } }`
 
-	err := c.Post(`mutation { 
-		joinGame(gameId: "test-game-id") { 
-			queued 
-			sessionId 
-			joinUrl 
-		} 
-	}`, &resp)
-	if err != nil {
-		t.Fatalf("GraphQL mutation failed: %v", err)
+	err := c.Post(query, &resp)
+	if err == nil || !strings.Contains(err.Error(), `"code":"UNAUTHORIZED"`) {
+		t.Errorf("Expected UNAUTHORIZED for anonymous caller, got: %v", err)
 	}
 
-	// Verify the response
-	if !resp.JoinGame.Queued {
-		t.Error("Expected joinGame.queued to be true")
+	err = c.Post(query, &resp, asUser("player-1"))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for player, got: %v", err)
 	}
-	if resp.JoinGame.SessionID == nil || *resp.JoinGame.SessionID == "" {
-		t.Error("Expected joinGame.sessionId to be non-empty")
-	}
-	if resp.JoinGame.JoinURL == nil || *resp.JoinGame.JoinURL == "" {
-		t.Error("Expected joinGame.joinUrl to be non-empty")
+
+	// Admins pass every role check
+	if err := c.Post(query, &resp, asUser("admin-1", auth.RoleAdmin)); err != nil {
+		t.Errorf("Expected admin to create a game, got: %v", err)
 	}
 }
 
-func TestGoodsResolver(t *testing.T) {
-	resolver := &Resolver{}
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+func TestGrantRoleRejectsMalformedUserID(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
 	c := client.New(srv)
 
-	var resp struct {
-		Goods []struct {
-			ID          string
-			Code        string
-			Name        string
-			Description *string
+	var resp map[string]any
+	for _, query := range []string{
+		`mutation { grantRole(userId: "not-a-uuid", role: SUPPORT) }`,
+		`mutation { revokeRole(userId: "not-a-uuid", role: SUPPORT) }`,
+		`mutation { revokeUserSessions(userId: "not-a-uuid") }`,
+	} {
+		err := c.Post(query, &resp, asUser("admin-1", auth.RoleAdmin))
+		if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+			t.Errorf("%s: expected VALIDATION_ERROR, got: %v", query, err)
 		}
 	}
+}
 
-	err := c.Post(`query { 
-		goods { 
-			id 
-			code 
-			name 
-			description 
-		} 
-	}`, &resp)
-	if err != nil {
-		t.Fatalf("GraphQL query failed: %v", err)
+func TestGrantAndRevokeRole(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var resp map[string]any
+	grant := fmt.Sprintf(`mutation { grantRole(userId: %q, role: SUPPORT) }`, f.player.ID)
+	if err := c.Post(grant, &resp, asUser("admin-1", auth.RoleAdmin)); err != nil {
+		t.Fatalf("grantRole failed: %v", err)
+	}
+	var me struct{ Me struct{ Roles []string } }
+	if err := c.Post(`query { me { roles } }`, &me, asUser(f.player.ID)); err != nil {
+		t.Fatalf("me failed: %v", err)
+	}
+	if !slices.Contains(me.Me.Roles, "SUPPORT") {
+		t.Errorf("Expected granted role in %v", me.Me.Roles)
 	}
 
-	// Verify we get some goods back
-	if len(resp.Goods) == 0 {
-		t.Error("Expected to get at least one good")
+	unknown := `mutation { grantRole(userId: "11111111-1111-1111-1111-111111111111", role: SUPPORT) }`
+	err := c.Post(unknown, &resp, asUser("admin-1", auth.RoleAdmin))
+	if err == nil || !strings.Contains(err.Error(), `"code":"USER_NOT_FOUND"`) {
+		t.Errorf("Expected USER_NOT_FOUND for unknown user, got: %v", err)
 	}
 
-	// Verify the structure of the first good
-	good := resp.Goods[0]
-	if good.ID == "" {
-		t.Error("Expected good.id to be non-empty")
+	revoke := fmt.Sprintf(`mutation { revokeRole(userId: %q, role: SUPPORT) }`, f.player.ID)
+	if err := c.Post(revoke, &resp, asUser("admin-1", auth.RoleAdmin)); err != nil {
+		t.Fatalf("revokeRole failed: %v", err)
 	}
-	if good.Code == "" {
-		t.Error("Expected good.code to be non-empty")
+	if err := c.Post(`query { me { roles } }`, &me, asUser(f.player.ID)); err != nil {
+		t.Fatalf("me failed: %v", err)
 	}
-	if good.Name == "" {
-		t.Error("Expected good.name to be non-empty")
+	if slices.Contains(me.Me.Roles, "SUPPORT") {
+		t.Errorf("Expected role to be revoked, got %v", me.Me.Roles)
 	}
 }
 
-func TestMyInventoryResolver(t *testing.T) {
-	resolver := &Resolver{}
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+func TestGrantGoodRequiresOwnership(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
 	c := client.New(srv)
 
 	var resp struct {
-		MyInventory []struct {
-			Good struct {
-				ID   string
-				Code string
-				Name string
-			}
-			Quantity  int
-			GrantedAt string
-		}
+		GrantGood bool
 	}
+	query := fmt.Sprintf(`mutation { grantGood(userId: %q, goodId: %q) }`, f.player.ID, f.good.ID)
 
-	err := c.Post(`query { 
-		myInventory { 
-			good { 
-				id 
-				code 
-				name 
-			} 
-			quantity 
-			grantedAt 
-		} 
-	}`, &resp)
+	// Support staff may grant any good
+	if err := c.Post(query, &resp, asUser("support-1", auth.RoleSupport)); err != nil || !resp.GrantGood {
+		t.Errorf("Expected support to grant good, got %v (%v)", resp.GrantGood, err)
+	}
+	// Publishers may grant goods of their own games
+	if err := c.Post(query, &resp, asUser(f.publisher.ID, auth.RolePublisher)); err != nil || !resp.GrantGood {
+		t.Errorf("Expected owner to grant good, got %v (%v)", resp.GrantGood, err)
+	}
+
+	err := c.Post(query, &resp, asUser("publisher-2", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for other publisher, got: %v", err)
+	}
+	err = c.Post(query, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for player, got: %v", err)
+	}
+}
+
+func TestGrantAndRevokeGood(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	support := asUser("support-1", auth.RoleSupport)
+
+	var resp map[string]any
+	if err := c.Post(fmt.Sprintf(`mutation { grantGood(userId: %q, goodId: %q, quantity: 3) }`, f.player.ID, f.good.ID), &resp, support); err != nil {
+		t.Fatalf("grantGood failed: %v", err)
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { revokeGood(userId: %q, goodId: %q) }`, f.player.ID, f.good.ID), &resp, support); err != nil {
+		t.Fatalf("revokeGood failed: %v", err)
+	}
+
+	items, err := resolver.Store.Inventory.List(context.Background(), f.player.ID, "", store.Page{})
 	if err != nil {
-		t.Fatalf("GraphQL query failed: %v", err)
+		t.Fatalf("Failed to list inventory: %v", err)
+	}
+	if len(items) != 1 || items[0].Quantity != 2 {
+		t.Fatalf("Expected 2 of the good to be left, got %+v", items)
 	}
 
-	// Verify we get some inventory back
-	if len(resp.MyInventory) == 0 {
-		t.Error("Expected to get at least one inventory item")
+	err = c.Post(fmt.Sprintf(`mutation { grantGood(userId: %q, goodId: %q, quantity: 0) }`, f.player.ID, f.good.ID), &resp, support)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for zero quantity, got: %v", err)
 	}
+	err = c.Post(fmt.Sprintf(`mutation { revokeGood(userId: %q, goodId: %q) }`, f.publisher.ID, f.good.ID), &resp, support)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR when revoking an unowned good, got: %v", err)
+	}
+}
 
-	// Verify the structure of the first inventory item
-	item := resp.MyInventory[0]
-	if item.Good.ID == "" {
-		t.Error("Expected inventory.good.id to be non-empty")
+func TestHasRoleGameScope(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var resp map[string]any
+	grant := fmt.Sprintf(`mutation { grantGood(userId: %q, goodId: %q) }`, f.player.ID, f.good.ID)
+
+	// Game servers never pass fields without a gameScope
+	err := c.Post(`mutation { createGame(input: { name: "Test Game" }) { id } }`, &resp, asGame(f.game.ID, auth.ScopeGoodsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for game on createGame, got: %v", err)
 	}
-	if item.Good.Code == "" {
-		t.Error("Expected inventory.good.code to be non-empty")
+
+	err = c.Post(grant, &resp, asGame(f.game.ID, auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for game without goods:write, got: %v", err)
 	}
-	if item.Good.Name == "" {
-		t.Error("Expected inventory.good.name to be non-empty")
+
+	// With the scope the directive passes and the resolver checks the good's game
+	if err := c.Post(grant, &resp, asGame(f.game.ID, auth.ScopeGoodsWrite)); err != nil {
+		t.Errorf("Expected game to grant its own good, got: %v", err)
 	}
-	if item.Quantity <= 0 {
-		t.Error("Expected inventory.quantity to be positive")
+	err = c.Post(grant, &resp, asGame("other-game", auth.ScopeGoodsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's good, got: %v", err)
 	}
-	if item.GrantedAt == "" {
-		t.Error("Expected inventory.grantedAt to be non-empty")
+}
+
+func TestAPIKeyScopeMapping(t *testing.T) {
+	for _, scope := range []string{auth.ScopeGoodsWrite, auth.ScopeSessionsWrite} {
+		m := toModelScope(scope)
+		if !m.IsValid() {
+			t.Errorf("Expected %q to map to a schema scope, got %q", scope, m)
+		}
+		if back := toAuthScope(m); back != scope {
+			t.Errorf("Expected %q to round trip, got %q", scope, back)
+		}
 	}
 }
 
-// Test error handling
-func TestGameNotFound(t *testing.T) {
-	resolver := &Resolver{}
+func TestJoinGameMutation(t *testing.T) {
+	resolver, f := newTestResolver(t)
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
 	c := client.New(srv)
 
 	var resp struct {
-		Game *struct {
-			ID   string
-			Name string
+		JoinGame struct {
+			Queued bool
+			Queue  *struct {
+				GameID   string
+				Position int
+			}
+			SessionID *string
+			JoinURL   *string
 		}
 	}
+	join := fmt.Sprintf(`mutation {
+		joinGame(gameId: %q) {
+			queued
+			queue { gameId position }
+			sessionId
+			joinUrl
+		}
+	}`, f.game.ID)
 
-	err := c.Post(`query { 
-		game(id: "non-existent-id") { 
-			id 
-			name 
-		} 
-	}`, &resp)
+	for _, u := range []*store.User{f.player, f.publisher} {
+		resp.JoinGame.Queue = nil
+		if err := c.Post(join, &resp, asUser(u.ID)); err != nil {
+			t.Fatalf("GraphQL mutation failed: %v", err)
+		}
+	}
+	if !resp.JoinGame.Queued || resp.JoinGame.Queue == nil || resp.JoinGame.Queue.Position != 2 {
+		t.Fatalf("Expected the second player to be queued at position 2, got %+v", resp.JoinGame)
+	}
+	if resp.JoinGame.SessionID != nil || resp.JoinGame.JoinURL != nil {
+		t.Errorf("Expected no session before a match, got %+v", resp.JoinGame)
+	}
 
-	// The resolver returns an error for non-existent games, which is expected
-	if err == nil {
-		t.Error("Expected GraphQL query to return an error for non-existent game")
+	// Joining again keeps the player's place
+	resp.JoinGame.Queue = nil
+	if err := c.Post(join, &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("GraphQL mutation failed: %v", err)
+	}
+	if resp.JoinGame.Queue == nil || resp.JoinGame.Queue.Position != 1 {
+		t.Errorf("Expected the first player to stay at position 1, got %+v", resp.JoinGame.Queue)
+	}
+	waiting, err := resolver.Store.Queues.ListWaiting(context.Background(), f.game.ID, 0)
+	if err != nil || len(waiting) != 2 {
+		t.Errorf("Expected two waiting entries, got %d (%v)", len(waiting), err)
 	}
 
-	// Verify the error message contains "game not found"
-	if err != nil && err.Error() != `[{"message":"game not found","path":["game"]}]` {
-		t.Errorf("Expected 'game not found' error, got: %v", err)
+	var anon map[string]any
+	err = c.Post(join, &anon)
+	if err == nil || !strings.Contains(err.Error(), `"code":"UNAUTHORIZED"`) {
+		t.Errorf("Expected UNAUTHORIZED for anonymous callers, got: %v", err)
 	}
 }
 
-// Test direct resolver calls
-func TestDirectResolverCalls(t *testing.T) {
-	resolver := &Resolver{}
-	queryResolver := resolver.Query()
-	mutationResolver := resolver.Mutation()
+func TestJoinGameIssuesTicket(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	withSigner(t, resolver)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	publisher := asUser(f.publisher.ID, auth.RolePublisher)
 
-	// Test direct healthz call
-	healthz, err := queryResolver.Healthz(context.Background())
-	if err != nil {
-		t.Fatalf("Direct healthz call failed: %v", err)
+	var updated map[string]any
+	err := c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { joinUrlTemplate: "ftp://play.example.com/{ticket}" }) { id } }`, f.game.ID), &updated, publisher)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a non-http template, got: %v", err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { joinUrlTemplate: "https://play.example.com/join" }) { id } }`, f.game.ID), &updated, publisher)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a template without a ticket, got: %v", err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { joinUrlTemplate: "https://play.example.com/join/{sessionId}?ticket={ticket}" }) { id } }`, f.game.ID), &updated, publisher)
+	if err != nil {
+		t.Fatalf("updateGame failed: %v", err)
+	}
+
+	ctx := context.Background()
+	session := &store.Session{GameID: f.game.ID}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	var resp struct {
+		JoinGame struct {
+			Queued          bool
+			SessionID       *string
+			Ticket          *string
+			TicketExpiresAt *string
+			JoinURL         *string
+		}
+	}
+	join := fmt.Sprintf(`mutation { joinGame(gameId: %q) { queued sessionId ticket ticketExpiresAt joinUrl } }`, f.game.ID)
+	if err := c.Post(join, &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	got := resp.JoinGame
+	if got.Queued || got.SessionID == nil || *got.SessionID != session.ID || got.Ticket == nil || got.TicketExpiresAt == nil {
+		t.Fatalf("Expected a ticket for the matched session, got %+v", got)
+	}
+	if want := "https://play.example.com/join/" + session.ID + "?ticket=" + *got.Ticket; got.JoinURL == nil || *got.JoinURL != want {
+		t.Errorf("Expected join URL %s, got %v", want, got.JoinURL)
+	}
+
+	// Game servers verify tickets against the public keys alone
+	keys := auth.StaticKeys{"k1": resolver.Signer.PublicKey()}
+	claims, err := auth.NewJoinTicketVerifier(keys, auth.DefaultIssuer, f.game.ID).VerifyJoinTicket(*got.Ticket)
+	if err != nil {
+		t.Fatalf("Ticket failed to verify: %v", err)
+	}
+	if claims.Subject != f.player.ID || claims.GameSessionID != session.ID || claims.GameID != f.game.ID {
+		t.Errorf("Unexpected ticket claims: %+v", claims)
+	}
+
+	// Once the session has ended the player queues again
+	if _, err := resolver.Store.Sessions.Transition(ctx, session.ID, store.SessionCancelled); err != nil {
+		t.Fatalf("Failed to cancel session: %v", err)
+	}
+	resp.JoinGame.Ticket = nil
+	if err := c.Post(join, &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	if !resp.JoinGame.Queued || resp.JoinGame.Ticket != nil {
+		t.Errorf("Expected the player to be queued after the session ended, got %+v", resp.JoinGame)
+	}
+}
+
+func TestLeaveQueueAndQueueStatus(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var status struct {
+		MyQueueStatus *struct {
+			Position    int
+			WaitSeconds int
+		}
+	}
+	statusQuery := fmt.Sprintf(`query { myQueueStatus(gameId: %q) { position waitSeconds } }`, f.game.ID)
+	if err := c.Post(statusQuery, &status, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus != nil {
+		t.Fatalf("Expected no queue status before joining, got %+v", status.MyQueueStatus)
+	}
+
+	var resp map[string]any
+	for _, u := range []*store.User{f.publisher, f.player} {
+		if err := c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q) { queued } }`, f.game.ID), &resp, asUser(u.ID)); err != nil {
+			t.Fatalf("joinGame failed: %v", err)
+		}
+	}
+	if err := c.Post(statusQuery, &status, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus == nil || status.MyQueueStatus.Position != 2 || status.MyQueueStatus.WaitSeconds < 0 {
+		t.Fatalf("Expected position 2, got %+v", status.MyQueueStatus)
+	}
+
+	// The player moves up once the publisher leaves
+	var left struct{ LeaveQueue bool }
+	leave := fmt.Sprintf(`mutation { leaveQueue(gameId: %q) }`, f.game.ID)
+	if err := c.Post(leave, &left, asUser(f.publisher.ID)); err != nil || !left.LeaveQueue {
+		t.Fatalf("leaveQueue = %v, %v; expected true", left.LeaveQueue, err)
+	}
+	status.MyQueueStatus = nil
+	if err := c.Post(statusQuery, &status, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus == nil || status.MyQueueStatus.Position != 1 {
+		t.Errorf("Expected position 1 after the publisher left, got %+v", status.MyQueueStatus)
+	}
+	if err := c.Post(leave, &left, asUser(f.publisher.ID)); err != nil || left.LeaveQueue {
+		t.Errorf("leaveQueue = %v, %v; expected false when not waiting", left.LeaveQueue, err)
+	}
+
+	err := c.Post(`mutation { leaveQueue(gameId: "missing") }`, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"GAME_NOT_FOUND"`) {
+		t.Errorf("Expected GAME_NOT_FOUND for unknown game, got: %v", err)
+	}
+	err = c.Post(leave, &resp)
+	if err == nil || !strings.Contains(err.Error(), `"code":"UNAUTHORIZED"`) {
+		t.Errorf("Expected UNAUTHORIZED for anonymous callers, got: %v", err)
+	}
+}
+
+func TestQueuePreferencesAndPriority(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var resp map[string]any
+	if err := c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q) { queued } }`, f.game.ID), &resp, asUser(f.publisher.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	var joined struct {
+		JoinGame struct {
+			Queue struct {
+				Position    int
+				Preferences struct {
+					Region      *string
+					Mode        *string
+					SkillRating *int
+				}
+			}
+		}
+	}
+	err := c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q, preferences: { region: " EU ", mode: "ranked", skillRating: 1500 }) {
+		queue { position preferences { region mode skillRating } }
+	} }`, f.game.ID), &joined, asUser(f.player.ID))
+	if err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	prefs := joined.JoinGame.Queue.Preferences
+	if prefs.Region == nil || *prefs.Region != "eu" || prefs.Mode == nil || *prefs.Mode != "ranked" || prefs.SkillRating == nil || *prefs.SkillRating != 1500 {
+		t.Errorf("Unexpected preferences %+v", prefs)
+	}
+	if joined.JoinGame.Queue.Position != 2 {
+		t.Errorf("Expected position 2, got %d", joined.JoinGame.Queue.Position)
+	}
+
+	// Support moves the player to the front of the queue
+	var set struct{ SetQueuePriority bool }
+	priority := fmt.Sprintf(`mutation { setQueuePriority(gameId: %q, userId: %q, priority: 10) }`, f.game.ID, f.player.ID)
+	if err := c.Post(priority, &set, asUser(f.publisher.ID, auth.RoleSupport)); err != nil || !set.SetQueuePriority {
+		t.Fatalf("setQueuePriority = %v, %v; expected true", set.SetQueuePriority, err)
+	}
+	var status struct {
+		MyQueueStatus struct {
+			Position int
+			Priority int
+		}
+	}
+	if err := c.Post(fmt.Sprintf(`query { myQueueStatus(gameId: %q) { position priority } }`, f.game.ID), &status, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus.Position != 1 || status.MyQueueStatus.Priority != 10 {
+		t.Errorf("Expected priority 10 at position 1, got %+v", status.MyQueueStatus)
+	}
+
+	err = c.Post(priority, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for players, got: %v", err)
+	}
+	err = c.Post(priority, &resp, asGame("other-game", auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's server, got: %v", err)
+	}
+	if err := c.Post(priority, &set, asGame(f.game.ID, auth.ScopeSessionsWrite)); err != nil || !set.SetQueuePriority {
+		t.Errorf("Expected the game's server to set priorities, got %v, %v", set.SetQueuePriority, err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q, preferences: { skillRating: -1 }) { queued } }`, f.game.ID), &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a negative rating, got: %v", err)
+	}
+}
+
+func TestQueueExpiry(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	resolver.Events = notify.NewHub()
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var updated map[string]any
+	err := c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { maxQueueWaitSeconds: 90 }) { maxQueueWaitSeconds } }`, f.game.ID), &updated, asUser(f.publisher.ID, auth.RolePublisher))
+	if err != nil {
+		t.Fatalf("updateGame failed: %v", err)
+	}
+	var resp struct {
+		JoinGame struct {
+			Queue struct {
+				JoinedAt  string
+				ExpiresAt *string
+			}
+		}
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q) { queue { joinedAt expiresAt } } }`, f.game.ID), &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	queue := resp.JoinGame.Queue
+	if queue.ExpiresAt == nil {
+		t.Fatal("Expected the entry to expire")
+	}
+	joined, _ := time.Parse(time.RFC3339Nano, queue.JoinedAt)
+	expires, _ := time.Parse(time.RFC3339Nano, *queue.ExpiresAt)
+	if expires.Sub(joined).Round(time.Second) != 90*time.Second {
+		t.Errorf("Expected the entry to expire 90s after joining, got %+v", queue)
+	}
+
+	err = c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { maxQueueWaitSeconds: -1 }) { id } }`, f.game.ID), &updated, asUser(f.publisher.ID, auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a negative wait, got: %v", err)
+	}
+
+	// The subscription only carries the caller's queueExpired events
+	ctx, cancel := context.WithCancel(auth.WithPrincipal(context.Background(), &auth.Principal{Kind: auth.PrincipalUser, UserID: f.player.ID}))
+	defer cancel()
+	events, err := resolver.Subscription().QueueExpired(ctx)
+	if err != nil {
+		t.Fatalf("queueExpired failed: %v", err)
+	}
+	resolver.Events.Publish(ctx, notify.Event{Type: notify.QueueExpired, UserID: f.publisher.ID, GameID: f.game.ID})
+	resolver.Events.Publish(ctx, notify.Event{Type: notify.QueueExpired, UserID: f.player.ID, GameID: f.game.ID, At: time.Now()})
+	select {
+	case e := <-events:
+		if e.GameID != f.game.ID {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a queueExpired event")
+	}
+	select {
+	case e := <-events:
+		t.Errorf("Expected no other event, got %+v", e)
+	default:
+	}
+
+	if _, err := resolver.Subscription().QueueExpired(context.Background()); err == nil {
+		t.Error("Expected anonymous subscriptions to fail")
+	}
+}
+
+func TestJoinGameUnavailable(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var resp map[string]any
+	err := c.Post(fmt.Sprintf(`mutation { setGameStatus(id: %q, status: MAINTENANCE) { status } }`, f.game.ID), &resp, asUser(f.publisher.ID, auth.RolePublisher))
+	if err != nil {
+		t.Fatalf("setGameStatus failed: %v", err)
+	}
+
+	err = c.Post(fmt.Sprintf(`mutation { joinGame(gameId: %q) { queued } }`, f.game.ID), &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"GAME_UNAVAILABLE"`) {
+		t.Errorf("Expected GAME_UNAVAILABLE during maintenance, got: %v", err)
+	}
+	err = c.Post(`mutation { joinGame(gameId: "missing") { queued } }`, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"GAME_NOT_FOUND"`) {
+		t.Errorf("Expected GAME_NOT_FOUND for unknown game, got: %v", err)
+	}
+}
+
+func TestUpdateGameMutation(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	owner := asUser(f.publisher.ID, auth.RolePublisher)
+
+	var resp struct {
+		UpdateGame struct {
+			Name                     string
+			Description              *string
+			MinPlayers               int
+			MaxPlayers               int
+			EstimatedDurationMinutes *int
+			Status                   string
+		}
+	}
+	query := fmt.Sprintf(`mutation { updateGame(id: %q, input: { description: " Classic strategy ", maxPlayers: 2, minPlayers: 2, estimatedDurationMinutes: 45 }) {
+		name description minPlayers maxPlayers estimatedDurationMinutes status
+	} }`, f.game.ID)
+	if err := c.Post(query, &resp, owner); err != nil {
+		t.Fatalf("updateGame failed: %v", err)
+	}
+	got := resp.UpdateGame
+	if got.Name != "Chess" || got.Description == nil || *got.Description != "Classic strategy" {
+		t.Errorf("Expected name kept and description trimmed, got %+v", got)
+	}
+	if got.MinPlayers != 2 || got.MaxPlayers != 2 || got.EstimatedDurationMinutes == nil || *got.EstimatedDurationMinutes != 45 {
+		t.Errorf("Expected player counts and duration to be updated, got %+v", got)
+	}
+	if got.Status != "ACTIVE" {
+		t.Errorf("Expected status to stay ACTIVE, got %q", got.Status)
+	}
+
+	err := c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { minPlayers: 3 }) { id } }`, f.game.ID), &resp, owner)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for minPlayers above maxPlayers, got: %v", err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { name: "Go" }) { id } }`, f.game.ID), &resp, asUser("publisher-2", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for other publisher, got: %v", err)
+	}
+}
+
+func TestGoodsResolver(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		Goods struct {
+			Edges []struct {
+				Node struct {
+					ID          string
+					Code        string
+					Name        string
+					Description *string
+				}
+			}
+		}
+	}
+
+	err := c.Post(`query { 
+		goods { 
+			edges { 
+				node { id code name description } 
+			} 
+		} 
+	}`, &resp)
+	if err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+
+	// Verify we get the seeded good back
+	if len(resp.Goods.Edges) != 1 {
+		t.Fatalf("Expected exactly one good, got %d", len(resp.Goods.Edges))
+	}
+	good := resp.Goods.Edges[0].Node
+	if good.ID != f.good.ID {
+		t.Errorf("Expected good.id %q, got %q", f.good.ID, good.ID)
+	}
+	if good.Code != "SKIN_001" {
+		t.Errorf("Expected good.code to be 'SKIN_001', got %q", good.Code)
+	}
+	if good.Description == nil || *good.Description != "A really cool skin" {
+		t.Errorf("Expected good.description to be set, got %v", good.Description)
+	}
+
+	// Filtering by another game returns nothing
+	resp.Goods.Edges = nil
+	if err := c.Post(`query { goods(gameId: "other-game") { edges { node { id } } } }`, &resp); err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+	if len(resp.Goods.Edges) != 0 {
+		t.Errorf("Expected no goods for another game, got %d", len(resp.Goods.Edges))
+	}
+}
+
+func TestGoodsPagination(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	// Goods are ordered by name, so these sort around the seeded "Cool Skin"
+	for _, name := range []string{"Axe", "Dragon"} {
+		good := &store.Good{GameID: f.game.ID, Code: strings.ToUpper(name), Name: name}
+		if err := resolver.Store.Goods.Create(context.Background(), good); err != nil {
+			t.Fatalf("Failed to create good: %v", err)
+		}
+	}
+
+	var names []string
+	var after *string
+	for range 3 {
+		var resp struct {
+			Goods struct {
+				Edges []struct {
+					Node struct{ Name string }
+				}
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		err := c.Post(`query($after: String) { goods(first: 2, after: $after) {
+			edges { node { name } }
+			pageInfo { hasNextPage endCursor }
+		} }`, &resp, client.Var("after", after))
+		if err != nil {
+			t.Fatalf("GraphQL query failed: %v", err)
+		}
+		for _, e := range resp.Goods.Edges {
+			names = append(names, e.Node.Name)
+		}
+		if !resp.Goods.PageInfo.HasNextPage {
+			break
+		}
+		after = resp.Goods.PageInfo.EndCursor
+	}
+
+	if want := []string{"Axe", "Cool Skin", "Dragon"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected goods %v across pages, got %v", want, names)
+	}
+}
+
+func TestMyInventoryResolver(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	if _, err := resolver.Store.Inventory.Grant(context.Background(), f.player.ID, f.good.ID, 2); err != nil {
+		t.Fatalf("Failed to grant good: %v", err)
+	}
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		MyInventory struct {
+			Edges []struct {
+				Node struct {
+					Good struct {
+						ID   string
+						Code string
+						Name string
+					}
+					Quantity  int
+					GrantedAt string
+				}
+			}
+		}
+	}
+
+	err := c.Post(`query { 
+		myInventory { 
+			edges { 
+				node { 
+					good { id code name } 
+					quantity 
+					grantedAt 
+				} 
+			} 
+		} 
+	}`, &resp, asUser(f.player.ID))
+	if err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+
+	// Verify we get the granted good back
+	if len(resp.MyInventory.Edges) != 1 {
+		t.Fatalf("Expected exactly one inventory item, got %d", len(resp.MyInventory.Edges))
+	}
+
+	// Verify the structure of the first inventory item
+	item := resp.MyInventory.Edges[0].Node
+	if item.Good.ID != f.good.ID {
+		t.Errorf("Expected inventory.good.id %q, got %q", f.good.ID, item.Good.ID)
+	}
+	if item.Good.Code == "" {
+		t.Error("Expected inventory.good.code to be non-empty")
+	}
+	if item.Good.Name == "" {
+		t.Error("Expected inventory.good.name to be non-empty")
+	}
+	if item.Quantity != 2 {
+		t.Errorf("Expected inventory.quantity to be 2, got %d", item.Quantity)
+	}
+	if item.GrantedAt == "" {
+		t.Error("Expected inventory.grantedAt to be non-empty")
+	}
+}
+
+func TestActiveSessions(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	ctx := context.Background()
+	session := &store.Session{GameID: f.game.ID, Status: store.SessionActive}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID, f.publisher.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		Game struct {
+			ActiveSessions struct {
+				Edges []struct {
+					Node struct {
+						ID      string
+						Status  string
+						Players []struct {
+							User struct {
+								ID    string
+								Email *string
+							}
+							Status string
+						}
+					}
+				}
+			}
+		}
+	}
+	err := c.Post(fmt.Sprintf(`query { game(id: %q) { activeSessions {
+		edges { node { id status players { user { id email } status } } }
+	} } }`, f.game.ID), &resp)
+	if err != nil {
+		t.Fatalf("GraphQL query failed: %v", err)
+	}
+
+	edges := resp.Game.ActiveSessions.Edges
+	if len(edges) != 1 || edges[0].Node.ID != session.ID || edges[0].Node.Status != "ACTIVE" {
+		t.Fatalf("Expected the active session, got %+v", edges)
+	}
+	players := edges[0].Node.Players
+	if len(players) != 2 {
+		t.Fatalf("Expected two players, got %+v", players)
+	}
+	for _, p := range players {
+		if p.User.Email != nil {
+			t.Errorf("Expected player emails to be hidden, got %q", *p.User.Email)
+		}
+		if p.Status != "MATCHED" {
+			t.Errorf("Expected players not yet seen by the game server to be MATCHED, got %s", p.Status)
+		}
+	}
+}
+
+func TestMyInventoryRequiresAuth(t *testing.T) {
+	resolver := &Resolver{}
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		MyInventory struct {
+			Edges []struct {
+				Node struct{ Quantity int }
+			}
+		}
+	}
+
+	err := c.Post(`query { myInventory { edges { node { quantity } } } }`, &resp)
+	if err == nil {
+		t.Fatal("Expected myInventory to fail for anonymous caller")
+	}
+	if !strings.Contains(err.Error(), `"code":"UNAUTHORIZED"`) {
+		t.Errorf("Expected UNAUTHORIZED error code, got: %v", err)
+	}
+}
+
+// Test error handling
+func TestGameNotFound(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	var resp struct {
+		Game *struct {
+			ID   string
+			Name string
+		}
+	}
+
+	err := c.Post(`query { 
+		game(id: "non-existent-id") { 
+			id 
+			name 
+		} 
+	}`, &resp)
+
+	// The resolver returns an error for non-existent games, which is expected
+	if err == nil {
+		t.Error("Expected GraphQL query to return an error for non-existent game")
+	}
+
+	// Verify the error carries the GAME_NOT_FOUND code
+	if err != nil && !strings.Contains(err.Error(), `"code":"GAME_NOT_FOUND"`) {
+		t.Errorf("Expected GAME_NOT_FOUND error, got: %v", err)
+	}
+}
+
+// Test direct resolver calls
+func TestDirectResolverCalls(t *testing.T) {
+	resolver, _ := newTestResolver(t)
+	queryResolver := resolver.Query()
+	mutationResolver := resolver.Mutation()
+
+	// Test direct healthz call
+	healthz, err := queryResolver.Healthz(context.Background())
+	if err != nil {
+		t.Fatalf("Direct healthz call failed: %v", err)
 	}
 	if healthz != "ok" {
 		t.Errorf("Expected healthz to return 'ok', got %q", healthz)
@@ -358,3 +1396,568 @@ func TestDirectResolverCalls(t *testing.T) {
 		t.Errorf("Expected game name to be 'Direct Test Game', got %q", game.Name)
 	}
 }
+
+func TestPartyQueueing(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	ctx := context.Background()
+
+	var created struct{ CreateParty struct{ ID string } }
+	if err := c.Post(`mutation { createParty { id } }`, &created, asUser(f.publisher.ID)); err != nil {
+		t.Fatalf("createParty failed: %v", err)
+	}
+	partyID := created.CreateParty.ID
+	var resp map[string]any
+	err := c.Post(fmt.Sprintf(`mutation { inviteToParty(partyId: %q, userId: %q) { id } }`, partyID, f.player.ID), &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for invites by members other than the leader, got: %v", err)
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { inviteToParty(partyId: %q, userId: %q) { id } }`, partyID, f.player.ID), &resp, asUser(f.publisher.ID)); err != nil {
+		t.Fatalf("inviteToParty failed: %v", err)
+	}
+
+	var invites struct{ MyPartyInvites []struct{ ID string } }
+	if err := c.Post(`query { myPartyInvites { id } }`, &invites, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myPartyInvites failed: %v", err)
+	}
+	if len(invites.MyPartyInvites) != 1 || invites.MyPartyInvites[0].ID != partyID {
+		t.Fatalf("Expected the invite to %s, got %+v", partyID, invites.MyPartyInvites)
+	}
+	var accepted struct {
+		AcceptPartyInvite struct {
+			Leader  struct{ ID string }
+			Members []struct {
+				User   struct{ ID string }
+				Status string
+			}
+		}
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { acceptPartyInvite(partyId: %q) { leader { id } members { user { id } status } } }`, partyID), &accepted, asUser(f.player.ID)); err != nil {
+		t.Fatalf("acceptPartyInvite failed: %v", err)
+	}
+	members := accepted.AcceptPartyInvite.Members
+	if accepted.AcceptPartyInvite.Leader.ID != f.publisher.ID || len(members) != 2 || members[1].User.ID != f.player.ID || members[1].Status != "JOINED" {
+		t.Fatalf("Expected the player to join the publisher's party, got %+v", accepted.AcceptPartyInvite)
+	}
+
+	// Only the leader queues the party, and only when it fits the game
+	join := fmt.Sprintf(`mutation { joinGame(gameId: %q, party: true) { queued queue { partyId } } }`, f.game.ID)
+	err = c.Post(join, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN when a member queues the party, got: %v", err)
+	}
+	f.game.MaxPlayers = 1
+	if err := resolver.Store.Games.Update(ctx, f.game); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Post(join, &resp, asUser(f.publisher.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a party over maxPlayers, got: %v", err)
+	}
+	f.game.MaxPlayers = 4
+	if err := resolver.Store.Games.Update(ctx, f.game); err != nil {
+		t.Fatal(err)
+	}
+	var joined struct {
+		JoinGame struct {
+			Queued bool
+			Queue  struct{ PartyID string }
+		}
+	}
+	if err := c.Post(join, &joined, asUser(f.publisher.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	if !joined.JoinGame.Queued || joined.JoinGame.Queue.PartyID != partyID {
+		t.Fatalf("Expected the party queued, got %+v", joined.JoinGame)
+	}
+	if err := c.Post(join, &joined, asUser(f.publisher.ID)); err != nil {
+		t.Errorf("Expected joining again to keep the party's place, got: %v", err)
+	}
+
+	var status struct{ MyQueueStatus *struct{ PartyID string } }
+	statusQuery := fmt.Sprintf(`query { myQueueStatus(gameId: %q) { partyId } }`, f.game.ID)
+	if err := c.Post(statusQuery, &status, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus == nil || status.MyQueueStatus.PartyID != partyID {
+		t.Fatalf("Expected the player queued with the party, got %+v", status.MyQueueStatus)
+	}
+
+	// A member leaving the queue cancels the party's entry
+	if err := c.Post(fmt.Sprintf(`mutation { leaveQueue(gameId: %q) }`, f.game.ID), &resp, asUser(f.player.ID)); err != nil {
+		t.Fatalf("leaveQueue failed: %v", err)
+	}
+	status.MyQueueStatus = nil
+	if err := c.Post(statusQuery, &status, asUser(f.publisher.ID)); err != nil {
+		t.Fatalf("myQueueStatus failed: %v", err)
+	}
+	if status.MyQueueStatus != nil {
+		t.Errorf("Expected the leader's entry cancelled, got %+v", status.MyQueueStatus)
+	}
+
+	// The lead passes on when the leader leaves
+	var left struct{ LeaveParty bool }
+	if err := c.Post(fmt.Sprintf(`mutation { leaveParty(partyId: %q) }`, partyID), &left, asUser(f.publisher.ID)); err != nil || !left.LeaveParty {
+		t.Fatalf("leaveParty = %v, %v; expected true", left.LeaveParty, err)
+	}
+	var mine struct {
+		MyParty *struct{ Leader struct{ ID string } }
+	}
+	if err := c.Post(`query { myParty { leader { id } } }`, &mine, asUser(f.player.ID)); err != nil {
+		t.Fatalf("myParty failed: %v", err)
+	}
+	if mine.MyParty == nil || mine.MyParty.Leader.ID != f.player.ID {
+		t.Errorf("Expected the player to lead the party, got %+v", mine.MyParty)
+	}
+	err = c.Post(`mutation { acceptPartyInvite(partyId: "missing") { id } }`, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"PARTY_NOT_FOUND"`) {
+		t.Errorf("Expected PARTY_NOT_FOUND for unknown parties, got: %v", err)
+	}
+}
+
+func TestRatingFeedsQueueSkill(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+
+	var me struct {
+		Me struct {
+			Rating *struct {
+				Rating      float64
+				GamesPlayed int
+			}
+		}
+	}
+	meQuery := fmt.Sprintf(`query { me { rating(gameId: %q) { rating gamesPlayed } } }`, f.game.ID)
+	if err := c.Post(meQuery, &me, asUser(f.player.ID)); err != nil {
+		t.Fatalf("me failed: %v", err)
+	}
+	if me.Me.Rating != nil {
+		t.Fatalf("Expected no rating before playing, got %+v", me.Me.Rating)
+	}
+
+	placements := map[string]int{f.player.ID: 1, f.publisher.ID: 2}
+	if err := rating.Record(context.Background(), resolver.Store.Ratings, f.game.ID, placements); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := c.Post(meQuery, &me, asUser(f.player.ID)); err != nil {
+		t.Fatalf("me failed: %v", err)
+	}
+	if me.Me.Rating == nil || me.Me.Rating.Rating <= rating.Default.Value || me.Me.Rating.GamesPlayed != 1 {
+		t.Fatalf("Expected the winner's rating above %v, got %+v", rating.Default.Value, me.Me.Rating)
+	}
+
+	// The stored rating replaces the one the player asks for
+	var joined struct {
+		JoinGame struct {
+			Queue struct{ Preferences struct{ SkillRating int } }
+		}
+	}
+	join := fmt.Sprintf(`mutation { joinGame(gameId: %q, preferences: { skillRating: 10 }) { queue { preferences { skillRating } } } }`, f.game.ID)
+	if err := c.Post(join, &joined, asUser(f.player.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	if got, want := joined.JoinGame.Queue.Preferences.SkillRating, int(me.Me.Rating.Rating+0.5); got != want {
+		t.Errorf("Expected skillRating %d from the stored rating, got %d", want, got)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	ctx := context.Background()
+
+	session := &store.Session{GameID: f.game.ID}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	var got struct {
+		Session *struct {
+			Status    string
+			StartedAt *string
+			Game      struct{ ID string }
+		}
+	}
+	query := fmt.Sprintf(`query { session(id: %q) { status startedAt game { id } } }`, session.ID)
+	if err := c.Post(query, &got); err != nil {
+		t.Fatalf("session failed: %v", err)
+	}
+	if got.Session == nil || got.Session.Status != "PENDING" || got.Session.StartedAt != nil || got.Session.Game.ID != f.game.ID {
+		t.Fatalf("Expected a pending session of %s, got %+v", f.game.ID, got.Session)
+	}
+
+	var resp map[string]any
+	start := fmt.Sprintf(`mutation { startSession(id: %q) { status } }`, session.ID)
+	err := c.Post(start, &resp, asGame("other-game", auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's server, got: %v", err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { endSession(id: %q, status: PENDING) { status } }`, session.ID), &resp, asGame(f.game.ID, auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for ending as PENDING, got: %v", err)
+	}
+
+	var started struct {
+		StartSession struct {
+			Status    string
+			StartedAt *string
+		}
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { startSession(id: %q) { status startedAt } }`, session.ID), &started, asGame(f.game.ID, auth.ScopeSessionsWrite)); err != nil {
+		t.Fatalf("startSession failed: %v", err)
+	}
+	if started.StartSession.Status != "ACTIVE" || started.StartSession.StartedAt == nil {
+		t.Errorf("Expected an active session with a start time, got %+v", started.StartSession)
+	}
+	err = c.Post(start, &resp, asGame(f.game.ID, auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for starting an active session, got: %v", err)
+	}
+
+	var ended struct {
+		EndSession struct {
+			Status  string
+			EndedAt *string
+		}
+	}
+	if err := c.Post(fmt.Sprintf(`mutation { endSession(id: %q) { status endedAt } }`, session.ID), &ended, asUser(f.publisher.ID, auth.RolePublisher)); err != nil {
+		t.Fatalf("endSession failed: %v", err)
+	}
+	if ended.EndSession.Status != "COMPLETED" || ended.EndSession.EndedAt == nil {
+		t.Errorf("Expected a completed session with an end time, got %+v", ended.EndSession)
+	}
+
+	got.Session = nil
+	if err := c.Post(`query { session(id: "missing") { status } }`, &got); err != nil || got.Session != nil {
+		t.Errorf("Expected null for an unknown session, got %+v (%v)", got.Session, err)
+	}
+	err = c.Post(`mutation { startSession(id: "missing") { status } }`, &resp, asUser(f.publisher.ID, auth.RoleSupport))
+	if err == nil || !strings.Contains(err.Error(), `"code":"SESSION_NOT_FOUND"`) {
+		t.Errorf("Expected SESSION_NOT_FOUND for an unknown session, got: %v", err)
+	}
+}
+
+func TestReportSessionResultAndMatchHistory(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	ctx := context.Background()
+	server := asGame(f.game.ID, auth.ScopeSessionsWrite)
+
+	session := &store.Session{GameID: f.game.ID, Status: store.SessionActive}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID, f.publisher.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	report := func(players string) string {
+		return fmt.Sprintf(`mutation { reportSessionResult(id: %q, result: { players: [%s], stats: { rounds: 12 } }) {
+			status endedAt result { players { userId placement score stats } stats }
+		} }`, session.ID, players)
+	}
+
+	var resp map[string]any
+	for name, players := range map[string]string{
+		"no players":      ``,
+		"an outsider":     `{ userId: "someone-else", placement: 1 }`,
+		"a duplicate":     fmt.Sprintf(`{ userId: %q, placement: 1 }, { userId: %[1]q, placement: 2 }`, f.player.ID),
+		"a bad placement": fmt.Sprintf(`{ userId: %q, placement: 0 }`, f.player.ID),
+	} {
+		err := c.Post(report(players), &resp, server)
+		if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+			t.Errorf("Expected VALIDATION_ERROR for %s, got: %v", name, err)
+		}
+	}
+	players := fmt.Sprintf(`{ userId: %q, placement: 2, score: 3 }, { userId: %q, placement: 1, score: 7.5, stats: { kills: 4 } }`, f.player.ID, f.publisher.ID)
+	err := c.Post(report(players), &resp, asUser("publisher-2", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's publisher, got: %v", err)
+	}
+
+	var reported struct {
+		ReportSessionResult struct {
+			Status  string
+			EndedAt *string
+			Result  struct {
+				Players []struct {
+					UserID    string
+					Placement int
+					Score     *float64
+					Stats     map[string]any
+				}
+				Stats map[string]any
+			}
+		}
+	}
+	if err := c.Post(report(players), &reported, server); err != nil {
+		t.Fatalf("reportSessionResult failed: %v", err)
+	}
+	got := reported.ReportSessionResult
+	if got.Status != "COMPLETED" || got.EndedAt == nil || got.Result.Stats["rounds"] != float64(12) {
+		t.Errorf("Expected a completed session with its stats, got %+v", got)
+	}
+	if len(got.Result.Players) != 2 || got.Result.Players[0].UserID != f.publisher.ID || got.Result.Players[0].Stats["kills"] != float64(4) {
+		t.Errorf("Expected the winner first, got %+v", got.Result.Players)
+	}
+	err = c.Post(report(players), &resp, server)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for reporting twice, got: %v", err)
+	}
+
+	// Reporting updates both players' ratings
+	winner, err := resolver.Store.Ratings.Get(ctx, f.publisher.ID, f.game.ID)
+	if err != nil || winner.Value <= rating.Default.Value {
+		t.Errorf("Expected the winner's rating to rise, got %+v (%v)", winner, err)
+	}
+	if _, err := resolver.Store.Ratings.Get(ctx, f.player.ID, f.game.ID); err != nil {
+		t.Errorf("Expected the loser to be rated: %v", err)
+	}
+
+	// Sessions still running are not part of the history
+	if err := resolver.Store.Sessions.Create(ctx, &store.Session{GameID: f.game.ID, Status: store.SessionActive}, []string{f.player.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	var history struct {
+		MatchHistory struct {
+			Edges []struct {
+				Node struct {
+					ID     string
+					Game   struct{ ID string }
+					Result *struct {
+						Players []struct{ UserID string }
+					}
+				}
+			}
+		}
+	}
+	query := `query { matchHistory(userId: %q%s) { edges { node { id game { id } result { players { userId } } } } } }`
+	ofGame := fmt.Sprintf(", gameId: %q", f.game.ID)
+	if err := c.Post(fmt.Sprintf(query, f.player.ID, ofGame), &history, asUser(f.player.ID)); err != nil {
+		t.Fatalf("matchHistory failed: %v", err)
+	}
+	edges := history.MatchHistory.Edges
+	if len(edges) != 1 || edges[0].Node.ID != session.ID || edges[0].Node.Game.ID != f.game.ID || edges[0].Node.Result == nil {
+		t.Fatalf("Expected the reported session in the history, got %+v", edges)
+	}
+	history.MatchHistory.Edges = nil
+	if err := c.Post(fmt.Sprintf(query, "someone-else", ""), &history, asUser("support-1", auth.RoleSupport)); err != nil || len(history.MatchHistory.Edges) != 0 {
+		t.Errorf("Expected an empty history for another user, got %+v (%v)", history.MatchHistory.Edges, err)
+	}
+	err = c.Post(fmt.Sprintf(query, f.player.ID, `, gameId: "missing"`), &history, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"GAME_NOT_FOUND"`) {
+		t.Errorf("Expected GAME_NOT_FOUND for an unknown game, got: %v", err)
+	}
+
+	// Others only see the history of games they manage
+	for name, tc := range map[string]struct {
+		gameID string
+		caller client.Option
+		code   string
+	}{
+		"the game's publisher":     {ofGame, asUser(f.publisher.ID, auth.RolePublisher), ""},
+		"the game's server":        {ofGame, server, ""},
+		"support":                  {"", asUser("support-1", auth.RoleSupport), ""},
+		"an anonymous caller":      {ofGame, func(*client.Request) {}, "UNAUTHORIZED"},
+		"another user":             {ofGame, asUser("someone-else"), "FORBIDDEN"},
+		"another game's server":    {ofGame, asGame("other-game", auth.ScopeSessionsWrite), "FORBIDDEN"},
+		"a publisher on all games": {"", asUser(f.publisher.ID, auth.RolePublisher), "FORBIDDEN"},
+	} {
+		err := c.Post(fmt.Sprintf(query, f.player.ID, tc.gameID), &history, tc.caller)
+		if tc.code == "" && err != nil {
+			t.Errorf("Expected %s to read the history, got: %v", name, err)
+		}
+		if tc.code != "" && (err == nil || !strings.Contains(err.Error(), `"code":"`+tc.code+`"`)) {
+			t.Errorf("Expected %s for %s, got: %v", tc.code, name, err)
+		}
+	}
+}
+
+func TestSessionPresence(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	withSigner(t, resolver)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	ctx := context.Background()
+	server := asGame(f.game.ID, auth.ScopeSessionsWrite)
+
+	session := &store.Session{GameID: f.game.ID, Status: store.SessionActive}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID, f.publisher.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	report := func(event, userID string) string {
+		return fmt.Sprintf(`mutation { reportPresence(sessionId: %q, event: %s, userIds: [%q]) {
+			user { id } status lastSeenAt leftAt
+		} }`, session.ID, event, userID)
+	}
+	type player struct {
+		User       struct{ ID string }
+		Status     string
+		LastSeenAt *string
+		LeftAt     *string
+	}
+	var reported struct{ ReportPresence []player }
+	status := func(event string) string {
+		t.Helper()
+		reported.ReportPresence = nil
+		if err := c.Post(report(event, f.player.ID), &reported, server); err != nil {
+			t.Fatalf("reportPresence %s failed: %v", event, err)
+		}
+		if len(reported.ReportPresence) != 1 || reported.ReportPresence[0].User.ID != f.player.ID {
+			t.Fatalf("Expected only the reported player, got %+v", reported.ReportPresence)
+		}
+		return reported.ReportPresence[0].Status
+	}
+
+	if s := status("JOIN"); s != "CONNECTED" || reported.ReportPresence[0].LastSeenAt == nil {
+		t.Errorf("Expected a connected player after JOIN, got %+v", reported.ReportPresence[0])
+	}
+	if s := status("HEARTBEAT"); s != "CONNECTED" {
+		t.Errorf("Expected a heartbeat to keep the player connected, got %s", s)
+	}
+	if s := status("LEAVE"); s != "LEFT" || reported.ReportPresence[0].LeftAt == nil {
+		t.Errorf("Expected the player to have left, got %+v", reported.ReportPresence[0])
+	}
+	if s := status("HEARTBEAT"); s != "LEFT" {
+		t.Errorf("Expected a late heartbeat to leave the player out, got %s", s)
+	}
+
+	var resp map[string]any
+	err := c.Post(report("JOIN", "someone-else"), &resp, server)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for a user outside the session, got: %v", err)
+	}
+	err = c.Post(report("JOIN", f.player.ID), &resp, asGame("other-game", auth.ScopeSessionsWrite))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's server, got: %v", err)
+	}
+	if err := c.Post(report("HEARTBEAT", f.player.ID), &resp, asUser(f.publisher.ID, auth.RolePublisher)); err != nil {
+		t.Errorf("Expected the game's publisher to report presence, got: %v", err)
+	}
+	err = c.Post(report("HEARTBEAT", f.player.ID), &resp, asUser("publisher-2", auth.RolePublisher))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for another game's publisher, got: %v", err)
+	}
+
+	// A player who dropped out gets a new ticket for the running session
+	var rejoined struct {
+		RejoinSession struct {
+			SessionID *string
+			Ticket    *string
+		}
+	}
+	rejoin := fmt.Sprintf(`mutation { rejoinSession(sessionId: %q) { sessionId ticket } }`, session.ID)
+	if err := c.Post(rejoin, &rejoined, asUser(f.player.ID)); err != nil {
+		t.Fatalf("rejoinSession failed: %v", err)
+	}
+	if rejoined.RejoinSession.SessionID == nil || *rejoined.RejoinSession.SessionID != session.ID || rejoined.RejoinSession.Ticket == nil {
+		t.Errorf("Expected a ticket for the session, got %+v", rejoined.RejoinSession)
+	}
+	err = c.Post(rejoin, &resp, asUser("someone-else"))
+	if err == nil || !strings.Contains(err.Error(), `"code":"FORBIDDEN"`) {
+		t.Errorf("Expected FORBIDDEN for a user outside the session, got: %v", err)
+	}
+
+	// Ending the session marks everyone left
+	if _, err := resolver.Store.Sessions.Transition(ctx, session.ID, store.SessionCompleted); err != nil {
+		t.Fatalf("Failed to end session: %v", err)
+	}
+	var got struct {
+		Session struct{ Players []player }
+	}
+	if err := c.Post(fmt.Sprintf(`query { session(id: %q) { players { user { id } status } } }`, session.ID), &got); err != nil {
+		t.Fatalf("session failed: %v", err)
+	}
+	for _, p := range got.Session.Players {
+		if p.Status != "LEFT" {
+			t.Errorf("Expected %s to have left the ended session, got %s", p.User.ID, p.Status)
+		}
+	}
+	err = c.Post(report("JOIN", f.player.ID), &resp, server)
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for an ended session, got: %v", err)
+	}
+	err = c.Post(rejoin, &resp, asUser(f.player.ID))
+	if err == nil || !strings.Contains(err.Error(), `"code":"VALIDATION_ERROR"`) {
+		t.Errorf("Expected VALIDATION_ERROR for rejoining an ended session, got: %v", err)
+	}
+}
+
+func TestBackfillSession(t *testing.T) {
+	resolver, f := newTestResolver(t)
+	withSigner(t, resolver)
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(NewConfig(resolver)))
+	c := client.New(srv)
+	ctx := context.Background()
+
+	var updated struct{ UpdateGame struct{ Backfill bool } }
+	err := c.Post(fmt.Sprintf(`mutation { updateGame(id: %q, input: { maxPlayers: 2, backfill: true }) { backfill } }`, f.game.ID),
+		&updated, asUser(f.publisher.ID, auth.RolePublisher))
+	if err != nil || !updated.UpdateGame.Backfill {
+		t.Fatalf("Expected the game to allow backfill, got %+v (%v)", updated, err)
+	}
+
+	session := &store.Session{GameID: f.game.ID, Status: store.SessionActive}
+	if err := resolver.Store.Sessions.Create(ctx, session, []string{f.player.ID, f.publisher.ID}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	openSlots := func() int {
+		t.Helper()
+		var got struct{ Session struct{ OpenSlots int } }
+		if err := c.Post(fmt.Sprintf(`query { session(id: %q) { openSlots } }`, session.ID), &got); err != nil {
+			t.Fatalf("session failed: %v", err)
+		}
+		return got.Session.OpenSlots
+	}
+	if n := openSlots(); n != 0 {
+		t.Errorf("Expected a full session, got %d open slots", n)
+	}
+	server := asGame(f.game.ID, auth.ScopeSessionsWrite)
+	leave := fmt.Sprintf(`mutation { reportPresence(sessionId: %q, event: LEAVE, userIds: [%q]) { status } }`, session.ID, f.publisher.ID)
+	var resp map[string]any
+	if err := c.Post(leave, &resp, server); err != nil {
+		t.Fatalf("reportPresence failed: %v", err)
+	}
+	if n := openSlots(); n != 1 {
+		t.Errorf("Expected one open slot after a player left, got %d", n)
+	}
+
+	// A queued player takes the open slot and joins the running session
+	newcomer := &store.User{Email: "newcomer@example.com", Username: "newcomer"}
+	if err := resolver.Store.Users.Create(ctx, newcomer); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	var joined struct {
+		JoinGame struct {
+			Queued    bool
+			SessionID *string
+			Ticket    *string
+		}
+	}
+	join := fmt.Sprintf(`mutation { joinGame(gameId: %q) { queued sessionId ticket } }`, f.game.ID)
+	if err := c.Post(join, &joined, asUser(newcomer.ID)); err != nil || !joined.JoinGame.Queued {
+		t.Fatalf("Expected the newcomer to be queued, got %+v (%v)", joined.JoinGame, err)
+	}
+	if n, err := matchmaker.New(resolver.Store, notify.NewHub()).Run(ctx); err != nil || n != 0 {
+		t.Fatalf("Run = %d, %v; expected the slot to be backfilled instead of a new session", n, err)
+	}
+	if err := c.Post(join, &joined, asUser(newcomer.ID)); err != nil {
+		t.Fatalf("joinGame failed: %v", err)
+	}
+	if got := joined.JoinGame; got.Queued || got.SessionID == nil || *got.SessionID != session.ID || got.Ticket == nil {
+		t.Errorf("Expected a ticket for the existing session, got %+v", got)
+	}
+	if n := openSlots(); n != 0 {
+		t.Errorf("Expected the session to be full again, got %d open slots", n)
+	}
+
+	// The player who left no longer has a place to come back to
+	rejoin := fmt.Sprintf(`mutation { rejoinSession(sessionId: %q) { sessionId } }`, session.ID)
+	err = c.Post(rejoin, &resp, asUser(f.publisher.ID))
+	if err == nil || !strings.Contains(err.Error(), "the session is full") {
+		t.Errorf("Expected rejoining a full session to fail, got: %v", err)
+	}
+	err = c.Post(fmt.Sprintf(`mutation { reportPresence(sessionId: %q, event: JOIN, userIds: [%q]) { status } }`, session.ID, f.publisher.ID), &resp, server)
+	if err == nil || !strings.Contains(err.Error(), "the session is full") {
+		t.Errorf("Expected a join beyond the open slots to fail, got: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// loadSession loads sessionID with its game, failing with SESSION_NOT_FOUND
+// when it does not exist
+func (r *Resolver) loadSession(ctx context.Context, sessionID, op string) (*store.Session, *store.Game, error) {
+	session, err := r.Store.Sessions.Get(ctx, sessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, newError(ctx, codeSessionNotFound, "session not found")
+	}
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, nil, errors.New("failed to load session")
+	}
+	game, err := r.loadGame(ctx, session.GameID, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, game, nil
+}
+
+// transitionSession moves sessionID to status to on behalf of a caller who
+// manages its game
+func (r *Resolver) transitionSession(ctx context.Context, sessionID, to, op string) (*model.Session, error) {
+	session, game, err := r.loadSession(ctx, sessionID, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameManager(ctx, game.ID, game.OwnerID); err != nil {
+		return nil, err
+	}
+
+	updated, err := r.Store.Sessions.Transition(ctx, session.ID, to)
+	if err != nil {
+		return nil, sessionUpdateError(ctx, err, session, to, op)
+	}
+	return toModelSession(updated, toModelGame(game)), nil
+}
+
+// sessionUpdateError maps a failure to move session to status to onto a
+// GraphQL error
+func sessionUpdateError(ctx context.Context, err error, session *store.Session, to, op string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return newError(ctx, codeSessionNotFound, "session not found")
+	}
+	if errors.Is(err, store.ErrInvalidTransition) {
+		return newError(ctx, codeValidationError, fmt.Sprintf("a %s session cannot become %s", session.Status, to))
+	}
+	log.Printf("%s: %v", op, err)
+	return errors.New("failed to update session")
+}
+
+// maxResultBytes bounds the encoded size of a reported session result
+const maxResultBytes = 64 << 10
+
+// maxPresenceBatch caps the players of one presence report
+const maxPresenceBatch = 100
+
+// sessionResult validates a reported result against the participants of
+// sessionID and orders its players by placement
+func (r *Resolver) sessionResult(ctx context.Context, sessionID string, input model.SessionResultInput) (*store.SessionResult, error) {
+	if len(input.Players) == 0 {
+		return nil, newError(ctx, codeValidationError, "result must list at least one player")
+	}
+	participants, err := r.loadParticipants(ctx, sessionID, "reportSessionResult")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &store.SessionResult{Players: make([]store.PlayerResult, len(input.Players)), Stats: input.Stats}
+	seen := make(map[string]bool, len(input.Players))
+	for i, p := range input.Players {
+		switch {
+		case findParticipant(participants, p.UserID) == nil:
+			return nil, errNotParticipant(ctx, p.UserID)
+		case seen[p.UserID]:
+			return nil, newError(ctx, codeValidationError, fmt.Sprintf("user %s is listed more than once", p.UserID))
+		case p.Placement < 1 || p.Placement > len(input.Players):
+			return nil, newError(ctx, codeValidationError, fmt.Sprintf("placement must be 1-%d", len(input.Players)))
+		}
+		seen[p.UserID] = true
+		result.Players[i] = store.PlayerResult{UserID: p.UserID, Placement: p.Placement, Score: p.Score, Stats: p.Stats}
+	}
+	slices.SortStableFunc(result.Players, func(a, b store.PlayerResult) int { return a.Placement - b.Placement })
+
+	if b, err := json.Marshal(result); err != nil || len(b) > maxResultBytes {
+		return nil, newError(ctx, codeValidationError, fmt.Sprintf("result must encode to at most %d bytes of JSON", maxResultBytes))
+	}
+	return result, nil
+}
+
+// joinSession issues userID a join ticket for the session sessionID of game
+func (r *Resolver) joinSession(ctx context.Context, game *store.Game, sessionID, userID, op string) (*model.JoinResult, error) {
+	if r.Signer == nil {
+		log.Printf("%s: no signing key configured for join tickets", op)
+		return nil, errors.New("failed to issue join ticket")
+	}
+	ticket, expiresAt, err := r.Signer.IssueJoinTicket(sessionID, userID, game.ID)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, errors.New("failed to issue join ticket")
+	}
+	return &model.JoinResult{
+		Queued:          false,
+		SessionID:       &sessionID,
+		Ticket:          &ticket,
+		TicketExpiresAt: &expiresAt,
+		JoinURL:         optionalString(game.JoinURL(sessionID, ticket)),
+	}, nil
+}
+
+// loadParticipants loads the players of sessionID in joining order
+func (r *Resolver) loadParticipants(ctx context.Context, sessionID, op string) ([]*store.Participant, error) {
+	participants, err := r.Store.Sessions.Participants(ctx, sessionID)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return nil, errors.New("failed to load players")
+	}
+	return participants, nil
+}
+
+// findParticipant returns the participant with userID, or nil
+func findParticipant(participants []*store.Participant, userID string) *store.Participant {
+	for _, p := range participants {
+		if p.User.ID == userID {
+			return p
+		}
+	}
+	return nil
+}
+
+// errNotParticipant is returned when a game server names a user who was not
+// placed in the session
+func errNotParticipant(ctx context.Context, userID string) error {
+	return newError(ctx, codeValidationError, fmt.Sprintf("user %s did not take part in the session", userID))
+}
+
+// errSessionFull is returned when a player who left cannot come back because
+// others took every open slot
+func errSessionFull(ctx context.Context) error {
+	return newError(ctx, codeValidationError, "the session is full")
+}
@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// issueSessionCookies mints an access token for user bound to session and sets
+// it, together with the session's refresh token, as the auth cookies of the
+// current response. The user's roles are loaded so they end up in the token,
+// and returned.
+func (r *Resolver) issueSessionCookies(ctx context.Context, user *store.User, session *store.AuthSession, refreshToken string) ([]auth.Role, error) {
+	var roles []auth.Role
+	if r.Roles != nil {
+		var err error
+		if roles, err = r.Roles.ForUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	accessToken, expiresAt, err := r.Signer.IssueAccessToken(user, roles, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	auth.SetAccessCookie(ctx, accessToken, expiresAt)
+	auth.SetRefreshCookie(ctx, refreshToken, session.ExpiresAt)
+	return roles, nil
+}
@@ -0,0 +1,33 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/scruffyprodigy/playhub/graph/generated"
+	"github.com/scruffyprodigy/playhub/graph/model"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// Rating is the resolver for the rating field.
+func (r *userResolver) Rating(ctx context.Context, obj *model.User, gameID string) (*model.Rating, error) {
+	rating, err := r.Store.Ratings.Get(ctx, obj.ID, gameID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("rating: %v", err)
+		return nil, errors.New("failed to load rating")
+	}
+	return toModelRating(rating), nil
+}
+
+// User returns generated.UserResolver implementation.
+func (r *Resolver) User() generated.UserResolver { return &userResolver{r} }
+
+type userResolver struct{ *Resolver }
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// APIKeyPrefix starts every game API key so that it can be told apart from a JWT
+const APIKeyPrefix = "phk_"
+
+// Scopes a game API key can be granted
+const (
+	ScopeGoodsWrite    = "goods:write"    // grant and revoke goods of the game
+	ScopeSessionsWrite = "sessions:write" // report session lifecycle and results
+)
+
+var (
+	// ErrInvalidAPIKey is returned for unknown or revoked API keys
+	ErrInvalidAPIKey = errors.New("invalid or revoked API key")
+	// ErrAPIKeyNotFound is returned when no API key has the requested id
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyAuthenticator resolves a raw API key to the key it belongs to
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, rawKey string) (*store.APIKey, error)
+}
+
+// APIKeys issues and checks game API keys. Only the SHA-256 hash of a key is
+// persisted.
+type APIKeys struct {
+	keys store.APIKeys
+}
+
+// NewAPIKeys creates an API key service over keys
+func NewAPIKeys(keys store.APIKeys) *APIKeys {
+	return &APIKeys{keys: keys}
+}
+
+// ValidScope reports whether scope is a known API key scope
+func ValidScope(scope string) bool {
+	return scope == ScopeGoodsWrite || scope == ScopeSessionsWrite
+}
+
+// IsAPIKey reports whether token looks like a game API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Create issues a key for gameID and returns it together with the raw key
+func (s *APIKeys) Create(ctx context.Context, gameID, name string, scopes []string, createdBy string) (*store.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	secret, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := APIKeyPrefix + secret
+
+	key := &store.APIKey{GameID: gameID, Name: name, Prefix: raw[:len(APIKeyPrefix)+8], Scopes: scopes}
+	if err := s.keys.Create(ctx, key, HashToken(raw), createdBy); err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+// Get returns the key with the given id. It fails with ErrAPIKeyNotFound when
+// there is none.
+func (s *APIKeys) Get(ctx context.Context, id string) (*store.APIKey, error) {
+	key, err := s.keys.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// List returns the keys of gameID, newest first, including revoked ones
+func (s *APIKeys) List(ctx context.Context, gameID string) ([]*store.APIKey, error) {
+	return s.keys.List(ctx, gameID)
+}
+
+// Revoke disables the key with the given id. It fails with ErrAPIKeyNotFound
+// when there is none.
+func (s *APIKeys) Revoke(ctx context.Context, id string) error {
+	err := s.keys.Revoke(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// Authenticate implements APIKeyAuthenticator and records the key's use
+func (s *APIKeys) Authenticate(ctx context.Context, rawKey string) (*store.APIKey, error) {
+	if !IsAPIKey(rawKey) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.keys.Authenticate(ctx, HashToken(rawKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	return key, err
+}
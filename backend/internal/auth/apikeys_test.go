@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+type fakeAPIKeys map[string]*store.APIKey
+
+func (f fakeAPIKeys) Authenticate(_ context.Context, rawKey string) (*store.APIKey, error) {
+	if k, ok := f[rawKey]; ok {
+		return k, nil
+	}
+	return nil, ErrInvalidAPIKey
+}
+
+func TestMiddlewareAPIKey(t *testing.T) {
+	keys := fakeAPIKeys{"phk_valid": {ID: "key-1", GameID: "game-1", Scopes: []string{ScopeGoodsWrite}}}
+
+	var got *Principal
+	h := Middleware(nil, nil, keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	serve := func(header, value string) int {
+		got = nil
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct{ header, value string }{
+		{"Authorization", "Bearer phk_valid"},
+		{"X-API-Key", "phk_valid"},
+	} {
+		if code := serve(tc.header, tc.value); code != http.StatusOK || got == nil {
+			t.Fatalf("%s: code %d, principal %+v", tc.header, code, got)
+		}
+		if got.Kind != PrincipalGame || got.GameID != "game-1" || !got.HasScope(ScopeGoodsWrite) || got.HasScope(ScopeSessionsWrite) {
+			t.Errorf("%s: unexpected principal %+v", tc.header, got)
+		}
+		if got.HasRole(RolePlayer) {
+			t.Errorf("%s: expected game principal to hold no user roles", tc.header)
+		}
+	}
+
+	if code := serve("X-API-Key", "phk_revoked"); code != http.StatusUnauthorized || got != nil {
+		t.Errorf("Revoked key: code %d, principal %+v", code, got)
+	}
+}
+
+func TestMiddlewareAPIKeyWithoutStore(t *testing.T) {
+	h := Middleware(nil, nil, nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("X-API-Key", "phk_anything")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without API key store, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// RefreshCookieName is the cookie that carries the refresh token. It is scoped
+// to the GraphQL endpoint so it is not sent with other requests.
+const RefreshCookieName = "playhub_refresh"
+
+const refreshCookiePath = "/graphql"
+
+type httpContextKey struct{}
+
+// httpContext gives resolvers further down the chain access to the request
+// cookies and lets them set cookies on the response
+type httpContext struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+func withHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	return context.WithValue(ctx, httpContextKey{}, &httpContext{w: w, r: r})
+}
+
+func httpFromContext(ctx context.Context) (*httpContext, bool) {
+	h, ok := ctx.Value(httpContextKey{}).(*httpContext)
+	return h, ok
+}
+
+// secureCookies reports whether auth cookies are marked Secure. It defaults to
+// true and can be disabled with AUTH_COOKIE_SECURE=false for plain-HTTP setups.
+func secureCookies() bool {
+	return os.Getenv("AUTH_COOKIE_SECURE") != "false"
+}
+
+func setCookie(ctx context.Context, name, value, path string, expiresAt time.Time) bool {
+	h, ok := httpFromContext(ctx)
+	if !ok {
+		return false
+	}
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	http.SetCookie(h.w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return true
+}
+
+// SetAccessCookie stores token in the HttpOnly access cookie of the current
+// response. It reports false when ctx is not bound to an HTTP response.
+func SetAccessCookie(ctx context.Context, token string, expiresAt time.Time) bool {
+	return setCookie(ctx, AccessCookieName, token, "/", expiresAt)
+}
+
+// SetRefreshCookie stores token in the HttpOnly refresh cookie of the current
+// response. It reports false when ctx is not bound to an HTTP response.
+func SetRefreshCookie(ctx context.Context, token string, expiresAt time.Time) bool {
+	return setCookie(ctx, RefreshCookieName, token, refreshCookiePath, expiresAt)
+}
+
+// clearAccessCookie expires the access cookie, leaving the refresh cookie
+func clearAccessCookie(ctx context.Context) {
+	setCookie(ctx, AccessCookieName, "", "/", time.Unix(0, 0))
+}
+
+// ClearAuthCookies expires the access and refresh cookies
+func ClearAuthCookies(ctx context.Context) {
+	setCookie(ctx, AccessCookieName, "", "/", time.Unix(0, 0))
+	setCookie(ctx, RefreshCookieName, "", refreshCookiePath, time.Unix(0, 0))
+}
+
+// RefreshTokenFromContext returns the refresh cookie sent with the current request
+func RefreshTokenFromContext(ctx context.Context) string {
+	h, ok := httpFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	c, err := h.r.Cookie(RefreshCookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// ClientInfo returns the user agent and IP address of the current request's
+// client; see TrustProxies
+func ClientInfo(ctx context.Context) (userAgent, ip string) {
+	h, ok := httpFromContext(ctx)
+	if !ok {
+		return "", ""
+	}
+	return h.r.UserAgent(), clientIP(h.r)
+}
+
+type trustedProxiesKey struct{}
+
+// TrustProxies lets ClientInfo read X-Forwarded-For from requests that come
+// from one of proxies. The client is then the rightmost hop that is not a
+// trusted proxy, since clients can put anything in the hops before it.
+// Without trusted proxies only the remote address counts.
+func TrustProxies(proxies []netip.Prefix, next http.Handler) http.Handler {
+	if len(proxies) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), trustedProxiesKey{}, proxies)))
+	})
+}
+
+// TrustedProxiesFromEnv reads TRUSTED_PROXIES, a comma-separated list of the
+// addresses or CIDR ranges of the proxies in front of the server
+func TrustedProxiesFromEnv() ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, v := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", v)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// clientIP returns the remote address of r, or the rightmost X-Forwarded-For
+// hop that is not a trusted proxy when the request came through one
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+
+	proxies, _ := r.Context().Value(trustedProxiesKey{}).([]netip.Prefix)
+	if !trusted(proxies, addr) {
+		return addr.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Nothing left of a malformed hop can be trusted
+			break
+		}
+		addr = hop.Unmap()
+		if !trusted(proxies, addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// trusted reports whether addr belongs to one of proxies
+func trusted(proxies []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
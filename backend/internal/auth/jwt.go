@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultIssuer and DefaultAudience are used when JWT_ISSUER / JWT_AUDIENCE are unset
+const (
+	DefaultIssuer   = "playhub"
+	DefaultAudience = "playhub"
+)
+
+// defaultLeeway tolerates small clock differences between issuer and verifier
+const defaultLeeway = 30 * time.Second
+
+var (
+	// ErrMalformedToken is returned for tokens that are not a well-formed JWS
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrUnknownKey is returned when the token's kid is not in the verifier's key set
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrBadSignature is returned when the token signature does not verify
+	ErrBadSignature = errors.New("invalid token signature")
+	// ErrTokenExpired is returned for tokens past their exp claim
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotYetValid is returned for tokens before their nbf claim
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+	// ErrInvalidClaims is returned when iss, aud or sub do not match expectations
+	ErrInvalidClaims = errors.New("invalid token claims")
+)
+
+// Audience is the JWT aud claim, which may be encoded as a string or an array
+type Audience []string
+
+// UnmarshalJSON accepts both the string and array forms of aud
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Contains reports whether aud includes want
+func (a Audience) Contains(want string) bool {
+	for _, v := range a {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the JWT claims PlayHub issues and accepts
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+
+	// Join tickets name the game session and its game
+	GameSessionID string `json:"game_session_id,omitempty"`
+	GameID        string `json:"game_id,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// PublicKeys resolves the kid in a token header to a verification key
+type PublicKeys interface {
+	PublicKey(kid string) (ed25519.PublicKey, bool)
+}
+
+// StaticKeys is a fixed kid to public key mapping
+type StaticKeys map[string]ed25519.PublicKey
+
+// PublicKey implements PublicKeys
+func (s StaticKeys) PublicKey(kid string) (ed25519.PublicKey, bool) {
+	k, ok := s[kid]
+	return k, ok
+}
+
+// Verifier checks EdDSA-signed JWTs against a set of Ed25519 public keys
+type Verifier struct {
+	keys     PublicKeys
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewVerifier creates a verifier for tokens from issuer intended for audience
+func NewVerifier(keys PublicKeys, issuer, audience string) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   defaultLeeway,
+		now:      time.Now,
+	}
+}
+
+// ParsePublicKey decodes a base64url Ed25519 public key as published in a JWK "x" member
+func ParsePublicKey(x string) (ed25519.PublicKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(x, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length %d", len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Verify checks the token's signature and its exp, nbf, iss and aud claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if header.Alg != "EdDSA" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrMalformedToken, header.Alg)
+	}
+
+	key, ok := v.keys.PublicKey(header.Kid)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrBadSignature
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(v.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenNotYetValid
+	}
+	if claims.Issuer != v.issuer || !claims.Audience.Contains(v.audience) || claims.Subject == "" {
+		return nil, ErrInvalidClaims
+	}
+
+	return &claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
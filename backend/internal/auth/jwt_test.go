@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return pub, priv
+}
+
+// signTestToken builds a compact JWS with an arbitrary header and claims
+func signTestToken(t *testing.T, priv ed25519.PrivateKey, header map[string]string, claims any) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(input)))
+}
+
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		Issuer:    DefaultIssuer,
+		Subject:   "11111111-1111-1111-1111-111111111111",
+		Audience:  Audience{DefaultAudience},
+		ExpiresAt: now.Add(5 * time.Minute).Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        "token-1",
+		Email:     "player@example.com",
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv := newTestKey(t)
+	_, otherPriv := newTestKey(t)
+	v := NewVerifier(StaticKeys{"k1": pub}, DefaultIssuer, DefaultAudience)
+	header := map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": "k1"}
+
+	claims, err := v.Verify(signTestToken(t, priv, header, validClaims()))
+	if err != nil {
+		t.Fatalf("Expected valid token to verify, got %v", err)
+	}
+	if claims.Subject != validClaims().Subject || claims.Email != "player@example.com" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	expired := validClaims()
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	future := validClaims()
+	future.NotBefore = time.Now().Add(time.Hour).Unix()
+	wrongIss := validClaims()
+	wrongIss.Issuer = "someone-else"
+	wrongAud := validClaims()
+	wrongAud.Audience = Audience{"another-service"}
+
+	cases := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed", "not.a-token", ErrMalformedToken},
+		{"wrong alg", signTestToken(t, priv, map[string]string{"alg": "HS256", "kid": "k1"}, validClaims()), ErrMalformedToken},
+		{"unknown kid", signTestToken(t, priv, map[string]string{"alg": "EdDSA", "kid": "k2"}, validClaims()), ErrUnknownKey},
+		{"wrong key", signTestToken(t, otherPriv, header, validClaims()), ErrBadSignature},
+		{"expired", signTestToken(t, priv, header, expired), ErrTokenExpired},
+		{"not yet valid", signTestToken(t, priv, header, future), ErrTokenNotYetValid},
+		{"wrong issuer", signTestToken(t, priv, header, wrongIss), ErrInvalidClaims},
+		{"wrong audience", signTestToken(t, priv, header, wrongAud), ErrInvalidClaims},
+	}
+	for _, tc := range cases {
+		if _, err := v.Verify(tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestAudienceArray(t *testing.T) {
+	pub, priv := newTestKey(t)
+	v := NewVerifier(StaticKeys{"k1": pub}, DefaultIssuer, DefaultAudience)
+
+	claims := map[string]any{
+		"iss": DefaultIssuer,
+		"sub": "user-1",
+		"aud": []string{"other", DefaultAudience},
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	if _, err := v.Verify(signTestToken(t, priv, map[string]string{"alg": "EdDSA", "kid": "k1"}, claims)); err != nil {
+		t.Errorf("Expected aud array to be accepted, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	pub, priv := newTestKey(t)
+	v := NewVerifier(StaticKeys{"k1": pub}, DefaultIssuer, DefaultAudience)
+	token := signTestToken(t, priv, map[string]string{"alg": "EdDSA", "kid": "k1"}, validClaims())
+
+	var got *Principal
+	h := Middleware(v, nil, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	serve := func(r *http.Request) int {
+		got = nil
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Anonymous requests pass through without a principal
+	if code := serve(httptest.NewRequest(http.MethodPost, "/graphql", nil)); code != http.StatusOK || got != nil {
+		t.Errorf("Anonymous request: code %d, principal %+v", code, got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if code := serve(req); code != http.StatusOK || got == nil || got.UserID != validClaims().Subject || got.Kind != PrincipalUser {
+		t.Errorf("Bearer request: code %d, principal %+v", code, got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.AddCookie(&http.Cookie{Name: AccessCookieName, Value: token})
+	if code := serve(req); code != http.StatusOK || got == nil || got.Email != "player@example.com" {
+		t.Errorf("Cookie request: code %d, principal %+v", code, got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+token+"x")
+	if code := serve(req); code != http.StatusUnauthorized || got != nil {
+		t.Errorf("Tampered request: code %d, principal %+v", code, got)
+	}
+
+	// An unusable cookie is ignored rather than failing the request
+	req = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.AddCookie(&http.Cookie{Name: AccessCookieName, Value: token + "x"})
+	if code := serve(req); code != http.StatusOK || got != nil {
+		t.Errorf("Tampered cookie: code %d, principal %+v", code, got)
+	}
+
+	// Without a configured verifier any presented token is rejected
+	h = Middleware(nil, nil, nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without verifier, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultRetiredGrace is how long a retired key keeps verifying tokens. It must
+// exceed the lifetime of anything signed with the key.
+const DefaultRetiredGrace = 24 * time.Hour
+
+// KeyState is where a signing key is in its rotation lifecycle
+type KeyState string
+
+const (
+	// KeyActive signs new tokens. A key set has exactly one active key.
+	KeyActive KeyState = "active"
+	// KeyNext is published ahead of promotion so JWKS caches learn it early
+	KeyNext KeyState = "next"
+	// KeyRetired no longer signs but verifies until its grace period ends
+	KeyRetired KeyState = "retired"
+)
+
+// Key is one Ed25519 key in a KeySet
+type Key struct {
+	KID       string
+	State     KeyState
+	Public    ed25519.PublicKey
+	Private   ed25519.PrivateKey
+	CreatedAt time.Time
+	RetiredAt time.Time
+}
+
+// PublicX returns the base64url public key as published in a JWK "x" member
+func (k *Key) PublicX() string {
+	return base64.RawURLEncoding.EncodeToString(k.Public)
+}
+
+// PrivatePEM returns the private key as PKCS#8 PEM
+func (k *Key) PrivatePEM() (string, error) {
+	if k.Private == nil {
+		return "", fmt.Errorf("key %q has no private key", k.KID)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", fmt.Errorf("marshal key %q: %w", k.KID, err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// keyJSON is the on-disk form of a Key
+type keyJSON struct {
+	KID       string     `json:"kid"`
+	State     KeyState   `json:"state"`
+	PubX      string     `json:"pub_x"`
+	PrivPEM   string     `json:"priv_pem,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+// KeySet is the collection of signing keys PlayHub publishes in its JWKS
+type KeySet struct {
+	keys  []*Key
+	grace time.Duration
+	now   func() time.Time
+}
+
+// NewKeySet validates keys and returns a key set. grace is how long retired
+// keys remain verifiable.
+func NewKeySet(keys []*Key, grace time.Duration) (*KeySet, error) {
+	seen := make(map[string]bool)
+	active := 0
+	for _, k := range keys {
+		if k.KID == "" {
+			return nil, errors.New("key without kid")
+		}
+		if seen[k.KID] {
+			return nil, fmt.Errorf("duplicate kid %q", k.KID)
+		}
+		seen[k.KID] = true
+
+		switch k.State {
+		case KeyActive:
+			active++
+			if k.Private == nil {
+				return nil, fmt.Errorf("active key %q has no private key", k.KID)
+			}
+		case KeyNext, KeyRetired:
+		default:
+			return nil, fmt.Errorf("key %q has unknown state %q", k.KID, k.State)
+		}
+		if k.Private != nil && !k.Public.Equal(k.Private.Public()) {
+			return nil, fmt.Errorf("key %q public and private halves do not match", k.KID)
+		}
+	}
+	if active != 1 {
+		return nil, fmt.Errorf("key set must have exactly one active key, found %d", active)
+	}
+
+	return &KeySet{keys: keys, grace: grace, now: time.Now}, nil
+}
+
+// GenerateKey creates a new Ed25519 key in the given state
+func GenerateKey(kid string, state KeyState) (*Key, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("keygen error: %w", err)
+	}
+	return &Key{KID: kid, State: state, Public: pub, Private: priv, CreatedAt: time.Now().UTC()}, nil
+}
+
+// ParseKeySet decodes a key set document of the form {"keys": [...]}
+func ParseKeySet(data []byte, grace time.Duration) (*KeySet, error) {
+	keys, err := parseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(keys, grace)
+}
+
+// LoadKeySetDir reads every *.json key set document in dir, such as a mounted
+// Kubernetes Secret, and merges them into one set
+func LoadKeySetDir(dir string, grace time.Duration) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no key set files in %s", dir)
+	}
+	sort.Strings(files)
+
+	var keys []*Key
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f, err)
+		}
+		fileKeys, err := parseKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return NewKeySet(keys, grace)
+}
+
+// KeySetFromEnv loads the key set from JWKS_DIR or JWKS_KEYSET, falling back to
+// a single active key from JWKS_KID, JWKS_PUB_X and JWKS_PRIV_PEM. Retired key
+// grace is read from JWKS_RETIRED_GRACE. It returns nil when nothing is
+// configured.
+func KeySetFromEnv() (*KeySet, error) {
+	grace := DefaultRetiredGrace
+	if v := os.Getenv("JWKS_RETIRED_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS_RETIRED_GRACE: %w", err)
+		}
+		grace = d
+	}
+
+	if dir := os.Getenv("JWKS_DIR"); dir != "" {
+		return LoadKeySetDir(dir, grace)
+	}
+	if doc := os.Getenv("JWKS_KEYSET"); doc != "" {
+		ks, err := ParseKeySet([]byte(doc), grace)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS_KEYSET: %w", err)
+		}
+		return ks, nil
+	}
+
+	privPEM := os.Getenv("JWKS_PRIV_PEM")
+	if privPEM == "" {
+		return nil, nil
+	}
+	priv, err := ParsePrivateKeyPEM([]byte(privPEM))
+	if err != nil {
+		return nil, fmt.Errorf("JWKS_PRIV_PEM: %w", err)
+	}
+	key := &Key{KID: os.Getenv("JWKS_KID"), State: KeyActive, Public: priv.Public().(ed25519.PublicKey), Private: priv}
+	if x := os.Getenv("JWKS_PUB_X"); x != "" {
+		pub, err := ParsePublicKey(x)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS_PUB_X: %w", err)
+		}
+		if !pub.Equal(key.Public) {
+			return nil, errors.New("JWKS_PUB_X does not match JWKS_PRIV_PEM")
+		}
+	}
+	if key.KID == "" {
+		return nil, errors.New("JWKS_KID is required with JWKS_PRIV_PEM")
+	}
+	return NewKeySet([]*Key{key}, grace)
+}
+
+// Active returns the key that signs new tokens
+func (ks *KeySet) Active() *Key {
+	for _, k := range ks.keys {
+		if k.State == KeyActive {
+			return k
+		}
+	}
+	return nil
+}
+
+// Keys returns every key in the set, including expired retired keys
+func (ks *KeySet) Keys() []*Key {
+	return ks.keys
+}
+
+// verifiable reports whether tokens signed by k are still accepted
+func (ks *KeySet) verifiable(k *Key) bool {
+	if k.State != KeyRetired || k.RetiredAt.IsZero() {
+		return true
+	}
+	return ks.now().Before(k.RetiredAt.Add(ks.grace))
+}
+
+// PublicKey implements PublicKeys. Retired keys past their grace period are
+// not returned.
+func (ks *KeySet) PublicKey(kid string) (ed25519.PublicKey, bool) {
+	for _, k := range ks.keys {
+		if k.KID == kid && ks.verifiable(k) {
+			return k.Public, true
+		}
+	}
+	return nil, false
+}
+
+// JWK is a public key in JSON Web Key form
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+}
+
+// JWKS returns the public keys that still verify tokens, active key first
+func (ks *KeySet) JWKS() []JWK {
+	jwks := []JWK{}
+	for _, state := range []KeyState{KeyActive, KeyNext, KeyRetired} {
+		for _, k := range ks.keys {
+			if k.State == state && ks.verifiable(k) {
+				jwks = append(jwks, JWK{
+					Kty: "OKP", Crv: "Ed25519", Use: "sig", Alg: "EdDSA",
+					Kid: k.KID,
+					X:   k.PublicX(),
+				})
+			}
+		}
+	}
+	return jwks
+}
+
+// Rotate adds a freshly generated key to the set. If a next key is already
+// published it is promoted to active, the current active key is retired, and
+// the new key becomes next. Otherwise the new key is only staged as next.
+// Retired keys whose grace period has ended are dropped.
+func (ks *KeySet) Rotate(kid string) (*KeySet, error) {
+	now := ks.now().UTC()
+	fresh, err := GenerateKey(kid, KeyNext)
+	if err != nil {
+		return nil, err
+	}
+
+	var next *Key
+	for _, k := range ks.keys {
+		if k.State == KeyNext {
+			next = k
+		}
+	}
+
+	var keys []*Key
+	for _, k := range ks.keys {
+		c := *k
+		switch {
+		case next != nil && c.State == KeyActive:
+			c.State = KeyRetired
+			c.RetiredAt = now
+		case next != nil && c.KID == next.KID:
+			c.State = KeyActive
+		case c.State == KeyRetired && !ks.verifiable(&c):
+			continue
+		}
+		keys = append(keys, &c)
+	}
+	keys = append(keys, fresh)
+
+	rotated, err := NewKeySet(keys, ks.grace)
+	if err != nil {
+		return nil, err
+	}
+	rotated.now = ks.now
+	return rotated, nil
+}
+
+// MarshalJSON encodes the key set, including private keys, as a key set document
+func (ks *KeySet) MarshalJSON() ([]byte, error) {
+	doc := struct {
+		Keys []keyJSON `json:"keys"`
+	}{Keys: []keyJSON{}}
+
+	for _, k := range ks.keys {
+		kj := keyJSON{
+			KID:       k.KID,
+			State:     k.State,
+			PubX:      k.PublicX(),
+			CreatedAt: k.CreatedAt,
+		}
+		if k.Private != nil {
+			privPEM, err := k.PrivatePEM()
+			if err != nil {
+				return nil, err
+			}
+			kj.PrivPEM = privPEM
+		}
+		if !k.RetiredAt.IsZero() {
+			t := k.RetiredAt
+			kj.RetiredAt = &t
+		}
+		doc.Keys = append(doc.Keys, kj)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func parseKeys(data []byte) ([]*Key, error) {
+	var doc struct {
+		Keys []keyJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(doc.Keys))
+	for _, kj := range doc.Keys {
+		k := &Key{KID: kj.KID, State: kj.State, CreatedAt: kj.CreatedAt}
+		if kj.RetiredAt != nil {
+			k.RetiredAt = *kj.RetiredAt
+		}
+		if kj.PrivPEM != "" {
+			priv, err := ParsePrivateKeyPEM([]byte(kj.PrivPEM))
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", kj.KID, err)
+			}
+			k.Private = priv
+			k.Public = priv.Public().(ed25519.PublicKey)
+		}
+		if kj.PubX != "" {
+			pub, err := ParsePublicKey(kj.PubX)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", kj.KID, err)
+			}
+			if k.Public != nil && !pub.Equal(k.Public) {
+				return nil, fmt.Errorf("key %q public and private halves do not match", kj.KID)
+			}
+			k.Public = pub
+		}
+		if k.Public == nil {
+			return nil, fmt.Errorf("key %q has no key material", kj.KID)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
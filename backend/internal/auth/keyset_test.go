@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	k, err := GenerateKey("k1", KeyActive)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	ks, err := NewKeySet([]*Key{k}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	return ks
+}
+
+func kids(jwks []JWK) []string {
+	var out []string
+	for _, k := range jwks {
+		out = append(out, k.Kid)
+	}
+	return out
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ks := newTestKeySet(t)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	// First rotation only stages the new key
+	ks, err := ks.Rotate("k2")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if ks.Active().KID != "k1" {
+		t.Errorf("Expected k1 to stay active, got %s", ks.Active().KID)
+	}
+	if got := kids(ks.JWKS()); len(got) != 2 || got[0] != "k1" || got[1] != "k2" {
+		t.Errorf("Unexpected JWKS after staging: %v", got)
+	}
+
+	// Second rotation promotes k2, retires k1 and stages k3
+	ks, err = ks.Rotate("k3")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if ks.Active().KID != "k2" {
+		t.Errorf("Expected k2 to be active, got %s", ks.Active().KID)
+	}
+	if got := kids(ks.JWKS()); len(got) != 3 || got[0] != "k2" || got[1] != "k3" || got[2] != "k1" {
+		t.Errorf("Unexpected JWKS after promotion: %v", got)
+	}
+	if _, ok := ks.PublicKey("k1"); !ok {
+		t.Error("Expected retired key to verify during its grace period")
+	}
+
+	// After the grace period the retired key is neither published nor accepted
+	later := now.Add(2 * time.Hour)
+	ks.now = func() time.Time { return later }
+	if _, ok := ks.PublicKey("k1"); ok {
+		t.Error("Expected retired key to stop verifying after its grace period")
+	}
+	if got := kids(ks.JWKS()); len(got) != 2 {
+		t.Errorf("Expected expired key to be unpublished, got %v", got)
+	}
+
+	// And the next rotation drops it from the set entirely
+	ks, err = ks.Rotate("k4")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	for _, k := range ks.Keys() {
+		if k.KID == "k1" {
+			t.Error("Expected expired retired key to be pruned")
+		}
+	}
+}
+
+func TestKeySetJSONRoundTrip(t *testing.T) {
+	ks, err := newTestKeySet(t).Rotate("k2")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	data, err := ks.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "keyset.json"), data, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	loaded, err := LoadKeySetDir(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeySetDir failed: %v", err)
+	}
+	if loaded.Active().KID != "k1" || !loaded.Active().Private.Equal(ks.Active().Private) {
+		t.Error("Active key did not survive the round trip")
+	}
+	if len(loaded.Keys()) != 2 {
+		t.Errorf("Expected 2 keys, got %d", len(loaded.Keys()))
+	}
+}
+
+func TestNewKeySetValidation(t *testing.T) {
+	a, _ := GenerateKey("a", KeyActive)
+	b, _ := GenerateKey("b", KeyActive)
+	n, _ := GenerateKey("n", KeyNext)
+	dup, _ := GenerateKey("a", KeyNext)
+
+	if _, err := NewKeySet([]*Key{n}, time.Hour); err == nil {
+		t.Error("Expected key set without active key to fail")
+	}
+	if _, err := NewKeySet([]*Key{a, b}, time.Hour); err == nil {
+		t.Error("Expected key set with two active keys to fail")
+	}
+	if _, err := NewKeySet([]*Key{a, dup}, time.Hour); err == nil {
+		t.Error("Expected duplicate kid to fail")
+	}
+	if _, err := ParseKeySet([]byte(`{"keys":[{"kid":"x","state":"active"}]}`), time.Hour); err == nil {
+		t.Error("Expected key without material to fail")
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// DefaultMagicLinkTTL is how long a login link stays valid after it is issued
+const DefaultMagicLinkTTL = 15 * time.Minute
+
+var (
+	// ErrInvalidEmail is returned when a login is requested for a malformed address
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrInvalidToken is returned when a login token is unknown, expired or already used
+	ErrInvalidToken = errors.New("invalid or expired login token")
+	// ErrAccountDisabled is returned when a login is requested or completed
+	// for a deactivated account
+	ErrAccountDisabled = errors.New("account is disabled")
+)
+
+// MagicLinks issues and redeems single-use login tokens. Only the SHA-256 hash
+// of a token is persisted; the raw token exists solely in the link handed to
+// the user.
+type MagicLinks struct {
+	links   store.MagicLinks
+	ttl     time.Duration
+	baseURL string
+}
+
+// NewMagicLinks creates a magic link service over links. baseURL is the
+// frontend page that receives the token as its "token" query parameter.
+func NewMagicLinks(links store.MagicLinks, ttl time.Duration, baseURL string) *MagicLinks {
+	if ttl <= 0 {
+		ttl = DefaultMagicLinkTTL
+	}
+	return &MagicLinks{links: links, ttl: ttl, baseURL: baseURL}
+}
+
+// TTL returns how long issued links remain valid
+func (m *MagicLinks) TTL() time.Duration {
+	return m.ttl
+}
+
+// LinkURL builds the login link the user follows to complete sign-in
+func (m *MagicLinks) LinkURL(token string) string {
+	sep := "?"
+	if strings.Contains(m.baseURL, "?") {
+		sep = "&"
+	}
+	return m.baseURL + sep + "token=" + token
+}
+
+// Create issues a new login token for email and returns the raw token
+func (m *MagicLinks) Create(ctx context.Context, email string) (string, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = m.links.Create(ctx, email, HashToken(token), time.Now().Add(m.ttl))
+	if errors.Is(err, store.ErrDisabled) {
+		return "", ErrAccountDisabled
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// DeleteExpired removes links that expired before cutoff, used or not, and
+// returns how many were deleted
+func (m *MagicLinks) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	return m.links.DeleteExpired(ctx, cutoff)
+}
+
+// Complete redeems a login token. The token is marked used, the matching user
+// is created or updated, and the user is returned. Deactivated accounts get
+// ErrAccountDisabled.
+func (m *MagicLinks) Complete(ctx context.Context, token string) (*store.User, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := m.links.Redeem(ctx, HashToken(token), func(email string) (*store.User, error) {
+		username, err := newUsername(email)
+		if err != nil {
+			return nil, err
+		}
+		return &store.User{Email: email, Username: username, DisplayName: defaultDisplayName(email)}, nil
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if errors.Is(err, store.ErrDisabled) {
+		return nil, ErrAccountDisabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// NewToken returns a random URL-safe token with 256 bits of entropy
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 digest stored in place of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeEmail validates an address and returns it trimmed and lower-cased
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > 255 {
+		return "", ErrInvalidEmail
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+var usernameStrip = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// newUsername derives a unique-enough username from the local part of email.
+// Only used when the user row is first inserted.
+func newUsername(email string) (string, error) {
+	base := usernameStrip.ReplaceAllString(strings.ToLower(localPart(email)), "")
+	if base == "" {
+		base = "player"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate username: %w", err)
+	}
+	return base + "_" + hex.EncodeToString(suffix), nil
+}
+
+func defaultDisplayName(email string) string {
+	name := localPart(email)
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name
+}
+
+func localPart(email string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[:i]
+	}
+	return email
+}
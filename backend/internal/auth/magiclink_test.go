@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+	"github.com/scruffyprodigy/playhub/internal/store/memory"
+	"github.com/scruffyprodigy/playhub/internal/store/postgres"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "Player@Example.com", want: "player@example.com"},
+		{in: "  player@example.com  ", want: "player@example.com"},
+		{in: "", wantErr: true},
+		{in: "not-an-email", wantErr: true},
+		{in: "Player <player@example.com>", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := NormalizeEmail(tc.in)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidEmail) {
+				t.Errorf("NormalizeEmail(%q): expected ErrInvalidEmail, got %v", tc.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeEmail(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNewTokenAndHash(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	b, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	if a == b {
+		t.Error("Expected two tokens to differ")
+	}
+
+	hash := HashToken(a)
+	if len(hash) != 64 {
+		t.Errorf("Expected 64 character hash, got %d", len(hash))
+	}
+	if hash == a {
+		t.Error("Expected hash to differ from raw token")
+	}
+	if HashToken(a) != hash {
+		t.Error("Expected hashing to be deterministic")
+	}
+}
+
+func TestLinkURL(t *testing.T) {
+	m := NewMagicLinks(nil, 0, "https://playhub.example/auth/magic")
+	if got := m.LinkURL("abc"); got != "https://playhub.example/auth/magic?token=abc" {
+		t.Errorf("Unexpected link: %s", got)
+	}
+	if m.TTL() != DefaultMagicLinkTTL {
+		t.Errorf("Expected default TTL, got %s", m.TTL())
+	}
+
+	m = NewMagicLinks(nil, time.Minute, "https://playhub.example/login?next=home")
+	if got := m.LinkURL("abc"); got != "https://playhub.example/login?next=home&token=abc" {
+		t.Errorf("Unexpected link: %s", got)
+	}
+}
+
+func TestNewUsername(t *testing.T) {
+	name, err := newUsername("Jane.Doe+games@example.com")
+	if err != nil {
+		t.Fatalf("newUsername failed: %v", err)
+	}
+	if !strings.HasPrefix(name, "janedoegames_") {
+		t.Errorf("Unexpected username %q", name)
+	}
+	if len(name) > 50 {
+		t.Errorf("Username %q exceeds column width", name)
+	}
+}
+
+// testStores returns the stores the round-trip tests run against: always the
+// memory store, and Postgres when DATABASE_URL is set
+func testStores(t *testing.T) map[string]store.Store {
+	stores := map[string]store.Store{"memory": memory.New()}
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return stores
+	}
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	stores["postgres"] = postgres.New(db)
+	return stores
+}
+
+func TestMagicLinkRoundTrip(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) { testMagicLinkRoundTrip(t, st) })
+	}
+}
+
+func testMagicLinkRoundTrip(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	m := NewMagicLinks(st.MagicLinks, time.Minute, "http://localhost/auth/magic")
+	email := "magic-" + strings.ToLower(time.Now().Format("20060102150405.000000")) + "@example.com"
+
+	token, err := m.Create(ctx, email)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	user, err := m.Complete(ctx, token)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if user.Email != email {
+		t.Errorf("Expected user email %q, got %q", email, user.Email)
+	}
+
+	if _, err := m.Complete(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected reused token to be rejected, got %v", err)
+	}
+
+	// A second login for the same address must resolve to the same user
+	token, err = m.Create(ctx, email)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	again, err := m.Complete(ctx, token)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("Expected same user id %s, got %s", user.ID, again.ID)
+	}
+}
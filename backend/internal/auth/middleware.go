@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AccessCookieName is the cookie that carries the access token for browser clients
+const AccessCookieName = "playhub_access"
+
+// Middleware authenticates requests carrying a JWT in the Authorization header
+// or the access cookie and stores the resulting Principal in the request
+// context. Game servers instead send an API key, either as the bearer token or
+// in the X-API-Key header, and become a game principal. Requests without
+// credentials pass through anonymously. Requests whose explicit token or key is
+// invalid, or whose token belongs to a revoked login session, are rejected with
+// 401; the same failure from the access cookie instead clears the cookie and
+// serves the request anonymously, so that a stale browser session can still
+// call refreshSession. The request and response are also made available so
+// that resolvers can read and set auth cookies. sessions and keys may be nil.
+func Middleware(v *Verifier, sessions SessionValidator, keys APIKeyAuthenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(withHTTPContext(r.Context(), w, r))
+
+		token, fromCookie := tokenFromRequest(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reject := func(message string) {
+			if fromCookie {
+				clearAccessCookie(r.Context())
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeUnauthorized(w, message)
+		}
+
+		if !fromCookie && IsAPIKey(token) {
+			if keys == nil {
+				writeUnauthorized(w, "API keys are not configured")
+				return
+			}
+			key, err := keys.Authenticate(r.Context(), token)
+			if errors.Is(err, ErrInvalidAPIKey) {
+				writeUnauthorized(w, err.Error())
+				return
+			}
+			if err != nil {
+				log.Printf("auth: %v", err)
+				http.Error(w, "failed to check API key", http.StatusInternalServerError)
+				return
+			}
+			ctx := WithPrincipal(r.Context(), principalFromAPIKey(key))
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if v == nil {
+			reject("token verification is not configured")
+			return
+		}
+
+		claims, err := v.Verify(token)
+		if err != nil {
+			reject(err.Error())
+			return
+		}
+
+		if claims.SessionID != "" && sessions != nil {
+			active, err := sessions.IsActive(r.Context(), claims.SessionID)
+			if err != nil {
+				log.Printf("auth: %v", err)
+				http.Error(w, "failed to check session", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				reject("session revoked")
+				return
+			}
+		}
+
+		ctx := WithPrincipal(r.Context(), principalFromClaims(claims))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tokenFromRequest prefers an explicit API key or bearer token over the cookie
+// so that API clients are not affected by a stale browser session. fromCookie
+// reports whether the token came from the access cookie.
+func tokenFromRequest(r *http.Request) (token string, fromCookie bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key), false
+	}
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), false
+		}
+		return "", false
+	}
+	if c, err := r.Cookie(AccessCookieName); err == nil {
+		return c.Value, true
+	}
+	return "", false
+}
+
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []any{map[string]any{
+			"message":    message,
+			"extensions": map[string]string{"code": "UNAUTHORIZED"},
+		}},
+	})
+}
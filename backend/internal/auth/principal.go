@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// PrincipalKind identifies what sort of caller a Principal represents
+type PrincipalKind string
+
+// Kinds of principal
+const (
+	PrincipalUser PrincipalKind = "user" // a signed-in person
+	PrincipalGame PrincipalKind = "game" // a game server using an API key
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Kind      PrincipalKind
+	UserID    string
+	Email     string
+	SessionID string
+	TokenID   string
+	Roles     []Role
+	ExpiresAt time.Time
+
+	// Set for game principals
+	GameID   string
+	APIKeyID string
+	Scopes   []string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserFromContext returns the signed-in user principal in ctx, if any
+func UserFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.Kind != PrincipalUser {
+		return nil, false
+	}
+	return p, true
+}
+
+// GameFromContext returns the game principal in ctx, if any
+func GameFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.Kind != PrincipalGame {
+		return nil, false
+	}
+	return p, true
+}
+
+// HasScope reports whether a game principal's API key grants scope
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil || p.Kind != PrincipalGame {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func principalFromAPIKey(k *store.APIKey) *Principal {
+	return &Principal{
+		Kind:     PrincipalGame,
+		GameID:   k.GameID,
+		APIKeyID: k.ID,
+		Scopes:   k.Scopes,
+	}
+}
+
+func principalFromClaims(c *Claims) *Principal {
+	return &Principal{
+		Kind:      PrincipalUser,
+		UserID:    c.Subject,
+		Email:     c.Email,
+		SessionID: c.SessionID,
+		TokenID:   c.ID,
+		Roles:     parseRoles(c.Roles),
+		ExpiresAt: time.Unix(c.ExpiresAt, 0),
+	}
+}
+
+// parseRoles drops role names this build does not know about
+func parseRoles(names []string) []Role {
+	var roles []Role
+	for _, name := range names {
+		if r, err := ParseRole(name); err == nil {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
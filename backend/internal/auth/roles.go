@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// ErrUserNotFound is returned when a role is granted to a user who does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// Role is a capability granted to a user
+type Role string
+
+// Roles a user can hold. Every signed-in user is a player; admins pass every
+// role check.
+const (
+	RolePlayer    Role = "player"
+	RolePublisher Role = "publisher"
+	RoleSupport   Role = "support"
+	RoleAdmin     Role = "admin"
+)
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(s)); r {
+	case RolePlayer, RolePublisher, RoleSupport, RoleAdmin:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+// HasRole reports whether the principal holds role
+func (p *Principal) HasRole(role Role) bool {
+	if p == nil {
+		return false
+	}
+	if p.Kind == PrincipalUser && role == RolePlayer {
+		return true
+	}
+	for _, r := range p.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole reports whether the principal holds at least one of roles
+func (p *Principal) HasAnyRole(roles ...Role) bool {
+	for _, r := range roles {
+		if p.HasRole(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManageGame reports whether the principal may manage a game owned by
+// ownerID: admins manage every game, publishers only their own
+func (p *Principal) CanManageGame(ownerID string) bool {
+	if p.HasRole(RoleAdmin) {
+		return true
+	}
+	return p.HasRole(RolePublisher) && ownerID != "" && p.UserID == ownerID
+}
+
+// RoleStore keeps the roles granted to users
+type RoleStore struct {
+	roles store.Roles
+}
+
+// NewRoleStore creates a role store over roles
+func NewRoleStore(roles store.Roles) *RoleStore {
+	return &RoleStore{roles: roles}
+}
+
+// ForUser returns the roles granted to userID. Roles this build does not know
+// about are dropped.
+func (s *RoleStore) ForUser(ctx context.Context, userID string) ([]Role, error) {
+	names, err := s.roles.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return parseRoles(names), nil
+}
+
+// Grant gives role to userID. Granting a role the user already holds is a
+// no-op. It fails with ErrUserNotFound for unknown users.
+func (s *RoleStore) Grant(ctx context.Context, userID string, role Role, grantedBy string) error {
+	err := s.roles.Grant(ctx, userID, string(role), grantedBy)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// Revoke takes role away from userID
+func (s *RoleStore) Revoke(ctx context.Context, userID string, role Role) error {
+	return s.roles.Revoke(ctx, userID, string(role))
+}
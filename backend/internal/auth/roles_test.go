@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+func TestParseRole(t *testing.T) {
+	if r, err := ParseRole("PUBLISHER"); err != nil || r != RolePublisher {
+		t.Errorf("Expected publisher, got %q (%v)", r, err)
+	}
+	if _, err := ParseRole("superuser"); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	player := &Principal{Kind: PrincipalUser, UserID: "u1"}
+	publisher := &Principal{Kind: PrincipalUser, UserID: "u2", Roles: []Role{RolePublisher}}
+	admin := &Principal{Kind: PrincipalUser, UserID: "u3", Roles: []Role{RoleAdmin}}
+
+	if !player.HasRole(RolePlayer) {
+		t.Error("Expected every user to be a player")
+	}
+	if player.HasRole(RolePublisher) {
+		t.Error("Expected player not to be a publisher")
+	}
+	if !publisher.HasAnyRole(RoleSupport, RolePublisher) || publisher.HasRole(RoleSupport) {
+		t.Errorf("Unexpected role checks for %+v", publisher)
+	}
+	if !admin.HasRole(RoleSupport) || !admin.HasRole(RolePublisher) {
+		t.Error("Expected admin to pass every role check")
+	}
+	var nobody *Principal
+	if nobody.HasRole(RolePlayer) {
+		t.Error("Expected nil principal to hold no roles")
+	}
+}
+
+func TestCanManageGame(t *testing.T) {
+	publisher := &Principal{Kind: PrincipalUser, UserID: "u2", Roles: []Role{RolePublisher}}
+	if !publisher.CanManageGame("u2") {
+		t.Error("Expected publisher to manage own game")
+	}
+	if publisher.CanManageGame("someone-else") || publisher.CanManageGame("") {
+		t.Error("Expected publisher not to manage other or unowned games")
+	}
+	player := &Principal{Kind: PrincipalUser, UserID: "u1"}
+	if player.CanManageGame("u1") {
+		t.Error("Expected players not to manage games")
+	}
+	admin := &Principal{Kind: PrincipalUser, UserID: "u3", Roles: []Role{RoleAdmin}}
+	if !admin.CanManageGame("") {
+		t.Error("Expected admin to manage every game")
+	}
+}
+
+func TestRolesClaimRoundTrip(t *testing.T) {
+	_, priv := newTestKey(t)
+	s := NewSigner("k1", priv, TokenConfig{Issuer: DefaultIssuer, Audience: DefaultAudience})
+	user := &store.User{ID: "11111111-1111-1111-1111-111111111111"}
+
+	token, _, err := s.IssueAccessToken(user, []Role{RoleSupport}, "session-1")
+	if err != nil {
+		t.Fatalf("IssueAccessToken failed: %v", err)
+	}
+	claims, err := s.Verifier().Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	p := principalFromClaims(claims)
+	if !p.HasRole(RoleSupport) || p.HasRole(RoleAdmin) {
+		t.Errorf("Unexpected roles in principal: %v", p.Roles)
+	}
+}
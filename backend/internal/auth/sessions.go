@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// DefaultRefreshTokenTTL is how long a login session can be refreshed without
+// signing in again
+const DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired
+// or belongs to a revoked session
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// SessionValidator reports whether a login session may still be used
+type SessionValidator interface {
+	IsActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// Sessions issues login sessions and rotates their refresh tokens. Only the
+// SHA-256 hash of a refresh token is persisted.
+type Sessions struct {
+	sessions store.AuthSessions
+	ttl      time.Duration
+}
+
+// NewSessions creates a session service over sessions whose refresh tokens
+// live for ttl
+func NewSessions(sessions store.AuthSessions, ttl time.Duration) *Sessions {
+	if ttl <= 0 {
+		ttl = DefaultRefreshTokenTTL
+	}
+	return &Sessions{sessions: sessions, ttl: ttl}
+}
+
+// Create starts a session for userID and returns it with its raw refresh token
+func (s *Sessions) Create(ctx context.Context, userID, userAgent, ip string) (*store.AuthSession, string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &store.AuthSession{UserID: userID, UserAgent: userAgent, IP: ip, ExpiresAt: time.Now().Add(s.ttl)}
+	if err := s.sessions.Create(ctx, session, HashToken(token)); err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Refresh exchanges a refresh token for a new one. The old token stops working
+// immediately and the session's expiry is extended.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (*store.AuthSession, *store.User, string, error) {
+	if refreshToken == "" {
+		return nil, nil, "", ErrInvalidRefreshToken
+	}
+	token, err := NewToken()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	session, user, err := s.sessions.Rotate(ctx, HashToken(refreshToken), HashToken(token), time.Now().Add(s.ttl))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return session, user, token, nil
+}
+
+// Revoke ends a single session
+func (s *Sessions) Revoke(ctx context.Context, sessionID string) error {
+	return s.sessions.Revoke(ctx, sessionID)
+}
+
+// RevokeByRefreshToken ends the session a refresh token belongs to
+func (s *Sessions) RevokeByRefreshToken(ctx context.Context, refreshToken string) error {
+	return s.sessions.RevokeByToken(ctx, HashToken(refreshToken))
+}
+
+// RevokeAllForUser ends every open session of userID and returns how many were revoked
+func (s *Sessions) RevokeAllForUser(ctx context.Context, userID string) (int, error) {
+	return s.sessions.RevokeAllForUser(ctx, userID)
+}
+
+// IsActive implements SessionValidator
+func (s *Sessions) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	return s.sessions.IsActive(ctx, sessionID)
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+type fakeSessions map[string]bool
+
+func (f fakeSessions) IsActive(_ context.Context, sessionID string) (bool, error) {
+	return f[sessionID], nil
+}
+
+func TestMiddlewareRejectsRevokedSession(t *testing.T) {
+	pub, priv := newTestKey(t)
+	v := NewVerifier(StaticKeys{"k1": pub}, DefaultIssuer, DefaultAudience)
+	var got *Principal
+	h := Middleware(v, fakeSessions{"live": true, "revoked": false}, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	serve := func(sessionID string, cookie bool) *httptest.ResponseRecorder {
+		got = nil
+		claims := validClaims()
+		claims.SessionID = sessionID
+		token := signTestToken(t, priv, map[string]string{"alg": "EdDSA", "kid": "k1"}, claims)
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		if cookie {
+			req.AddCookie(&http.Cookie{Name: AccessCookieName, Value: token})
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("live", false); rec.Code != http.StatusOK || got == nil {
+		t.Errorf("Expected live session to pass, got %d", rec.Code)
+	}
+	if rec := serve("revoked", false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked session to be rejected, got %d", rec.Code)
+	}
+
+	// A revoked session in the cookie is dropped instead, so that the
+	// browser can still refresh into a new session
+	rec := serve("revoked", true)
+	if rec.Code != http.StatusOK || got != nil {
+		t.Fatalf("Expected revoked cookie to be served anonymously, got %d, principal %+v", rec.Code, got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != AccessCookieName || cookies[0].Value != "" || cookies[0].MaxAge >= 0 {
+		t.Errorf("Expected the access cookie to be cleared, got %+v", cookies)
+	}
+}
+
+func TestRefreshCookie(t *testing.T) {
+	var got string
+	h := Middleware(nil, nil, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RefreshTokenFromContext(r.Context())
+		ClearAuthCookies(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.AddCookie(&http.Cookie{Name: RefreshCookieName, Value: "refresh-1"})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got != "refresh-1" {
+		t.Errorf("Expected refresh token from cookie, got %q", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("Expected two cleared cookies, got %d", len(cookies))
+	}
+	for _, c := range cookies {
+		if c.Value != "" || c.MaxAge >= 0 {
+			t.Errorf("Expected %s to be cleared, got %+v", c.Name, c)
+		}
+		if c.Name == RefreshCookieName && c.Path != "/graphql" {
+			t.Errorf("Expected refresh cookie scoped to /graphql, got %q", c.Path)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	clientIP := func(remote string, forwarded string, proxies []netip.Prefix) string {
+		var ip string
+		h := TrustProxies(proxies, Middleware(nil, nil, nil, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			_, ip = ClientInfo(r.Context())
+		})))
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		return ip
+	}
+
+	if ip := clientIP("10.0.0.1:1234", "", proxies); ip != "10.0.0.1" {
+		t.Errorf("Expected remote address, got %q", ip)
+	}
+	// Without trusted proxies the header is ignored
+	if ip := clientIP("198.51.100.9:1234", "203.0.113.7", nil); ip != "198.51.100.9" {
+		t.Errorf("Expected remote address of an untrusted peer, got %q", ip)
+	}
+	if ip := clientIP("198.51.100.9:1234", "203.0.113.7", proxies); ip != "198.51.100.9" {
+		t.Errorf("Expected remote address of a peer outside the proxies, got %q", ip)
+	}
+	// Hops the client made up before the proxies' own do not change the key
+	for _, forwarded := range []string{"203.0.113.7, 10.0.0.2", "1.2.3.4, 203.0.113.7, 10.0.0.2", "garbage, 203.0.113.7"} {
+		if ip := clientIP("10.0.0.1:1234", forwarded, proxies); ip != "203.0.113.7" {
+			t.Errorf("%q: expected the rightmost untrusted hop, got %q", forwarded, ip)
+		}
+	}
+}
+
+func TestSessionsRoundTrip(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) { testSessionsRoundTrip(t, st) })
+	}
+}
+
+func testSessionsRoundTrip(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	m := NewMagicLinks(st.MagicLinks, time.Minute, "http://localhost/auth/magic")
+	email := "session-" + strings.ToLower(time.Now().Format("20060102150405.000000")) + "@example.com"
+	token, err := m.Create(ctx, email)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	user, err := m.Complete(ctx, token)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	s := NewSessions(st.AuthSessions, time.Hour)
+	session, refreshToken, err := s.Create(ctx, user.ID, "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+
+	refreshed, refreshedUser, rotated, err := s.Refresh(ctx, refreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if refreshed.ID != session.ID || refreshedUser.ID != user.ID || rotated == refreshToken {
+		t.Errorf("Unexpected refresh result: %+v %+v", refreshed, refreshedUser)
+	}
+	if _, _, _, err := s.Refresh(ctx, refreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected rotated-out refresh token to be rejected, got %v", err)
+	}
+
+	if n, err := s.RevokeAllForUser(ctx, user.ID); err != nil || n != 1 {
+		t.Errorf("Expected one revoked session, got %d (%v)", n, err)
+	}
+	if active, err := s.IsActive(ctx, session.ID); err != nil || active {
+		t.Errorf("Expected session to be inactive after revocation, got %v (%v)", active, err)
+	}
+	if _, _, _, err := s.Refresh(ctx, rotated); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected revoked session refresh to be rejected, got %v", err)
+	}
+}
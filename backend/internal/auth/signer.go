@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// DefaultAccessTokenTTL is the lifetime of access tokens minted at login
+const DefaultAccessTokenTTL = 15 * time.Minute
+
+// DefaultJoinTicketTTL is the lifetime of session join tickets
+const DefaultJoinTicketTTL = 2 * time.Minute
+
+// TokenConfig holds the claims and lifetimes applied to issued tokens
+type TokenConfig struct {
+	Issuer        string
+	Audience      string
+	AccessTTL     time.Duration
+	JoinTicketTTL time.Duration
+}
+
+// TokenConfigFromEnv reads JWT_ISSUER, JWT_AUDIENCE, JWT_ACCESS_TTL and
+// JWT_JOIN_TICKET_TTL
+func TokenConfigFromEnv() (TokenConfig, error) {
+	cfg := TokenConfig{
+		Issuer:        getenv("JWT_ISSUER", DefaultIssuer),
+		Audience:      getenv("JWT_AUDIENCE", DefaultAudience),
+		AccessTTL:     DefaultAccessTokenTTL,
+		JoinTicketTTL: DefaultJoinTicketTTL,
+	}
+	for _, d := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"JWT_ACCESS_TTL", &cfg.AccessTTL},
+		{"JWT_JOIN_TICKET_TTL", &cfg.JoinTicketTTL},
+	} {
+		if v := os.Getenv(d.env); v != "" {
+			ttl, err := time.ParseDuration(v)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", d.env, err)
+			}
+			*d.dst = ttl
+		}
+	}
+	return cfg, nil
+}
+
+// Signer mints EdDSA-signed JWTs with a single Ed25519 key
+type Signer struct {
+	kid       string
+	key       ed25519.PrivateKey
+	issuer    string
+	audience  string
+	ttl       time.Duration
+	ticketTTL time.Duration
+	now       func() time.Time
+}
+
+// NewSigner creates a signer whose tokens carry kid in their header
+func NewSigner(kid string, key ed25519.PrivateKey, cfg TokenConfig) *Signer {
+	ttl := cfg.AccessTTL
+	if ttl <= 0 {
+		ttl = DefaultAccessTokenTTL
+	}
+	ticketTTL := cfg.JoinTicketTTL
+	if ticketTTL <= 0 {
+		ticketTTL = DefaultJoinTicketTTL
+	}
+	return &Signer{kid: kid, key: key, issuer: cfg.Issuer, audience: cfg.Audience, ttl: ttl, ticketTTL: ticketTTL, now: time.Now}
+}
+
+// ParsePrivateKeyPEM decodes a PKCS#8 PEM Ed25519 private key as written by scripts/jwks.go
+func ParsePrivateKeyPEM(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, not Ed25519", key)
+	}
+	return priv, nil
+}
+
+// KeyID returns the kid placed in issued token headers
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// PublicKey returns the public half of the signing key
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Verifier returns a verifier accepting the tokens this signer issues
+func (s *Signer) Verifier() *Verifier {
+	return NewVerifier(StaticKeys{s.kid: s.PublicKey()}, s.issuer, s.audience)
+}
+
+// Sign serializes and signs claims as a compact JWS
+func (s *Signer) Sign(claims Claims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "EdDSA", Typ: "JWT", Kid: s.kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(s.key, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// IssueAccessToken mints a short-lived access token for user, holding roles,
+// bound to the login session sessionID. The roles are embedded so that role
+// checks need no database round trip; role changes apply from the next refresh.
+func (s *Signer) IssueAccessToken(user *store.User, roles []Role, sessionID string) (string, time.Time, error) {
+	jti, err := NewToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.ttl)
+	token, err := s.Sign(Claims{
+		Issuer:    s.issuer,
+		Subject:   user.ID,
+		Audience:  Audience{s.audience},
+		ExpiresAt: expiresAt.Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        jti,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Roles:     roleNames(roles),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+func roleNames(roles []Role) []string {
+	if len(roles) == 0 {
+		return nil
+	}
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	return names
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	_, priv := newTestKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	parsed, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM failed: %v", err)
+	}
+
+	s := NewSigner("lobby-dev", parsed, TokenConfig{Issuer: DefaultIssuer, Audience: DefaultAudience})
+	user := &store.User{ID: "11111111-1111-1111-1111-111111111111", Email: "player@example.com"}
+
+	token, expiresAt, err := s.IssueAccessToken(user, nil, "session-1")
+	if err != nil {
+		t.Fatalf("IssueAccessToken failed: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 0 || d > DefaultAccessTokenTTL {
+		t.Errorf("Unexpected expiry %s", expiresAt)
+	}
+
+	claims, err := s.Verifier().Verify(token)
+	if err != nil {
+		t.Fatalf("Issued token failed to verify: %v", err)
+	}
+	if claims.Subject != user.ID || claims.Email != user.Email || claims.ID == "" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(strings.Split(token, ".")[0], &header); err != nil {
+		t.Fatalf("Failed to decode header: %v", err)
+	}
+	if header.Kid != "lobby-dev" || header.Alg != "EdDSA" {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+}
+
+func TestJoinTicket(t *testing.T) {
+	pub, priv := newTestKey(t)
+	s := NewSigner("k1", priv, TokenConfig{Issuer: DefaultIssuer, Audience: DefaultAudience})
+
+	ticket, expiresAt, err := s.IssueJoinTicket("session-1", "user-1", "game-1")
+	if err != nil {
+		t.Fatalf("IssueJoinTicket failed: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 0 || d > DefaultJoinTicketTTL {
+		t.Errorf("Unexpected expiry %s", expiresAt)
+	}
+
+	keys := StaticKeys{"k1": pub}
+	claims, err := NewJoinTicketVerifier(keys, DefaultIssuer, "game-1").VerifyJoinTicket(ticket)
+	if err != nil {
+		t.Fatalf("Ticket failed to verify: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.GameSessionID != "session-1" || claims.GameID != "game-1" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := NewJoinTicketVerifier(keys, DefaultIssuer, "game-2").VerifyJoinTicket(ticket); err == nil {
+		t.Error("Expected a ticket for another game to be rejected")
+	}
+	if _, err := s.Verifier().Verify(ticket); err == nil {
+		t.Error("Expected a ticket to be rejected as an access token")
+	}
+
+	access, _, err := s.IssueAccessToken(&store.User{ID: "user-1"}, nil, "session-1")
+	if err != nil {
+		t.Fatalf("IssueAccessToken failed: %v", err)
+	}
+	if _, err := NewVerifier(keys, DefaultIssuer, JoinTicketAudience("game-1")).VerifyJoinTicket(access); err == nil {
+		t.Error("Expected an access token to be rejected as a ticket")
+	}
+}
+
+func TestParsePrivateKeyPEMRejectsGarbage(t *testing.T) {
+	if _, err := ParsePrivateKeyPEM([]byte("not a pem")); err == nil {
+		t.Error("Expected error for non-PEM input")
+	}
+}
+
+func TestSetAccessCookie(t *testing.T) {
+	h := Middleware(nil, nil, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !SetAccessCookie(r.Context(), "tok", time.Now().Add(time.Minute)) {
+			t.Error("Expected cookie to be set through the middleware context")
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %d", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != AccessCookieName || c.Value != "tok" || !c.HttpOnly || !c.Secure {
+		t.Errorf("Unexpected cookie: %+v", c)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// JoinTicketAudience is the aud claim of join tickets for gameID. Tickets
+// are therefore never accepted as access tokens, and a game server checking
+// the audience only accepts tickets for its own game.
+func JoinTicketAudience(gameID string) string {
+	return "playhub:game:" + gameID
+}
+
+// IssueJoinTicket mints a short-lived ticket letting userID into the game
+// session sessionID of gameID. Game servers verify it offline against the
+// JWKS with NewJoinTicketVerifier.
+func (s *Signer) IssueJoinTicket(sessionID, userID, gameID string) (string, time.Time, error) {
+	jti, err := NewToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.ticketTTL)
+	token, err := s.Sign(Claims{
+		Issuer:        s.issuer,
+		Subject:       userID,
+		Audience:      Audience{JoinTicketAudience(gameID)},
+		ExpiresAt:     expiresAt.Unix(),
+		NotBefore:     now.Unix(),
+		IssuedAt:      now.Unix(),
+		ID:            jti,
+		GameSessionID: sessionID,
+		GameID:        gameID,
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign join ticket: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// NewJoinTicketVerifier creates a verifier for the join tickets of gameID
+// issued by issuer
+func NewJoinTicketVerifier(keys PublicKeys, issuer, gameID string) *Verifier {
+	return NewVerifier(keys, issuer, JoinTicketAudience(gameID))
+}
+
+// VerifyJoinTicket checks token like Verify and that it names a session of
+// the verifier's game
+func (v *Verifier) VerifyJoinTicket(token string) (*Claims, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.GameSessionID == "" || JoinTicketAudience(claims.GameID) != v.audience {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
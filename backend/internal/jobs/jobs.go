@@ -0,0 +1,26 @@
+// Package jobs runs periodic background maintenance inside the server process
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn immediately and then every interval until ctx is done. Errors
+// are logged and do not stop the job.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("%s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogMailer writes messages to a writer instead of delivering them. It is the
+// default for local development.
+type LogMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewLogMailer creates a mailer that prints every message to w
+func NewLogMailer(w io.Writer, from string) *LogMailer {
+	return &LogMailer{w: w, from: from}
+}
+
+// Send implements Mailer
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "----- mail to %s -----\nFrom: %s\nSubject: %s\n\n%s\n----- end mail -----\n",
+		msg.To, msg.From, msg.Subject, msg.Body)
+	return err
+}
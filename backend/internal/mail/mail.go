@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a Mailer implementation
+type Config struct {
+	// Driver is one of "smtp", "log" or "outbox"
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	OutboxDir string
+}
+
+// ConfigFromEnv reads mailer settings from MAIL_* and SMTP_* environment variables
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:       getenv("MAIL_DRIVER", "log"),
+		From:         getenv("MAIL_FROM", "PlayHub <no-reply@playhub.local>"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getenv("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		OutboxDir:    getenv("MAIL_OUTBOX_DIR", "outbox"),
+	}
+}
+
+// New creates the Mailer selected by cfg.Driver
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case "log", "":
+		return NewLogMailer(os.Stdout, cfg.From), nil
+	case "outbox":
+		return NewOutboxMailer(cfg.OutboxDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
+
+// Bytes renders msg as an RFC 5322 message suitable for SMTP or an .eml file
+func (msg Message) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@playhub>\r\n", randomID())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginTemplateDefaults(t *testing.T) {
+	tmpl, err := NewTemplates("", "")
+	if err != nil {
+		t.Fatalf("NewTemplates failed: %v", err)
+	}
+
+	msg, err := tmpl.Login("player@example.com", "https://playhub.example/auth/magic?token=abc", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Login render failed: %v", err)
+	}
+	if msg.To != "player@example.com" {
+		t.Errorf("Unexpected recipient %q", msg.To)
+	}
+	if msg.Subject != defaultLoginSubject {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Body, "https://playhub.example/auth/magic?token=abc") {
+		t.Error("Expected body to contain the login link")
+	}
+	if !strings.Contains(msg.Body, "15 minutes") {
+		t.Errorf("Expected body to mention expiry, got %q", msg.Body)
+	}
+}
+
+func TestLoginTemplateCustom(t *testing.T) {
+	tmpl, err := NewTemplates("[staging] Sign in as {{.Email}}", "Go to {{.Link}}")
+	if err != nil {
+		t.Fatalf("NewTemplates failed: %v", err)
+	}
+
+	msg, err := tmpl.Login("player@example.com", "http://link", time.Hour)
+	if err != nil {
+		t.Fatalf("Login render failed: %v", err)
+	}
+	if msg.Subject != "[staging] Sign in as player@example.com" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+	if msg.Body != "Go to http://link" {
+		t.Errorf("Unexpected body %q", msg.Body)
+	}
+
+	if _, err := NewTemplates("{{.Broken", ""); err == nil {
+		t.Error("Expected invalid template to fail to parse")
+	}
+}
+
+func TestOutboxMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewOutboxMailer(dir, "PlayHub <no-reply@playhub.local>")
+	if err != nil {
+		t.Fatalf("NewOutboxMailer failed: %v", err)
+	}
+
+	err = m.Send(context.Background(), Message{To: "player@example.com", Subject: "Hello", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected exactly one .eml file, got %v (%v)", files, err)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read eml: %v", err)
+	}
+	eml := string(data)
+	for _, want := range []string{
+		"From: PlayHub <no-reply@playhub.local>\r\n",
+		"To: player@example.com\r\n",
+		"Subject: Hello\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(eml, want) {
+			t.Errorf("Expected eml to contain %q, got:\n%s", want, eml)
+		}
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf, "no-reply@playhub.local")
+
+	if err := m.Send(context.Background(), Message{To: "player@example.com", Subject: "Hi", Body: "http://link"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "player@example.com") || !strings.Contains(out, "http://link") {
+		t.Errorf("Unexpected log output %q", out)
+	}
+}
+
+func TestNewSelectsDriver(t *testing.T) {
+	if _, err := New(Config{Driver: "log"}); err != nil {
+		t.Errorf("log driver failed: %v", err)
+	}
+	if _, err := New(Config{Driver: "smtp"}); err == nil {
+		t.Error("Expected smtp driver without host to fail")
+	}
+	if m, err := New(Config{Driver: "outbox", OutboxDir: t.TempDir()}); err != nil {
+		t.Errorf("outbox driver failed: %v", err)
+	} else if _, ok := m.(*OutboxMailer); !ok {
+		t.Errorf("Expected *OutboxMailer, got %T", m)
+	}
+	if _, err := New(Config{Driver: "carrier-pigeon"}); err == nil {
+		t.Error("Expected unknown driver to fail")
+	}
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// OutboxMailer writes each message as an .eml file in a directory so that
+// development tools and CI can read login links without a mail provider
+type OutboxMailer struct {
+	dir  string
+	from string
+}
+
+// NewOutboxMailer creates a mailer writing to dir, creating it if needed
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	return &OutboxMailer{dir: dir, from: from}, nil
+}
+
+// Dir returns the directory messages are written to
+func (m *OutboxMailer) Dir() string {
+	return m.dir
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+
+// Send implements Mailer
+func (m *OutboxMailer) Send(_ context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	// Timestamp first so a directory listing sorts oldest to newest
+	name := fmt.Sprintf("%s_%s_%s.eml",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		unsafeFilename.ReplaceAllString(msg.To, "_"),
+		randomID()[:8])
+
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, msg.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer delivers mail through an SMTP relay
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates a mailer for host:port. PLAIN auth is used when a
+// username is provided.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{addr: net.JoinHostPort(host, port), auth: auth, from: from}
+}
+
+// Send implements Mailer
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail via %s: %w", m.addr, err)
+	}
+	return nil
+}
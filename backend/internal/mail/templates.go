@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultLoginSubject = "Your PlayHub login link"
+	defaultLoginBody    = `Hi,
+
+Use the link below to sign in to PlayHub. It expires in {{.ExpiresIn}} and can only be used once.
+
+{{.Link}}
+
+If you did not request this email you can safely ignore it.
+`
+)
+
+// LoginData is the data available to the login email templates
+type LoginData struct {
+	Email     string
+	Link      string
+	ExpiresIn string
+}
+
+// Templates renders the emails PlayHub sends
+type Templates struct {
+	loginSubject *template.Template
+	loginBody    *template.Template
+}
+
+// NewTemplates parses the login email subject and body templates. Empty
+// strings fall back to the built-in defaults.
+func NewTemplates(loginSubject, loginBody string) (*Templates, error) {
+	if loginSubject == "" {
+		loginSubject = defaultLoginSubject
+	}
+	if loginBody == "" {
+		loginBody = defaultLoginBody
+	}
+
+	subject, err := template.New("login_subject").Option("missingkey=error").Parse(loginSubject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse login subject template: %w", err)
+	}
+	body, err := template.New("login_body").Option("missingkey=error").Parse(loginBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse login body template: %w", err)
+	}
+
+	return &Templates{loginSubject: subject, loginBody: body}, nil
+}
+
+// TemplatesFromEnv loads templates from MAIL_LOGIN_SUBJECT and either
+// MAIL_LOGIN_TEMPLATE_FILE or MAIL_LOGIN_TEMPLATE
+func TemplatesFromEnv() (*Templates, error) {
+	body := os.Getenv("MAIL_LOGIN_TEMPLATE")
+	if path := os.Getenv("MAIL_LOGIN_TEMPLATE_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read login template: %w", err)
+		}
+		body = string(b)
+	}
+	return NewTemplates(os.Getenv("MAIL_LOGIN_SUBJECT"), body)
+}
+
+// Login renders the magic link email for to
+func (t *Templates) Login(to, link string, ttl time.Duration) (Message, error) {
+	data := LoginData{Email: to, Link: link, ExpiresIn: formatDuration(ttl)}
+
+	var subject, body strings.Builder
+	if err := t.loginSubject.Execute(&subject, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render login subject: %w", err)
+	}
+	if err := t.loginBody.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render login body: %w", err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    body.String(),
+	}, nil
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return plural(int(d/time.Minute), "minute")
+	default:
+		return plural(int(d/time.Second), "second")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
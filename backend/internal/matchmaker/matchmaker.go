@@ -0,0 +1,145 @@
+// Package matchmaker turns players waiting in game_queues into game sessions,
+// fills the places players leave in active sessions of games that allow it,
+// expires the entries of players who waited too long and drops players who
+// stopped sending heartbeats from their sessions
+package matchmaker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// DefaultInterval is how often queues are polled unless MATCHMAKER_INTERVAL says otherwise
+const DefaultInterval = 2 * time.Second
+
+// DefaultSweepInterval is how often expired entries are swept unless
+// QUEUE_SWEEP_INTERVAL says otherwise
+const DefaultSweepInterval = 30 * time.Second
+
+// DefaultHeartbeatTimeout is how long a connected player may go without a
+// heartbeat before they are marked left, unless SESSION_HEARTBEAT_TIMEOUT
+// says otherwise
+const DefaultHeartbeatTimeout = time.Minute
+
+// Matchmaker groups compatible waiting players into sessions sized by the
+// game's player limits. Several matchmakers may share a database; each claims
+// disjoint batches of queue entries.
+type Matchmaker struct {
+	store  store.Store
+	events notify.Publisher
+	rules  Rules
+	now    func() time.Time
+}
+
+// New returns a matchmaker working on st with DefaultRules that tells
+// players about expired entries through events
+func New(st store.Store, events notify.Publisher) *Matchmaker {
+	return &Matchmaker{store: st, events: events, rules: DefaultRules, now: time.Now}
+}
+
+// Run forms as many sessions as the current queues allow and returns how
+// many it created. Games with Backfill first fill the open slots of their
+// active sessions, oldest session first. Games that are not active keep their
+// queues untouched.
+func (m *Matchmaker) Run(ctx context.Context) (int, error) {
+	games, err := m.store.Queues.WaitingGames(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, g := range games {
+		for g.Backfill && ctx.Err() == nil {
+			s, err := m.store.Queues.Backfill(ctx, g.ID, func(s *store.Session, players, waiting []*store.QueueEntry) []*store.QueueEntry {
+				return m.rules.Fill(players, waiting, s.OpenSlots, m.now())
+			})
+			if err != nil {
+				return created, fmt.Errorf("game %s: %w", g.ID, err)
+			}
+			if s == nil {
+				break
+			}
+			log.Printf("matchmaker: backfilled session %s of game %s", s.ID, g.ID)
+		}
+		for ctx.Err() == nil {
+			s, err := m.store.Queues.Match(ctx, g.ID, func(waiting []*store.QueueEntry) []*store.QueueEntry {
+				return m.rules.Pick(waiting, g.MinPlayers, g.MaxPlayers, m.now())
+			})
+			if err != nil {
+				return created, fmt.Errorf("game %s: %w", g.ID, err)
+			}
+			if s == nil {
+				break
+			}
+			created++
+			log.Printf("matchmaker: created session %s of game %s", s.ID, g.ID)
+		}
+	}
+	return created, ctx.Err()
+}
+
+// Sweep expires the waiting entries past their expiry time, raises a
+// queueExpired event for each and returns how many expired
+func (m *Matchmaker) Sweep(ctx context.Context) (int, error) {
+	expired, err := m.store.Queues.Expire(ctx, m.now())
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range expired {
+		err := m.events.Publish(ctx, notify.Event{Type: notify.QueueExpired, UserID: e.UserID, GameID: e.GameID, At: m.now()})
+		if err != nil {
+			log.Printf("matchmaker: %v", err)
+		}
+	}
+	if len(expired) > 0 {
+		log.Printf("matchmaker: expired %d queue entries", len(expired))
+	}
+	return len(expired), nil
+}
+
+// SweepPresence marks the session players not seen within timeout as left
+// and returns how many it marked
+func (m *Matchmaker) SweepPresence(ctx context.Context, timeout time.Duration) (int, error) {
+	dropped, err := m.store.Sessions.DropStale(ctx, m.now().Add(-timeout))
+	if err != nil {
+		return 0, err
+	}
+	if dropped > 0 {
+		log.Printf("matchmaker: marked %d silent players as left", dropped)
+	}
+	return dropped, nil
+}
+
+// IntervalFromEnv returns the polling interval from MATCHMAKER_INTERVAL
+func IntervalFromEnv() (time.Duration, error) {
+	return durationFromEnv("MATCHMAKER_INTERVAL", DefaultInterval)
+}
+
+// SweepIntervalFromEnv returns the sweep interval from QUEUE_SWEEP_INTERVAL
+func SweepIntervalFromEnv() (time.Duration, error) {
+	return durationFromEnv("QUEUE_SWEEP_INTERVAL", DefaultSweepInterval)
+}
+
+// HeartbeatTimeoutFromEnv returns the heartbeat timeout from
+// SESSION_HEARTBEAT_TIMEOUT
+func HeartbeatTimeoutFromEnv() (time.Duration, error) {
+	return durationFromEnv("SESSION_HEARTBEAT_TIMEOUT", DefaultHeartbeatTimeout)
+}
+
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return d, nil
+}
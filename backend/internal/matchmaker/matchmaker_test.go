@@ -0,0 +1,217 @@
+package matchmaker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/store"
+	"github.com/scruffyprodigy/playhub/internal/store/memory"
+)
+
+func TestRunFormsSessionsWithinPlayerLimits(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	game := &store.Game{Name: "Chess", MinPlayers: 2, MaxPlayers: 3}
+	paused := &store.Game{Name: "Go", MinPlayers: 1, MaxPlayers: 2, Status: store.GameMaintenance}
+	for _, g := range []*store.Game{game, paused} {
+		if err := st.Games.Create(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 7 {
+		if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: fmt.Sprintf("u%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: paused.ID, UserID: "u0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := New(st, notify.NewHub()).Run(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("Run = %d, %v; expected 2 sessions", n, err)
+	}
+
+	// The six longest waiting players fill two sessions
+	if _, err := st.Queues.Waiting(ctx, game.ID, "u6"); err != nil {
+		t.Fatalf("Expected the last player to keep waiting, got %v", err)
+	}
+	for i := range 6 {
+		if _, err := st.Queues.Waiting(ctx, game.ID, fmt.Sprintf("u%d", i)); err == nil {
+			t.Errorf("Expected u%d to be matched", i)
+		}
+	}
+	sessions, err := st.Sessions.ListByGame(ctx, game.ID, store.SessionPending, store.Page{})
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Expected two pending sessions, got %d (%v)", len(sessions), err)
+	}
+	if _, err := st.Queues.Waiting(ctx, paused.ID, "u0"); err != nil {
+		t.Errorf("Expected the queue of a game under maintenance to be left alone, got %v", err)
+	}
+
+	// Nothing left to match
+	if n, err := New(st, notify.NewHub()).Run(ctx); err != nil || n != 0 {
+		t.Errorf("Run = %d, %v; expected no new sessions", n, err)
+	}
+}
+
+func TestSweepExpiresStaleEntries(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	game := &store.Game{Name: "Chess", MinPlayers: 2, MaxPlayers: 2}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+	for user, expires := range map[string]*time.Time{"stale": &past, "fresh": &future, "forever": nil} {
+		if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: user, ExpiresAt: expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hub := notify.NewHub()
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := hub.Subscribe(subCtx, "stale")
+
+	n, err := New(st, hub).Sweep(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Sweep = %d, %v; expected 1", n, err)
+	}
+	if _, err := st.Queues.Waiting(ctx, game.ID, "stale"); err == nil {
+		t.Error("Expected the stale entry to leave the queue")
+	}
+	for _, user := range []string{"fresh", "forever"} {
+		if _, err := st.Queues.Waiting(ctx, game.ID, user); err != nil {
+			t.Errorf("Expected %s to keep waiting, got %v", user, err)
+		}
+	}
+	select {
+	case e := <-events:
+		if e.Type != notify.QueueExpired || e.GameID != game.ID {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a queueExpired event")
+	}
+}
+
+func TestSweepPresenceDropsSilentPlayers(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	game := &store.Game{Name: "Chess", MinPlayers: 1, MaxPlayers: 2}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	var userIDs []string
+	for _, name := range []string{"silent", "loading"} {
+		u := &store.User{Email: name + "@example.com", Username: name}
+		if err := st.Users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, u.ID)
+	}
+	session := &store.Session{GameID: game.ID}
+	if err := st.Sessions.Create(ctx, session, userIDs); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Sessions.Presence(ctx, session.ID, store.PresenceJoin, userIDs[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(st, notify.NewHub())
+	if n, err := m.SweepPresence(ctx, time.Minute); err != nil || n != 0 {
+		t.Fatalf("SweepPresence = %d, %v; expected nobody to be dropped yet", n, err)
+	}
+	m.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if n, err := m.SweepPresence(ctx, time.Minute); err != nil || n != 1 {
+		t.Fatalf("SweepPresence = %d, %v; expected 1", n, err)
+	}
+
+	// Players the game server has not seen yet are left alone while the
+	// session is pending
+	participants, err := st.Sessions.Participants(ctx, session.ID)
+	if err != nil || len(participants) != 2 {
+		t.Fatalf("Expected two participants, got %d (%v)", len(participants), err)
+	}
+	if s := participants[0].Status(); s != store.ParticipantLeft {
+		t.Errorf("Expected the silent player to have left, got %s", s)
+	}
+	if s := participants[1].Status(); s != store.ParticipantMatched {
+		t.Errorf("Expected the loading player to stay matched, got %s", s)
+	}
+
+	// and are dropped once the session has been active for the timeout
+	if _, err := st.Sessions.Transition(ctx, session.ID, store.SessionActive); err != nil {
+		t.Fatal(err)
+	}
+	m.now = time.Now
+	if n, err := m.SweepPresence(ctx, time.Minute); err != nil || n != 0 {
+		t.Fatalf("SweepPresence = %d, %v; expected the loading player to get time after the start", n, err)
+	}
+	m.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if n, err := m.SweepPresence(ctx, time.Minute); err != nil || n != 1 {
+		t.Fatalf("SweepPresence = %d, %v; expected the loading player to be dropped", n, err)
+	}
+}
+
+func TestRunBackfillsOpenSlots(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	m := New(st, notify.NewHub())
+	for _, backfill := range []bool{true, false} {
+		game := &store.Game{Name: fmt.Sprintf("Chess %v", backfill), MinPlayers: 2, MaxPlayers: 2, Backfill: backfill}
+		if err := st.Games.Create(ctx, game); err != nil {
+			t.Fatal(err)
+		}
+		for _, user := range []string{"u0", "u1"} {
+			if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: user}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n, err := m.Run(ctx); err != nil || n != 1 {
+			t.Fatalf("Run = %d, %v; expected 1 session", n, err)
+		}
+		session, err := st.Sessions.Open(ctx, game.ID, "u0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := st.Sessions.Transition(ctx, session.ID, store.SessionActive); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Sessions.Presence(ctx, session.ID, store.PresenceLeave, []string{"u1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: "u2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// A lone player cannot form a session, but takes the place u1 left
+		if n, err := m.Run(ctx); err != nil || n != 0 {
+			t.Fatalf("Run = %d, %v; expected no new sessions", n, err)
+		}
+		_, waitErr := st.Queues.Waiting(ctx, game.ID, "u2")
+		if !backfill {
+			if waitErr != nil {
+				t.Errorf("Expected u2 to keep waiting without backfill, got %v", waitErr)
+			}
+			continue
+		}
+		if waitErr == nil {
+			t.Fatal("Expected u2 to be matched")
+		}
+		filled, err := st.Sessions.Open(ctx, game.ID, "u2")
+		if err != nil || filled.ID != session.ID {
+			t.Fatalf("Expected u2 in session %s, got %+v (%v)", session.ID, filled, err)
+		}
+		if filled.OpenSlots != 0 {
+			t.Errorf("Expected the session to be full, got %d open slots", filled.OpenSlots)
+		}
+		if _, err := st.Sessions.Open(ctx, game.ID, "u1"); err == nil {
+			t.Error("Expected u1 to have no open session after leaving")
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package matchmaker
+
+import (
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// Rules decide which waiting players may share a session. Criteria relax as
+// players wait: the allowed skill gap widens every RelaxInterval, and
+// language and region stop mattering after LanguageAfter and RegionAfter.
+// A pair relaxes as far as its longer waiting player allows. Modes must
+// always agree.
+type Rules struct {
+	SkillWindow   int // rating gap allowed right after joining
+	SkillWidening int // added to the gap every RelaxInterval
+	RelaxInterval time.Duration
+	LanguageAfter time.Duration
+	RegionAfter   time.Duration
+}
+
+// DefaultRules are used unless the matchmaker is given others
+var DefaultRules = Rules{
+	SkillWindow:   100,
+	SkillWidening: 50,
+	RelaxInterval: 15 * time.Second,
+	LanguageAfter: time.Minute,
+	RegionAfter:   2 * time.Minute,
+}
+
+// Compatible reports whether a and b may play together at now
+func (r Rules) Compatible(a, b *store.QueueEntry, now time.Time) bool {
+	waited := max(now.Sub(a.JoinedAt), now.Sub(b.JoinedAt))
+	pa, pb := a.Preferences, b.Preferences
+
+	if !agree(pa.Mode, pb.Mode) {
+		return false
+	}
+	if waited < r.LanguageAfter && !agree(pa.Language, pb.Language) {
+		return false
+	}
+	if waited < r.RegionAfter && !agree(pa.Region, pb.Region) {
+		return false
+	}
+	if pa.SkillRating != nil && pb.SkillRating != nil {
+		gap := *pa.SkillRating - *pb.SkillRating
+		if gap < 0 {
+			gap = -gap
+		}
+		allowed := r.SkillWindow
+		if r.RelaxInterval > 0 {
+			allowed += r.SkillWidening * int(waited/r.RelaxInterval)
+		}
+		if gap > allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// Pick chooses the next batch from waiting, which is in queue order. A party
+// is a unit placed whole, at the place of its first member, and only once all
+// its members are waiting; solo players are units of one. Each unit in turn
+// anchors a batch that takes, in queue order, every later unit whose members
+// are compatible with all players already in it, up to maxPlayers. Members
+// of one party need not be compatible with each other. The first batch
+// reaching minPlayers wins, so higher priority and longer waiting players
+// match first. Pick returns nil when no batch is big enough.
+func (r Rules) Pick(waiting []*store.QueueEntry, minPlayers, maxPlayers int, now time.Time) []*store.QueueEntry {
+	minPlayers = max(minPlayers, 1)
+	units := units(waiting, maxPlayers)
+	for i, anchor := range units {
+		batch := append([]*store.QueueEntry(nil), anchor...)
+		for _, unit := range units[i+1:] {
+			if len(batch) == maxPlayers {
+				break
+			}
+			if len(batch)+len(unit) <= maxPlayers && r.fits(unit, batch, now) {
+				batch = append(batch, unit...)
+			}
+		}
+		if len(batch) >= minPlayers {
+			return batch
+		}
+	}
+	return nil
+}
+
+// Fill chooses the waiting players who take up to slots open places in a
+// session alongside players. Units are taken in queue order while they fit,
+// as in Pick. Players already in the session count as just joined, so only
+// how long the newcomers waited relaxes the rules. Fill returns nil when no
+// unit fits.
+func (r Rules) Fill(players, waiting []*store.QueueEntry, slots int, now time.Time) []*store.QueueEntry {
+	batch := make([]*store.QueueEntry, len(players))
+	for i, p := range players {
+		cp := *p
+		cp.JoinedAt = now
+		batch[i] = &cp
+	}
+	var out []*store.QueueEntry
+	for _, unit := range units(waiting, slots) {
+		if len(out) == slots {
+			break
+		}
+		if len(out)+len(unit) <= slots && r.fits(unit, batch, now) {
+			batch = append(batch, unit...)
+			out = append(out, unit...)
+		}
+	}
+	return out
+}
+
+// units groups waiting into the units Pick places, dropping parties with
+// members missing or more members than maxPlayers
+func units(waiting []*store.QueueEntry, maxPlayers int) [][]*store.QueueEntry {
+	var out [][]*store.QueueEntry
+	at := make(map[string]int)
+	for _, e := range waiting {
+		if e.PartyID == "" {
+			out = append(out, []*store.QueueEntry{e})
+			continue
+		}
+		if i, ok := at[e.PartyID]; ok {
+			out[i] = append(out[i], e)
+			continue
+		}
+		at[e.PartyID] = len(out)
+		out = append(out, []*store.QueueEntry{e})
+	}
+	complete := out[:0]
+	for _, unit := range out {
+		if size := max(unit[0].PartySize, 1); len(unit) == size && size <= maxPlayers {
+			complete = append(complete, unit)
+		}
+	}
+	return complete
+}
+
+// fits reports whether every member of unit is compatible with every player
+// in batch
+func (r Rules) fits(unit, batch []*store.QueueEntry, now time.Time) bool {
+	for _, e := range unit {
+		for _, b := range batch {
+			if !r.Compatible(e, b, now) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// agree reports whether two preferences match; an empty one matches anything
+func agree(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
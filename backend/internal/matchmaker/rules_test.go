@@ -0,0 +1,139 @@
+package matchmaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+func rating(n int) *int { return &n }
+
+func TestCompatibleRelaxesWithWaiting(t *testing.T) {
+	now := time.Now()
+	entry := func(waited time.Duration, prefs store.QueuePreferences) *store.QueueEntry {
+		return &store.QueueEntry{JoinedAt: now.Add(-waited), Preferences: prefs}
+	}
+	eu := store.QueuePreferences{Region: "eu", Language: "de", Mode: "ranked", SkillRating: rating(1500)}
+	us := store.QueuePreferences{Region: "us", Language: "en", Mode: "ranked", SkillRating: rating(1500)}
+
+	tests := []struct {
+		name string
+		a, b *store.QueueEntry
+		want bool
+	}{
+		{"unset preferences match anyone", entry(0, eu), entry(0, store.QueuePreferences{}), true},
+		{"modes never relax", entry(time.Hour, eu), entry(0, store.QueuePreferences{Mode: "casual"}), false},
+		{"regions differ at first", entry(0, eu), entry(0, us), false},
+		{"language relaxes before region", entry(90*time.Second, eu), entry(0, store.QueuePreferences{Region: "eu", Language: "en"}), true},
+		{"region relaxes last", entry(90*time.Second, eu), entry(0, us), false},
+		{"everything but mode relaxes eventually", entry(2*time.Minute, eu), entry(0, us), true},
+		{"skill gap within window", entry(0, store.QueuePreferences{SkillRating: rating(1500)}), entry(0, store.QueuePreferences{SkillRating: rating(1600)}), true},
+		{"skill gap too wide", entry(0, store.QueuePreferences{SkillRating: rating(1500)}), entry(0, store.QueuePreferences{SkillRating: rating(1700)}), false},
+		{"skill gap widens with waiting", entry(30*time.Second, store.QueuePreferences{SkillRating: rating(1500)}), entry(0, store.QueuePreferences{SkillRating: rating(1700)}), true},
+	}
+	for _, tt := range tests {
+		if got := DefaultRules.Compatible(tt.a, tt.b, now); got != tt.want {
+			t.Errorf("%s: Compatible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	now := time.Now()
+	entry := func(id string, priority int, region string) *store.QueueEntry {
+		return &store.QueueEntry{ID: id, Priority: priority, JoinedAt: now, Preferences: store.QueuePreferences{Region: region}}
+	}
+	ids := func(batch []*store.QueueEntry) string {
+		s := ""
+		for _, e := range batch {
+			s += e.ID
+		}
+		return s
+	}
+
+	// The lone EU player cannot anchor a pair, so the US players match
+	waiting := []*store.QueueEntry{entry("a", 5, "eu"), entry("b", 0, "us"), entry("c", 0, "us"), entry("d", 0, "us")}
+	if got := ids(DefaultRules.Pick(waiting, 2, 2, now)); got != "bc" {
+		t.Errorf("Pick = %q, want bc", got)
+	}
+	// A compatible priority player leads the batch
+	waiting[0].Preferences.Region = "us"
+	if got := ids(DefaultRules.Pick(waiting, 2, 3, now)); got != "abc" {
+		t.Errorf("Pick = %q, want abc", got)
+	}
+	if got := DefaultRules.Pick(waiting[:1], 2, 4, now); got != nil {
+		t.Errorf("Expected no batch below minPlayers, got %q", ids(got))
+	}
+}
+
+func TestPickParties(t *testing.T) {
+	now := time.Now()
+	member := func(id, party string, size int, region string) *store.QueueEntry {
+		return &store.QueueEntry{ID: id, PartyID: party, PartySize: size, JoinedAt: now,
+			Preferences: store.QueuePreferences{Region: region}}
+	}
+	ids := func(batch []*store.QueueEntry) string {
+		s := ""
+		for _, e := range batch {
+			s += e.ID
+		}
+		return s
+	}
+
+	// The party is placed whole at its first member, even across regions
+	waiting := []*store.QueueEntry{
+		member("a", "p", 2, "eu"), member("b", "", 1, "eu"), member("c", "p", 2, "us"), member("d", "", 1, "us"),
+	}
+	if got := ids(DefaultRules.Pick(waiting, 2, 4, now)); got != "ac" {
+		t.Errorf("Pick = %q, want ac", got)
+	}
+	// A party that does not fit the remaining slots is skipped
+	waiting[0].Preferences.Region, waiting[1].Preferences.Region = "", ""
+	if got := ids(DefaultRules.Pick(waiting, 2, 2, now)); got != "ac" {
+		t.Errorf("Pick = %q, want ac", got)
+	}
+	if got := ids(DefaultRules.Pick(waiting, 2, 3, now)); got != "acb" {
+		t.Errorf("Pick = %q, want acb", got)
+	}
+	// Incomplete parties and parties over maxPlayers wait
+	if got := ids(DefaultRules.Pick(waiting[:2], 1, 4, now)); got != "b" {
+		t.Errorf("Pick = %q, want b", got)
+	}
+	if got := ids(DefaultRules.Pick(waiting, 1, 1, now)); got != "b" {
+		t.Errorf("Pick = %q, want b", got)
+	}
+}
+
+func TestFill(t *testing.T) {
+	now := time.Now()
+	entry := func(id, party string, size int, region string) *store.QueueEntry {
+		return &store.QueueEntry{ID: id, PartyID: party, PartySize: size, JoinedAt: now,
+			Preferences: store.QueuePreferences{Region: region}}
+	}
+	ids := func(batch []*store.QueueEntry) string {
+		s := ""
+		for _, e := range batch {
+			s += e.ID
+		}
+		return s
+	}
+
+	// A player who waited long in the session does not relax the rules
+	players := []*store.QueueEntry{entry("x", "", 1, "eu")}
+	players[0].JoinedAt = now.Add(-time.Hour)
+	waiting := []*store.QueueEntry{entry("a", "", 1, "us"), entry("b", "p", 2, "eu"), entry("c", "p", 2, "eu"), entry("d", "", 1, "")}
+	if got := ids(DefaultRules.Fill(players, waiting, 3, now)); got != "bcd" {
+		t.Errorf("Fill = %q, want bcd", got)
+	}
+	// Units that do not fit the open slots are skipped
+	if got := ids(DefaultRules.Fill(players, waiting, 1, now)); got != "d" {
+		t.Errorf("Fill = %q, want d", got)
+	}
+	if got := DefaultRules.Fill(players, waiting[:1], 2, now); got != nil {
+		t.Errorf("Expected nobody to fit, got %q", ids(got))
+	}
+	if players[0].JoinedAt.Equal(now) {
+		t.Error("Expected Fill to leave the session players untouched")
+	}
+}
@@ -0,0 +1,81 @@
+// Package notify delivers events about a user to that user's open
+// subscriptions. Events are published through Postgres NOTIFY when the
+// processes share a database, so a matchmaker or another replica can reach
+// users connected to this server.
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	QueueExpired = "queue_expired"
+)
+
+// Event is something that happened to a user
+type Event struct {
+	Type   string    `json:"type"`
+	UserID string    `json:"userId"`
+	GameID string    `json:"gameId,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Publisher sends events towards subscribers
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events to it are dropped
+const subscriberBuffer = 16
+
+// Hub fans events out to the subscriptions open in this process
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{}
+}
+
+// NewHub returns a hub without subscriptions
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe returns the events of userID until ctx is done, when the channel
+// is closed
+func (h *Hub) Subscribe(ctx context.Context, userID string) <-chan Event {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan Event]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs[userID], ch)
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+		h.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish delivers e to the subscriptions of its user without blocking
+func (h *Hub) Publish(_ context.Context, e Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHubDeliversToTheUsersSubscriptions(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	mine := hub.Subscribe(ctx, "u1")
+	other := hub.Subscribe(ctx, "u2")
+
+	hub.Publish(ctx, Event{Type: QueueExpired, UserID: "u1", GameID: "g1"})
+	select {
+	case e := <-mine:
+		if e.Type != QueueExpired || e.GameID != "g1" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event to be delivered")
+	}
+	select {
+	case e := <-other:
+		t.Errorf("Expected no event for another user, got %+v", e)
+	default:
+	}
+
+	// A slow subscriber does not block publishing
+	for range subscriberBuffer + 1 {
+		hub.Publish(ctx, Event{Type: QueueExpired, UserID: "u2"})
+	}
+
+	cancel()
+	for range mine {
+	}
+}
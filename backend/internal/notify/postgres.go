@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// channel is the Postgres NOTIFY channel carrying events
+const channel = "playhub_events"
+
+// PostgresPublisher publishes events with NOTIFY to every process listening
+// on the database
+type PostgresPublisher struct {
+	db *sql.DB
+}
+
+// NewPostgresPublisher returns a publisher notifying through db
+func NewPostgresPublisher(db *sql.DB) *PostgresPublisher {
+	return &PostgresPublisher{db: db}
+}
+
+// Publish sends e to the listeners of the database
+func (p *PostgresPublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Listen relays the events published on the database at databaseURL to hub
+// until ctx is done. Events published while the connection is down are lost.
+func Listen(ctx context.Context, databaseURL string, hub *Hub) error {
+	l := pq.NewListener(databaseURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("notify: %v", err)
+		}
+	})
+	defer l.Close()
+	if err := l.Listen(channel); err != nil {
+		return fmt.Errorf("failed to listen for events: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-l.Notify:
+			// A nil notification follows a reconnect
+			if n == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("notify: invalid event %q: %v", n.Extra, err)
+				continue
+			}
+			hub.Publish(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps hits in process memory. Limits are per replica and reset
+// on restart; use PostgresStore when running several backends.
+type MemoryStore struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{hits: make(map[string][]time.Time)}
+}
+
+// Hit implements Store
+func (s *MemoryStore) Hit(_ context.Context, key string, now time.Time, window time.Duration, limit int) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hits := trim(s.hits[key], now.Add(-window))
+	prior, oldest := len(hits), now
+	if prior > 0 {
+		oldest = hits[0]
+	}
+	if prior < limit {
+		hits = append(hits, now)
+	}
+	if len(hits) == 0 {
+		delete(s.hits, key)
+	} else {
+		s.hits[key] = hits
+	}
+	return prior, oldest, nil
+}
+
+// Prune drops hits at or before cutoff and forgets keys without hits
+func (s *MemoryStore) Prune(_ context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for key, hits := range s.hits {
+		kept := trim(hits, cutoff)
+		n += int64(len(hits) - len(kept))
+		if len(kept) == 0 {
+			delete(s.hits, key)
+		} else {
+			s.hits[key] = kept
+		}
+	}
+	return n, nil
+}
+
+// trim returns the suffix of hits (oldest first) that is after cutoff
+func trim(hits []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
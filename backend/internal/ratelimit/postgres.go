@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps hits in the rate_limit_hits table so that limits hold
+// across backend replicas
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store backed by db
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Hit implements Store
+func (s *PostgresStore) Hit(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (int, time.Time, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Hits of one key take turns so that concurrent requests cannot all
+	// count the same earlier hits and pass the limit together
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to lock rate limit key: %w", err)
+	}
+
+	var (
+		prior  int
+		oldest time.Time
+	)
+	err = tx.QueryRowContext(ctx, `
+		WITH prior AS (
+			SELECT COUNT(*) AS n, MIN(hit_at) AS oldest
+			FROM rate_limit_hits
+			WHERE key = $1 AND hit_at > $3
+		), hit AS (
+			INSERT INTO rate_limit_hits (key, hit_at)
+			SELECT $1, $2 FROM prior WHERE n < $4
+		)
+		SELECT n, COALESCE(oldest, $2) FROM prior`,
+		key, now, now.Add(-window), limit).Scan(&prior, &oldest)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to record hit: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to record hit: %w", err)
+	}
+	return prior, oldest, nil
+}
+
+// Prune deletes hits at or before cutoff
+func (s *PostgresStore) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_hits WHERE hit_at <= $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune hits: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,87 @@
+// Package ratelimit implements sliding-window rate limits over a pluggable
+// hit store
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Store records hits per key. Implementations must be safe for concurrent use.
+type Store interface {
+	// Hit records a hit for key at now unless key already has limit hits
+	// within (now-window, now]. It returns the number of those earlier hits
+	// and the oldest of them, or now when there are none.
+	Hit(ctx context.Context, key string, now time.Time, window time.Duration, limit int) (prior int, oldest time.Time, err error)
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter allows at most limit hits per key within any window-long period
+type Limiter struct {
+	store  Store
+	prefix string
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// New creates a limiter. prefix namespaces keys so several limiters can share
+// a store.
+func New(store Store, prefix string, limit int, window time.Duration) *Limiter {
+	return &Limiter{store: store, prefix: prefix, limit: limit, window: window, now: time.Now}
+}
+
+// Allow records a hit for key and reports whether it is within the limit.
+// Only allowed hits are recorded, so a key recovers one window after its
+// last allowed hit however often it is tried meanwhile. Otherwise anyone
+// could keep someone else's key, such as their email address, blocked.
+func (l *Limiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := l.now()
+	prior, oldest, err := l.store.Hit(ctx, l.prefix+":"+key, now, l.window, l.limit)
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit %s: %w", l.prefix, err)
+	}
+	if prior < l.limit {
+		return Decision{Allowed: true, Remaining: l.limit - prior - 1}, nil
+	}
+	retry := oldest.Add(l.window).Sub(now)
+	if retry < 0 {
+		retry = 0
+	}
+	return Decision{RetryAfter: retry}, nil
+}
+
+// Config describes one limit
+type Config struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ConfigFromEnv reads <prefix>_LIMIT and <prefix>_WINDOW, falling back to def
+func ConfigFromEnv(prefix string, def Config) (Config, error) {
+	cfg := def
+	if v := os.Getenv(prefix + "_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid %s_LIMIT %q", prefix, v)
+		}
+		cfg.Limit = n
+	}
+	if v := os.Getenv(prefix + "_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %s_WINDOW %q", prefix, v)
+		}
+		cfg.Window = d
+	}
+	return cfg, nil
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(NewMemoryStore(), "test", 2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		d, err := l.Allow(ctx, "a")
+		if err != nil || !d.Allowed {
+			t.Fatalf("Hit %d: expected allowed, got %+v (%v)", i+1, d, err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	d, _ := l.Allow(ctx, "a")
+	if d.Allowed {
+		t.Fatal("Expected third hit within the window to be rejected")
+	}
+	if d.RetryAfter != 40*time.Second {
+		t.Errorf("Expected retry after 40s, got %s", d.RetryAfter)
+	}
+
+	// Other keys are limited independently
+	if d, _ := l.Allow(ctx, "b"); !d.Allowed {
+		t.Error("Expected a different key to be allowed")
+	}
+
+	// Once the window has slid past every hit the key is allowed again
+	now = now.Add(time.Minute + time.Second)
+	if d, _ := l.Allow(ctx, "a"); !d.Allowed || d.Remaining != 1 {
+		t.Errorf("Expected key to be allowed after the window, got %+v", d)
+	}
+}
+
+func TestLimiterIgnoresRejectedHits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(NewMemoryStore(), "test", 2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if d, err := l.Allow(ctx, "a"); err != nil || !d.Allowed {
+			t.Fatalf("Hit %d: expected allowed, got %+v (%v)", i+1, d, err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	lastAllowed := now.Add(-10 * time.Second)
+
+	// Hammering a blocked key does not keep it blocked
+	for i := 0; i < 4; i++ {
+		if d, _ := l.Allow(ctx, "a"); d.Allowed {
+			t.Fatalf("Expected hit %d over the limit to be rejected", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	now = lastAllowed.Add(time.Minute)
+	if d, _ := l.Allow(ctx, "a"); !d.Allowed || d.Remaining != 1 {
+		t.Errorf("Expected the key to recover a window after its last allowed hit, got %+v", d)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	now := time.Now()
+	s.Hit(ctx, "a", now.Add(-2*time.Hour), time.Hour, 1)
+	s.Hit(ctx, "b", now, time.Hour, 1)
+
+	n, err := s.Prune(ctx, now.Add(-time.Hour))
+	if err != nil || n != 1 {
+		t.Errorf("Expected one pruned hit, got %d (%v)", n, err)
+	}
+	if _, ok := s.hits["a"]; ok {
+		t.Error("Expected key without hits to be forgotten")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("TEST_RATE_LIMIT", "3")
+	t.Setenv("TEST_RATE_WINDOW", "10m")
+	cfg, err := ConfigFromEnv("TEST_RATE", Config{Limit: 1, Window: time.Hour})
+	if err != nil || cfg.Limit != 3 || cfg.Window != 10*time.Minute {
+		t.Errorf("Unexpected config %+v (%v)", cfg, err)
+	}
+
+	t.Setenv("TEST_RATE_LIMIT", "zero")
+	if _, err := ConfigFromEnv("TEST_RATE", Config{}); err == nil {
+		t.Error("Expected error for invalid limit")
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set, skipping rate limit database test")
+	}
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	l := New(NewPostgresStore(db), "test-"+strconv.FormatInt(time.Now().UnixNano(), 36), 2, time.Minute)
+	for i := 0; i < 2; i++ {
+		if d, err := l.Allow(ctx, "a"); err != nil || !d.Allowed {
+			t.Fatalf("Hit %d: expected allowed, got %+v (%v)", i+1, d, err)
+		}
+	}
+	if d, err := l.Allow(ctx, "a"); err != nil || d.Allowed || d.RetryAfter <= 0 {
+		t.Errorf("Expected third hit to be rejected, got %+v (%v)", d, err)
+	}
+
+	// Concurrent hits of one key never pass the limit together
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := l.Allow(ctx, "b")
+			if err != nil {
+				t.Errorf("Allow failed: %v", err)
+			} else if d.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 2 {
+		t.Errorf("Expected 2 of 10 concurrent hits allowed, got %d", n)
+	}
+}
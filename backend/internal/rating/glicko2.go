@@ -0,0 +1,130 @@
+// Package rating implements the Glicko-2 skill rating system and updates the
+// per-game ratings of players from session results
+package rating
+
+import "math"
+
+// scale converts between the Glicko and Glicko-2 scales
+const scale = 173.7178
+
+// tau constrains how fast volatility changes; Glickman suggests 0.3-1.2
+const tau = 0.5
+
+// epsilon is the convergence tolerance of the volatility iteration
+const epsilon = 0.000001
+
+// Rating is a Glicko-2 rating on the familiar Glicko scale
+type Rating struct {
+	Value      float64 // 1500 for a new player
+	Deviation  float64 // uncertainty of Value; shrinks as the player plays
+	Volatility float64 // expected fluctuation of Value
+}
+
+// Default is the rating of a player who has not played yet
+var Default = Rating{Value: 1500, Deviation: 350, Volatility: 0.06}
+
+// Result is the outcome of one game against an opponent: 1 for a win, 0.5
+// for a draw and 0 for a loss
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns r after the results of one rating period. Without results
+// only the deviation grows.
+func Update(r Rating, results []Result) Rating {
+	mu := (r.Value - 1500) / scale
+	phi := r.Deviation / scale
+	if len(results) == 0 {
+		r.Deviation = math.Sqrt(phi*phi+r.Volatility*r.Volatility) * scale
+		return r
+	}
+
+	var vInv, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Value - 1500) / scale
+		g := g(res.Opponent.Deviation / scale)
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma := volatility(phi, r.Volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Rating{Value: muNew*scale + 1500, Deviation: phiNew * scale, Volatility: sigma}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// volatility finds the new volatility with the Illinois algorithm of step 5
+// of Glickman's paper
+func volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// Placements updates the ratings of the players of one session from their
+// placements, 1 being best. Each player is scored against every other as a
+// win, draw or loss by placement, all against the ratings before the session.
+// Players missing from ratings start from Default.
+func Placements(ratings map[string]Rating, placements map[string]int) map[string]Rating {
+	before := func(id string) Rating {
+		if r, ok := ratings[id]; ok {
+			return r
+		}
+		return Default
+	}
+	out := make(map[string]Rating, len(placements))
+	for id, place := range placements {
+		var results []Result
+		for other, otherPlace := range placements {
+			if other == id {
+				continue
+			}
+			score := 0.5
+			if place < otherPlace {
+				score = 1
+			} else if place > otherPlace {
+				score = 0
+			}
+			results = append(results, Result{Opponent: before(other), Score: score})
+		}
+		out[id] = Update(before(id), results)
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package rating
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+	"github.com/scruffyprodigy/playhub/internal/store/memory"
+)
+
+func TestUpdateMatchesGlickmanExample(t *testing.T) {
+	// The worked example of Glickman's "Example of the Glicko-2 system"
+	player := Rating{Value: 1500, Deviation: 200, Volatility: 0.06}
+	got := Update(player, []Result{
+		{Opponent: Rating{Value: 1400, Deviation: 30, Volatility: 0.06}, Score: 1},
+		{Opponent: Rating{Value: 1550, Deviation: 100, Volatility: 0.06}, Score: 0},
+		{Opponent: Rating{Value: 1700, Deviation: 300, Volatility: 0.06}, Score: 0},
+	})
+	for _, c := range []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", got.Value, 1464.06, 0.01},
+		{"deviation", got.Deviation, 151.52, 0.01},
+		{"volatility", got.Volatility, 0.05999, 0.00001},
+	} {
+		if math.Abs(c.got-c.want) > c.tolerance {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	idle := Update(player, nil)
+	if idle.Value != player.Value || idle.Deviation <= player.Deviation {
+		t.Errorf("Expected only the deviation to grow without results, got %+v", idle)
+	}
+}
+
+func TestRecordRanksByPlacement(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	game := &store.Game{Name: "Chess"}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		u := &store.User{Email: name + "@example.com", Username: name, DisplayName: name}
+		if err := st.Users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+
+	if err := Record(ctx, st.Ratings, game.ID, map[string]int{ids[0]: 1, ids[1]: 2, ids[2]: 3}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	var values []float64
+	for _, id := range ids {
+		r, err := st.Ratings.Get(ctx, id, game.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if r.GamesPlayed != 1 || r.Deviation >= Default.Deviation {
+			t.Errorf("Expected one game and a smaller deviation, got %+v", r)
+		}
+		values = append(values, r.Value)
+	}
+	if !(values[0] > Default.Value && Default.Value == math.Round(values[1]) && values[2] < Default.Value) {
+		t.Errorf("Expected ratings ordered by placement around %v, got %v", Default.Value, values)
+	}
+
+	if err := Record(ctx, st.Ratings, game.ID, map[string]int{ids[0]: 1}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if r, _ := st.Ratings.Get(ctx, ids[0], game.ID); r.GamesPlayed != 1 {
+		t.Errorf("Expected a solo session to leave ratings alone, got %d games", r.GamesPlayed)
+	}
+}
@@ -0,0 +1,53 @@
+package rating
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// Record updates the ratings for gameID of the players of one session from
+// their placements, 1 being best. Sessions of fewer than two players leave
+// ratings alone.
+func Record(ctx context.Context, ratings store.Ratings, gameID string, placements map[string]int) error {
+	update := Rater(gameID, placements)
+	if update == nil {
+		return nil
+	}
+	return ratings.Update(ctx, gameID, slices.Sorted(maps.Keys(placements)), update)
+}
+
+// Rater returns the update Record applies, for passing to Sessions.Complete.
+// It is nil for sessions of fewer than two players.
+func Rater(gameID string, placements map[string]int) store.RatingUpdate {
+	if len(placements) < 2 {
+		return nil
+	}
+	return func(current map[string]*store.Rating) []*store.Rating {
+		before := make(map[string]Rating, len(current))
+		for id, r := range current {
+			before[id] = Rating{Value: r.Value, Deviation: r.Deviation, Volatility: r.Volatility}
+		}
+		after := Placements(before, placements)
+
+		userIDs := slices.Sorted(maps.Keys(placements))
+		out := make([]*store.Rating, len(userIDs))
+		for i, id := range userIDs {
+			played := 0
+			if r, ok := current[id]; ok {
+				played = r.GamesPlayed
+			}
+			out[i] = &store.Rating{
+				UserID:      id,
+				GameID:      gameID,
+				Value:       after[id].Value,
+				Deviation:   after[id].Deviation,
+				Volatility:  after[id].Volatility,
+				GamesPlayed: played + 1,
+			}
+		}
+		return out
+	}
+}
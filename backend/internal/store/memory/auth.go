@@ -0,0 +1,274 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+type magicLink struct {
+	email     string
+	expiresAt time.Time
+	used      bool
+}
+
+type authSession struct {
+	store.AuthSession
+	tokenHash string
+	revoked   bool
+}
+
+type apiKey struct {
+	store.APIKey
+	keyHash string
+}
+
+type magicLinks struct{ *data }
+
+// Create never fails with ErrDisabled: memory accounts cannot be deactivated
+func (r *magicLinks) Create(_ context.Context, email, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.links[tokenHash] = &magicLink{email: email, expiresAt: expiresAt}
+	return nil
+}
+
+// Redeem never fails with ErrDisabled, like Create
+func (r *magicLinks) Redeem(_ context.Context, tokenHash string, newUser func(email string) (*store.User, error)) (*store.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.links[tokenHash]
+	if !ok || l.used || !l.expiresAt.After(r.now()) {
+		return nil, store.ErrNotFound
+	}
+
+	var user *store.User
+	for _, u := range r.users {
+		if u.Email == l.email {
+			user = u
+		}
+	}
+	if user == nil {
+		fresh, err := newUser(l.email)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range r.users {
+			if u.Username == fresh.Username {
+				return nil, store.ErrConflict
+			}
+		}
+		user = &store.User{
+			ID:          newID(),
+			Email:       l.email,
+			Username:    fresh.Username,
+			DisplayName: fresh.DisplayName,
+			CreatedAt:   r.now(),
+		}
+		r.users[user.ID] = user
+	}
+	l.used = true
+	cp := *user
+	return &cp, nil
+}
+
+func (r *magicLinks) DeleteExpired(_ context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for hash, l := range r.links {
+		if l.expiresAt.Before(cutoff) {
+			delete(r.links, hash)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type authSessions struct{ *data }
+
+func (r *authSessions) Create(_ context.Context, s *store.AuthSession, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[s.UserID]; !ok {
+		return store.ErrNotFound
+	}
+	s.ID = newID()
+	r.authSessions[s.ID] = &authSession{AuthSession: *s, tokenHash: tokenHash}
+	return nil
+}
+
+func (r *authSessions) Rotate(_ context.Context, oldHash, newHash string, expiresAt time.Time) (*store.AuthSession, *store.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.byToken(oldHash)
+	if s == nil || !r.live(s) {
+		return nil, nil, store.ErrNotFound
+	}
+	u, ok := r.users[s.UserID]
+	if !ok {
+		return nil, nil, store.ErrNotFound
+	}
+	s.tokenHash = newHash
+	s.ExpiresAt = expiresAt
+	cs, cu := s.AuthSession, *u
+	return &cs, &cu, nil
+}
+
+func (r *authSessions) Revoke(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.authSessions[id]; ok {
+		s.revoked = true
+	}
+	return nil
+}
+
+func (r *authSessions) RevokeByToken(_ context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.byToken(tokenHash); s != nil {
+		s.revoked = true
+	}
+	return nil
+}
+
+func (r *authSessions) RevokeAllForUser(_ context.Context, userID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, s := range r.authSessions {
+		if s.UserID == userID && !s.revoked {
+			s.revoked = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *authSessions) IsActive(_ context.Context, id string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.authSessions[id]
+	return ok && r.live(s), nil
+}
+
+func (r *authSessions) byToken(tokenHash string) *authSession {
+	for _, s := range r.authSessions {
+		if s.tokenHash == tokenHash {
+			return s
+		}
+	}
+	return nil
+}
+
+func (r *authSessions) live(s *authSession) bool {
+	return !s.revoked && s.ExpiresAt.After(r.now())
+}
+
+type roles struct{ *data }
+
+func (r *roles) ForUser(_ context.Context, userID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := slices.Clone(r.roles[userID])
+	slices.Sort(out)
+	return out, nil
+}
+
+func (r *roles) Grant(_ context.Context, userID, role, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[userID]; !ok {
+		return store.ErrNotFound
+	}
+	if !slices.Contains(r.roles[userID], role) {
+		r.roles[userID] = append(r.roles[userID], role)
+	}
+	return nil
+}
+
+func (r *roles) Revoke(_ context.Context, userID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[userID] = slices.DeleteFunc(r.roles[userID], func(held string) bool { return held == role })
+	return nil
+}
+
+type apiKeys struct{ *data }
+
+func (r *apiKeys) Create(_ context.Context, k *store.APIKey, keyHash, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.games[k.GameID]; !ok {
+		return store.ErrNotFound
+	}
+	k.ID = newID()
+	k.CreatedAt = r.now()
+	key := &apiKey{APIKey: *k, keyHash: keyHash}
+	key.Scopes = slices.Clone(k.Scopes)
+	r.apiKeys[k.ID] = key
+	return nil
+}
+
+func (r *apiKeys) Get(_ context.Context, id string) (*store.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, ok := r.apiKeys[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return k.copy(), nil
+}
+
+func (r *apiKeys) List(_ context.Context, gameID string) ([]*store.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.APIKey
+	for _, k := range r.apiKeys {
+		if k.GameID == gameID {
+			out = append(out, k.copy())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return newestFirst(out[i].CreatedAt, out[i].ID, out[j].CreatedAt, out[j].ID)
+	})
+	return out, nil
+}
+
+func (r *apiKeys) Revoke(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.apiKeys[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if k.RevokedAt == nil {
+		now := r.now()
+		k.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *apiKeys) Authenticate(_ context.Context, keyHash string) (*store.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.apiKeys {
+		if k.keyHash == keyHash && k.RevokedAt == nil {
+			if now := r.now(); k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > time.Minute {
+				k.LastUsedAt = &now
+			}
+			return k.copy(), nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (k *apiKey) copy() *store.APIKey {
+	cp := k.APIKey
+	cp.Scopes = slices.Clone(k.Scopes)
+	return &cp
+}
@@ -0,0 +1,1150 @@
+// Package memory implements the store repositories in process memory. Data is
+// lost on restart; it backs tests and local runs without a database.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// data holds every table. All repositories share one lock so cross-table
+// checks see a consistent state.
+type data struct {
+	mu           sync.RWMutex
+	users        map[string]*store.User
+	games        map[string]*store.Game
+	queue        []*store.QueueEntry
+	parties      map[string]*store.Party
+	sessions     map[string]*store.Session
+	participants map[string][]*participant
+	ratings      map[[2]string]*store.Rating
+	goods        map[string]*store.Good
+	inventory    map[[2]string]*store.InventoryItem
+	links        map[string]*magicLink
+	authSessions map[string]*authSession
+	roles        map[string][]string
+	apiKeys      map[string]*apiKey
+	now          func() time.Time
+}
+
+// New returns an empty in-memory store
+func New() store.Store {
+	d := &data{
+		users:        make(map[string]*store.User),
+		games:        make(map[string]*store.Game),
+		parties:      make(map[string]*store.Party),
+		sessions:     make(map[string]*store.Session),
+		participants: make(map[string][]*participant),
+		ratings:      make(map[[2]string]*store.Rating),
+		goods:        make(map[string]*store.Good),
+		inventory:    make(map[[2]string]*store.InventoryItem),
+		links:        make(map[string]*magicLink),
+		authSessions: make(map[string]*authSession),
+		roles:        make(map[string][]string),
+		apiKeys:      make(map[string]*apiKey),
+		now:          time.Now,
+	}
+	return store.Store{
+		Users:     &users{d},
+		Games:     &games{d},
+		Queues:    &queues{d},
+		Parties:   &parties{d},
+		Sessions:  &sessions{d},
+		Ratings:   &ratings{d},
+		Goods:     &goods{d},
+		Inventory: &inventory{d},
+
+		MagicLinks:   &magicLinks{d},
+		AuthSessions: &authSessions{d},
+		Roles:        &roles{d},
+		APIKeys:      &apiKeys{d},
+	}
+}
+
+func newID() string {
+	return uuid.New().String()
+}
+
+type users struct{ *data }
+
+func (r *users) Get(_ context.Context, id string) (*store.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *users) Create(_ context.Context, u *store.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Email == u.Email || existing.Username == u.Username {
+			return store.ErrConflict
+		}
+	}
+	u.ID = newID()
+	u.CreatedAt = r.now()
+	cp := *u
+	r.users[u.ID] = &cp
+	return nil
+}
+
+type games struct{ *data }
+
+func (r *games) Create(_ context.Context, g *store.Game) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.games {
+		if strings.EqualFold(existing.Name, g.Name) {
+			return store.ErrConflict
+		}
+	}
+	g.ID = newID()
+	if g.Status == "" {
+		g.Status = store.GameActive
+	}
+	if g.MinPlayers == 0 {
+		g.MinPlayers = 1
+	}
+	if g.MaxPlayers == 0 {
+		g.MaxPlayers = 4
+	}
+	g.CreatedAt = r.now()
+	g.UpdatedAt = g.CreatedAt
+	cp := *g
+	r.games[g.ID] = &cp
+	return nil
+}
+
+func (r *games) Get(_ context.Context, id string) (*store.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.games[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *g
+	return &cp, nil
+}
+
+func (r *games) Update(_ context.Context, g *store.Game) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.games[g.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, other := range r.games {
+		if other.ID != g.ID && strings.EqualFold(other.Name, g.Name) {
+			return store.ErrConflict
+		}
+	}
+	g.OwnerID = existing.OwnerID
+	g.CreatedAt = existing.CreatedAt
+	g.UpdatedAt = r.now()
+	cp := *g
+	r.games[g.ID] = &cp
+	return nil
+}
+
+func (r *games) List(_ context.Context, p store.Page) ([]*store.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	all := make([]*store.Game, 0, len(r.games))
+	for _, g := range r.games {
+		if p.After == nil || newestFirst(p.After.Time, p.After.ID, g.CreatedAt, g.ID) {
+			cp := *g
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return newestFirst(all[i].CreatedAt, all[i].ID, all[j].CreatedAt, all[j].ID)
+	})
+	return limit(all, p.Limit), nil
+}
+
+func (r *games) Search(_ context.Context, search store.GameSearch, p store.Page) ([]*store.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	popularity := make(map[string]int)
+	if search.Sort == store.SortPopular {
+		asOf := search.AsOf
+		if asOf.IsZero() {
+			asOf = r.now()
+		}
+		since := asOf.Add(-store.PopularityWindow)
+		for _, s := range r.sessions {
+			if s.CreatedAt.After(since) && !s.CreatedAt.After(asOf) {
+				popularity[s.GameID]++
+			}
+		}
+	}
+
+	var before func(a, b *store.Game) bool
+	switch search.Sort {
+	case store.SortName:
+		before = func(a, b *store.Game) bool {
+			return byName(strings.ToLower(a.Name), a.ID, strings.ToLower(b.Name), b.ID)
+		}
+	case store.SortPopular:
+		before = func(a, b *store.Game) bool {
+			if a.Popularity != b.Popularity {
+				return a.Popularity > b.Popularity
+			}
+			return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+		}
+	default:
+		before = func(a, b *store.Game) bool { return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID) }
+	}
+	var after *store.Game
+	if c := p.After; c != nil {
+		after = &store.Game{ID: c.ID, Name: c.Name, CreatedAt: c.Time, Popularity: c.Count}
+	}
+
+	out := []*store.Game{}
+	for _, g := range r.games {
+		cp := *g
+		cp.Popularity = popularity[g.ID]
+		if matchesSearch(&cp, search) && (after == nil || before(after, &cp)) {
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return before(out[i], out[j]) })
+	return limit(out, p.Limit), nil
+}
+
+// matchesSearch applies the filters of search to g
+func matchesSearch(g *store.Game, search store.GameSearch) bool {
+	if q := strings.ToLower(strings.TrimSpace(search.Query)); q != "" && !strings.HasPrefix(strings.ToLower(g.Name), q) {
+		words := store.SearchWords(q)
+		if len(words) == 0 {
+			return false
+		}
+		gameWords := store.SearchWords(g.Name + " " + g.Description + " " + g.Category)
+		for _, w := range words {
+			if !slices.ContainsFunc(gameWords, func(gw string) bool { return strings.HasPrefix(gw, w) }) {
+				return false
+			}
+		}
+	}
+	return (search.Category == "" || strings.EqualFold(g.Category, search.Category)) &&
+		(search.Status == "" || g.Status == search.Status) &&
+		(search.MinPlayers == 0 || g.MaxPlayers >= search.MinPlayers) &&
+		(search.MaxPlayers == 0 || g.MinPlayers <= search.MaxPlayers)
+}
+
+type queues struct{ *data }
+
+func (r *queues) Enqueue(_ context.Context, e *store.QueueEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waiting(e.GameID, e.UserID) != nil {
+		return store.ErrConflict
+	}
+	e.PartySize = max(e.PartySize, 1)
+	r.add(e)
+	return nil
+}
+
+func (r *queues) EnqueueParty(_ context.Context, entries []*store.QueueEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range entries {
+		if r.waiting(e.GameID, e.UserID) != nil {
+			return store.ErrConflict
+		}
+	}
+	for _, e := range entries {
+		e.PartySize = len(entries)
+		r.add(e)
+	}
+	return nil
+}
+
+// add appends e to the queue as waiting. It must be called with the lock held.
+func (r *queues) add(e *store.QueueEntry) {
+	e.ID = newID()
+	e.Status = store.QueueWaiting
+	e.JoinedAt = r.now()
+	cp := *e
+	r.queue = append(r.queue, &cp)
+}
+
+func (r *queues) Waiting(_ context.Context, gameID, userID string) (*store.QueueEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e := r.waiting(gameID, userID)
+	if e == nil {
+		return nil, store.ErrNotFound
+	}
+	cp := *e
+	return &cp, nil
+}
+
+func (r *queues) Cancel(_ context.Context, gameID, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	group := r.withParty(gameID, userID)
+	for _, e := range group {
+		e.Status = store.QueueCancelled
+	}
+	return len(group) > 0, nil
+}
+
+func (r *queues) SetPriority(_ context.Context, gameID, userID string, priority int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	group := r.withParty(gameID, userID)
+	for _, e := range group {
+		e.Priority = priority
+	}
+	return len(group) > 0, nil
+}
+
+func (r *queues) Position(_ context.Context, e *store.QueueEntry) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, o := range r.inOrder(e.GameID) {
+		if o.ID == e.ID {
+			return i + 1, nil
+		}
+	}
+	return 0, store.ErrNotFound
+}
+
+func (r *queues) ListWaiting(_ context.Context, gameID string, n int) ([]*store.QueueEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.QueueEntry
+	for _, e := range r.inOrder(gameID) {
+		cp := *e
+		out = append(out, &cp)
+	}
+	return limit(out, n), nil
+}
+
+// inOrder returns the waiting entries of gameID, highest priority first and
+// then in join order. It must be called with the lock held.
+func (r *queues) inOrder(gameID string) []*store.QueueEntry {
+	var out []*store.QueueEntry
+	for _, e := range r.queue {
+		if e.GameID == gameID && e.Status == store.QueueWaiting {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority > out[j].Priority })
+	return out
+}
+
+func (r *queues) Expire(_ context.Context, now time.Time) ([]*store.QueueEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*store.QueueEntry
+	for _, e := range r.queue {
+		if e.Status == store.QueueWaiting && e.ExpiresAt != nil && !e.ExpiresAt.After(now) {
+			e.Status = store.QueueExpired
+			cp := *e
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *queues) WaitingGames(_ context.Context) ([]*store.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Game
+	seen := make(map[string]bool)
+	for _, e := range r.queue {
+		g, ok := r.games[e.GameID]
+		if e.Status != store.QueueWaiting || !ok || g.Status != store.GameActive || seen[g.ID] {
+			continue
+		}
+		seen[g.ID] = true
+		cp := *g
+		out = append(out, &cp)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *queues) Match(_ context.Context, gameID string, pick func([]*store.QueueEntry) []*store.QueueEntry) (*store.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	waiting := r.inOrder(gameID)
+	cps := make([]*store.QueueEntry, len(waiting))
+	byID := make(map[string]*store.QueueEntry, len(waiting))
+	for i, e := range waiting {
+		cp := *e
+		cps[i] = &cp
+		byID[e.ID] = e
+	}
+	var batch []*store.QueueEntry
+	for _, e := range pick(cps) {
+		batch = append(batch, byID[e.ID])
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	now := r.now()
+	s := &store.Session{ID: newID(), GameID: gameID, Status: store.SessionPending, CreatedAt: now}
+	r.sessions[s.ID] = s
+	for _, e := range batch {
+		e.Status = store.QueueMatched
+		e.MatchedAt = &now
+		e.SessionID = s.ID
+		r.addParticipants(s.ID, now, e.UserID)
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (r *queues) Backfill(_ context.Context, gameID string, pick func(*store.Session, []*store.QueueEntry, []*store.QueueEntry) []*store.QueueEntry) (*store.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var open []*store.Session
+	for _, s := range r.sessions {
+		if s.GameID == gameID && s.Status == store.SessionActive && s.OpenSlots > 0 {
+			open = append(open, s)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		if !open[i].CreatedAt.Equal(open[j].CreatedAt) {
+			return open[i].CreatedAt.Before(open[j].CreatedAt)
+		}
+		return open[i].ID < open[j].ID
+	})
+	waiting := r.inOrder(gameID)
+	if len(open) == 0 || len(waiting) == 0 {
+		return nil, nil
+	}
+	cps := make([]*store.QueueEntry, len(waiting))
+	byID := make(map[string]*store.QueueEntry, len(waiting))
+	for i, e := range waiting {
+		cp := *e
+		cps[i] = &cp
+		byID[e.ID] = e
+	}
+
+	for _, s := range open {
+		var players []*store.QueueEntry
+		for _, e := range r.queue {
+			if e.SessionID == s.ID && e.Status == store.QueueMatched && r.present(s.ID, e.UserID) {
+				cp := *e
+				players = append(players, &cp)
+			}
+		}
+		var batch []*store.QueueEntry
+		sc := *s
+		for _, e := range pick(&sc, players, cps) {
+			batch = append(batch, byID[e.ID])
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		now := r.now()
+		for _, e := range batch {
+			e.Status = store.QueueMatched
+			e.MatchedAt = &now
+			e.SessionID = s.ID
+			r.addParticipants(s.ID, now, e.UserID)
+		}
+		r.refreshSlots(s)
+		cp := *s
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+// waiting must be called with the lock held
+func (r *queues) waiting(gameID, userID string) *store.QueueEntry {
+	for _, e := range r.queue {
+		if e.GameID == gameID && e.UserID == userID && e.Status == store.QueueWaiting {
+			return e
+		}
+	}
+	return nil
+}
+
+// withParty returns the user's waiting entry for the game with those of the
+// rest of their party. It must be called with the lock held.
+func (r *queues) withParty(gameID, userID string) []*store.QueueEntry {
+	e := r.waiting(gameID, userID)
+	if e == nil {
+		return nil
+	}
+	if e.PartyID == "" {
+		return []*store.QueueEntry{e}
+	}
+	var out []*store.QueueEntry
+	for _, o := range r.queue {
+		if o.PartyID == e.PartyID && o.Status == store.QueueWaiting {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+type parties struct{ *data }
+
+// party copies p with fresh copies of its members' users, leader first, then
+// joined members in joining order and invitees last. It must be called with
+// the lock held.
+func (r *parties) party(p *store.Party) *store.Party {
+	cp := *p
+	cp.Members = make([]*store.PartyMember, 0, len(p.Members))
+	for _, m := range p.Members {
+		mcp := *m
+		user := *r.users[m.User.ID]
+		mcp.User = &user
+		cp.Members = append(cp.Members, &mcp)
+	}
+	rank := func(m *store.PartyMember) int {
+		switch {
+		case m.User.ID == p.LeaderID:
+			return 0
+		case m.Status == store.PartyJoined:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(cp.Members, func(i, j int) bool { return rank(cp.Members[i]) < rank(cp.Members[j]) })
+	return &cp
+}
+
+// joinedParty returns the party userID joined, or nil. It must be called
+// with the lock held.
+func (r *parties) joinedParty(userID string) *store.Party {
+	for _, p := range r.parties {
+		if m := p.Member(userID); m != nil && m.Status == store.PartyJoined {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *parties) Create(_ context.Context, p *store.Party) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leader, ok := r.users[p.LeaderID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if r.joinedParty(p.LeaderID) != nil {
+		return store.ErrConflict
+	}
+	now := r.now()
+	p.ID = newID()
+	p.CreatedAt = now
+	stored := &store.Party{ID: p.ID, LeaderID: p.LeaderID, CreatedAt: now}
+	stored.Members = []*store.PartyMember{{User: leader, Status: store.PartyJoined, InvitedAt: now, JoinedAt: &now}}
+	r.parties[p.ID] = stored
+	*p = *r.party(stored)
+	return nil
+}
+
+func (r *parties) Get(_ context.Context, id string) (*store.Party, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.parties[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return r.party(p), nil
+}
+
+func (r *parties) ForUser(_ context.Context, userID string) (*store.Party, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p := r.joinedParty(userID)
+	if p == nil {
+		return nil, store.ErrNotFound
+	}
+	return r.party(p), nil
+}
+
+func (r *parties) Invites(_ context.Context, userID string) ([]*store.Party, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Party
+	invitedAt := make(map[string]time.Time)
+	for _, p := range r.parties {
+		if m := p.Member(userID); m != nil && m.Status == store.PartyInvited {
+			invitedAt[p.ID] = m.InvitedAt
+			out = append(out, r.party(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := invitedAt[out[i].ID], invitedAt[out[j].ID]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (r *parties) Invite(_ context.Context, partyID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.parties[partyID]
+	user, found := r.users[userID]
+	if !ok || !found {
+		return store.ErrNotFound
+	}
+	if p.Member(userID) != nil {
+		return store.ErrConflict
+	}
+	p.Members = append(p.Members, &store.PartyMember{User: user, Status: store.PartyInvited, InvitedAt: r.now()})
+	return nil
+}
+
+func (r *parties) Accept(_ context.Context, partyID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.parties[partyID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m := p.Member(userID)
+	if m == nil || m.Status != store.PartyInvited {
+		return store.ErrNotFound
+	}
+	if r.joinedParty(userID) != nil {
+		return store.ErrConflict
+	}
+	now := r.now()
+	m.Status = store.PartyJoined
+	m.JoinedAt = &now
+	// Joining order ranks members for the lead
+	p.Members = append(slices.DeleteFunc(p.Members, func(o *store.PartyMember) bool { return o == m }), m)
+	return nil
+}
+
+func (r *parties) Leave(_ context.Context, partyID, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.parties[partyID]
+	if !ok {
+		return false, nil
+	}
+	m := p.Member(userID)
+	if m == nil {
+		return false, nil
+	}
+	p.Members = slices.DeleteFunc(p.Members, func(o *store.PartyMember) bool { return o == m })
+	if m.Status != store.PartyJoined {
+		return true, nil
+	}
+
+	for _, e := range r.queue {
+		if e.PartyID == partyID && e.Status == store.QueueWaiting {
+			e.Status = store.QueueCancelled
+		}
+	}
+	joined := p.Joined()
+	switch {
+	case len(joined) == 0:
+		delete(r.parties, partyID)
+	case userID == p.LeaderID:
+		p.LeaderID = joined[0].User.ID
+	}
+	return true, nil
+}
+
+type sessions struct{ *data }
+
+func (r *sessions) Create(_ context.Context, s *store.Session, userIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.games[s.GameID]; !ok {
+		return store.ErrNotFound
+	}
+	s.ID = newID()
+	if s.Status == "" {
+		s.Status = store.SessionPending
+	}
+	now := r.now()
+	s.CreatedAt = now
+	if s.Status == store.SessionActive {
+		s.StartedAt = &now
+	}
+	r.addParticipants(s.ID, now, userIDs...)
+	r.refreshSlots(s)
+	cp := *s
+	r.sessions[s.ID] = &cp
+	return nil
+}
+
+func (r *sessions) Get(_ context.Context, id string) (*store.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (r *sessions) Open(_ context.Context, gameID, userID string) (*store.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var open *store.Session
+	for _, s := range r.sessions {
+		if s.GameID != gameID || (s.Status != store.SessionPending && s.Status != store.SessionActive) ||
+			!r.present(s.ID, userID) {
+			continue
+		}
+		if open == nil || newestFirst(s.CreatedAt, s.ID, open.CreatedAt, open.ID) {
+			open = s
+		}
+	}
+	if open == nil {
+		return nil, store.ErrNotFound
+	}
+	cp := *open
+	return &cp, nil
+}
+
+func (r *sessions) Transition(_ context.Context, id, to string) (*store.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !store.CanTransition(s.Status, to) {
+		return nil, store.ErrInvalidTransition
+	}
+	now := r.now()
+	s.Status = to
+	switch to {
+	case store.SessionActive:
+		s.StartedAt = &now
+	case store.SessionCompleted, store.SessionCancelled:
+		s.EndedAt = &now
+		r.leaveAll(s.ID, now)
+	}
+	r.refreshSlots(s)
+	cp := *s
+	return &cp, nil
+}
+
+func (r *sessions) Complete(_ context.Context, id string, result *store.SessionResult, rate store.RatingUpdate) (*store.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !store.CanTransition(s.Status, store.SessionCompleted) {
+		return nil, store.ErrInvalidTransition
+	}
+	if rate != nil {
+		if err := r.updateRatings(s.GameID, slices.Sorted(maps.Keys(result.Placements())), rate); err != nil {
+			return nil, err
+		}
+	}
+	now := r.now()
+	s.Status = store.SessionCompleted
+	s.EndedAt = &now
+	s.Result = result
+	r.leaveAll(s.ID, now)
+	r.refreshSlots(s)
+	cp := *s
+	return &cp, nil
+}
+
+func (r *sessions) History(_ context.Context, userID, gameID string, p store.Page) ([]*store.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Session
+	for _, s := range r.sessions {
+		if s.Status != store.SessionCompleted || (gameID != "" && s.GameID != gameID) ||
+			!r.inSession(s.ID, userID) {
+			continue
+		}
+		if p.After == nil || newestFirst(p.After.Time, p.After.ID, s.CreatedAt, s.ID) {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return newestFirst(out[i].CreatedAt, out[i].ID, out[j].CreatedAt, out[j].ID)
+	})
+	return limit(out, p.Limit), nil
+}
+
+func (r *sessions) ListByGame(_ context.Context, gameID, status string, p store.Page) ([]*store.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Session
+	for _, s := range r.sessions {
+		if s.GameID != gameID || s.Status != status {
+			continue
+		}
+		if p.After == nil || newestFirst(p.After.Time, p.After.ID, s.CreatedAt, s.ID) {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return newestFirst(out[i].CreatedAt, out[i].ID, out[j].CreatedAt, out[j].ID)
+	})
+	return limit(out, p.Limit), nil
+}
+
+func (r *sessions) Participants(_ context.Context, sessionID string) ([]*store.Participant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Participant
+	for _, p := range r.participants[sessionID] {
+		if u, ok := r.users[p.userID]; ok {
+			cp := *u
+			out = append(out, &store.Participant{
+				SessionID:  sessionID,
+				User:       &cp,
+				JoinedAt:   p.joinedAt,
+				LastSeenAt: p.lastSeenAt,
+				LeftAt:     p.leftAt,
+			})
+		}
+	}
+	return out, nil
+}
+
+func (r *sessions) Presence(_ context.Context, sessionID, event string, userIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if s.Status != store.SessionPending && s.Status != store.SessionActive {
+		return store.ErrInvalidTransition
+	}
+	if event == store.PresenceJoin && s.Status == store.SessionActive {
+		returning := 0
+		for _, p := range r.participants[sessionID] {
+			if p.leftAt != nil && slices.Contains(userIDs, p.userID) {
+				returning++
+			}
+		}
+		if returning > s.OpenSlots {
+			return store.ErrConflict
+		}
+	}
+	now := r.now()
+	for _, p := range r.participants[sessionID] {
+		if !slices.Contains(userIDs, p.userID) {
+			continue
+		}
+		switch {
+		case event == store.PresenceJoin:
+			p.lastSeenAt, p.leftAt = &now, nil
+		case p.leftAt != nil:
+			// Only a join brings back a player who left
+		case event == store.PresenceHeartbeat:
+			p.lastSeenAt = &now
+		case event == store.PresenceLeave:
+			p.leftAt = &now
+		}
+	}
+	r.refreshSlots(s)
+	return nil
+}
+
+func (r *sessions) DropStale(_ context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	dropped := 0
+	for id, s := range r.sessions {
+		if s.Status != store.SessionPending && s.Status != store.SessionActive {
+			continue
+		}
+		for _, p := range r.participants[id] {
+			seen, ok := lastSeen(s, p)
+			if ok && p.leftAt == nil && seen.Before(before) {
+				p.leftAt = &now
+				dropped++
+			}
+		}
+		r.refreshSlots(s)
+	}
+	return dropped, nil
+}
+
+// lastSeen returns when the participant was last seen in s. Players never
+// seen count from when they were placed or s started, whichever is later,
+// and only once s is active.
+func lastSeen(s *store.Session, p *participant) (time.Time, bool) {
+	if p.lastSeenAt != nil {
+		return *p.lastSeenAt, true
+	}
+	if s.Status != store.SessionActive {
+		return time.Time{}, false
+	}
+	if s.StartedAt != nil && s.StartedAt.After(p.joinedAt) {
+		return *s.StartedAt, true
+	}
+	return p.joinedAt, true
+}
+
+// participant is a player placed in a session
+type participant struct {
+	userID     string
+	joinedAt   time.Time
+	lastSeenAt *time.Time
+	leftAt     *time.Time
+}
+
+// addParticipants places users in a session, giving those who left it a
+// fresh place. It must be called with the lock held.
+func (d *data) addParticipants(sessionID string, now time.Time, userIDs ...string) {
+	for _, id := range userIDs {
+		i := slices.IndexFunc(d.participants[sessionID], func(p *participant) bool { return p.userID == id })
+		if i < 0 {
+			d.participants[sessionID] = append(d.participants[sessionID], &participant{userID: id, joinedAt: now})
+			continue
+		}
+		*d.participants[sessionID][i] = participant{userID: id, joinedAt: now}
+	}
+}
+
+// inSession must be called with the lock held
+func (d *data) inSession(sessionID, userID string) bool {
+	return slices.ContainsFunc(d.participants[sessionID], func(p *participant) bool { return p.userID == userID })
+}
+
+// present reports whether the user is in the session and has not left it.
+// It must be called with the lock held.
+func (d *data) present(sessionID, userID string) bool {
+	return slices.ContainsFunc(d.participants[sessionID], func(p *participant) bool {
+		return p.userID == userID && p.leftAt == nil
+	})
+}
+
+// refreshSlots recounts the open slots of a session. It must be called with
+// the lock held.
+func (d *data) refreshSlots(s *store.Session) {
+	s.OpenSlots = 0
+	g, ok := d.games[s.GameID]
+	if !ok || s.Status != store.SessionActive {
+		return
+	}
+	s.OpenSlots = g.MaxPlayers
+	for _, p := range d.participants[s.ID] {
+		if p.leftAt == nil {
+			s.OpenSlots--
+		}
+	}
+	s.OpenSlots = max(s.OpenSlots, 0)
+}
+
+// leaveAll marks the remaining participants of an ended session left. It
+// must be called with the lock held.
+func (d *data) leaveAll(sessionID string, now time.Time) {
+	for _, p := range d.participants[sessionID] {
+		if p.leftAt == nil {
+			p.leftAt = &now
+		}
+	}
+}
+
+type ratings struct{ *data }
+
+func (r *ratings) Get(_ context.Context, userID, gameID string) (*store.Rating, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rating, ok := r.ratings[[2]string{userID, gameID}]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *rating
+	return &cp, nil
+}
+
+func (r *ratings) Update(_ context.Context, gameID string, userIDs []string, update store.RatingUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.updateRatings(gameID, userIDs, update)
+}
+
+// updateRatings implements Ratings.Update; the caller holds the write lock.
+// Nothing is saved unless every rating can be.
+func (d *data) updateRatings(gameID string, userIDs []string, update store.RatingUpdate) error {
+	current := make(map[string]*store.Rating)
+	for _, id := range userIDs {
+		if rating, ok := d.ratings[[2]string{id, gameID}]; ok {
+			cp := *rating
+			current[id] = &cp
+		}
+	}
+	updated := update(current)
+	for _, rating := range updated {
+		if _, ok := d.users[rating.UserID]; !ok {
+			return store.ErrNotFound
+		}
+	}
+	if _, ok := d.games[gameID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, rating := range updated {
+		cp := *rating
+		cp.GameID = gameID
+		cp.UpdatedAt = d.now()
+		d.ratings[[2]string{rating.UserID, gameID}] = &cp
+	}
+	return nil
+}
+
+type goods struct{ *data }
+
+func (r *goods) Create(_ context.Context, g *store.Good) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.games[g.GameID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range r.goods {
+		if existing.GameID == g.GameID && existing.Code == g.Code {
+			return store.ErrConflict
+		}
+	}
+	g.ID = newID()
+	if g.Rarity == "" {
+		g.Rarity = "common"
+	}
+	g.CreatedAt = r.now()
+	cp := *g
+	r.goods[g.ID] = &cp
+	return nil
+}
+
+func (r *goods) Get(_ context.Context, id string) (*store.Good, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.goods[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *g
+	return &cp, nil
+}
+
+func (r *goods) List(_ context.Context, gameID string, p store.Page) ([]*store.Good, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.Good
+	for _, g := range r.goods {
+		if gameID != "" && g.GameID != gameID {
+			continue
+		}
+		if p.After == nil || byName(p.After.Name, p.After.ID, g.Name, g.ID) {
+			cp := *g
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return byName(out[i].Name, out[i].ID, out[j].Name, out[j].ID) })
+	return limit(out, p.Limit), nil
+}
+
+type inventory struct{ *data }
+
+func (r *inventory) Grant(_ context.Context, userID, goodID string, quantity int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[userID]; !ok {
+		return 0, store.ErrNotFound
+	}
+	if _, ok := r.goods[goodID]; !ok {
+		return 0, store.ErrNotFound
+	}
+	key := [2]string{userID, goodID}
+	item, ok := r.inventory[key]
+	if !ok {
+		item = &store.InventoryItem{UserID: userID, AcquiredAt: r.now()}
+		r.inventory[key] = item
+	}
+	item.Quantity += quantity
+	return item.Quantity, nil
+}
+
+func (r *inventory) Revoke(_ context.Context, userID, goodID string, quantity int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]string{userID, goodID}
+	item, ok := r.inventory[key]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	item.Quantity -= quantity
+	if item.Quantity <= 0 {
+		delete(r.inventory, key)
+		return 0, nil
+	}
+	return item.Quantity, nil
+}
+
+func (r *inventory) List(_ context.Context, userID, gameID string, p store.Page) ([]*store.InventoryItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*store.InventoryItem
+	for key, item := range r.inventory {
+		good := r.goods[key[1]]
+		if key[0] != userID || good == nil || (gameID != "" && good.GameID != gameID) {
+			continue
+		}
+		if p.After != nil && !newestFirst(p.After.Time, p.After.ID, item.AcquiredAt, good.ID) {
+			continue
+		}
+		g := *good
+		out = append(out, &store.InventoryItem{UserID: userID, Good: &g, Quantity: item.Quantity, AcquiredAt: item.AcquiredAt})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return newestFirst(out[i].AcquiredAt, out[i].Good.ID, out[j].AcquiredAt, out[j].Good.ID)
+	})
+	return limit(out, p.Limit), nil
+}
+
+// newestFirst reports whether the row (t, id) sorts before (t2, id2) in a list
+// ordered by time and id, both descending
+func newestFirst(t time.Time, id string, t2 time.Time, id2 string) bool {
+	if !t.Equal(t2) {
+		return t.After(t2)
+	}
+	return id > id2
+}
+
+// byName reports whether the row (name, id) sorts before (name2, id2) in a
+// list ordered by name and id
+func byName(name, id, name2, id2 string) bool {
+	if name != name2 {
+		return name < name2
+	}
+	return id < id2
+}
+
+// limit truncates a sorted slice to n rows; n <= 0 means no limit
+func limit[T any](all []T, n int) []T {
+	if n > 0 && n < len(all) {
+		return all[:n]
+	}
+	return all
+}
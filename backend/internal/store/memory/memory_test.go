@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+	"github.com/scruffyprodigy/playhub/internal/store/storetest"
+)
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, New())
+}
+
+func TestQueueEnqueueOnce(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	game := &store.Game{Name: "Chess"}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: "u1"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: "u1"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a second entry, got %v", err)
+	}
+
+	if ok, err := st.Queues.Cancel(ctx, game.ID, "u1"); err != nil || !ok {
+		t.Fatalf("Cancel = %v, %v; expected true", ok, err)
+	}
+	if _, err := st.Queues.Waiting(ctx, game.ID, "u1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected no waiting entry after cancel, got %v", err)
+	}
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: "u1"}); err != nil {
+		t.Errorf("Expected to queue again after cancel, got %v", err)
+	}
+}
+
+func TestGoodsAndInventory(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	user := &store.User{Email: "a@example.com", Username: "a", DisplayName: "A"}
+	if err := st.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Goods.Create(ctx, &store.Good{GameID: "missing", Code: "X", Name: "X"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown game, got %v", err)
+	}
+
+	game := &store.Game{Name: "Chess"}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	good := &store.Good{GameID: game.ID, Code: "PAWN", Name: "Golden Pawn"}
+	if err := st.Goods.Create(ctx, good); err != nil {
+		t.Fatalf("Create good failed: %v", err)
+	}
+	if err := st.Goods.Create(ctx, &store.Good{GameID: game.ID, Code: "PAWN", Name: "Other"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict for duplicate code, got %v", err)
+	}
+
+	if n, err := st.Inventory.Grant(ctx, user.ID, good.ID, 2); err != nil || n != 2 {
+		t.Fatalf("Grant = %d, %v; expected 2", n, err)
+	}
+	if n, err := st.Inventory.Revoke(ctx, user.ID, good.ID, 5); err != nil || n != 0 {
+		t.Fatalf("Revoke = %d, %v; expected 0", n, err)
+	}
+	if _, err := st.Inventory.Revoke(ctx, user.ID, good.ID, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound once the good is gone, got %v", err)
+	}
+}
+
+func TestPartyLeaveCancelsQueueAndPassesLead(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	game := &store.Game{Name: "Chess"}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	var users []*store.User
+	for _, name := range []string{"a", "b", "c"} {
+		u := &store.User{Email: name + "@example.com", Username: name, DisplayName: name}
+		if err := st.Users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+
+	party := &store.Party{LeaderID: users[0].ID}
+	if err := st.Parties.Create(ctx, party); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for _, u := range users[1:] {
+		if err := st.Parties.Invite(ctx, party.ID, u.ID); err != nil {
+			t.Fatalf("Invite failed: %v", err)
+		}
+		if err := st.Parties.Accept(ctx, party.ID, u.ID); err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+	}
+	if err := st.Parties.Create(ctx, &store.Party{LeaderID: users[1].ID}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a second party, got %v", err)
+	}
+
+	var entries []*store.QueueEntry
+	for _, u := range users {
+		entries = append(entries, &store.QueueEntry{GameID: game.ID, UserID: u.ID, PartyID: party.ID})
+	}
+	if err := st.Queues.EnqueueParty(ctx, entries); err != nil {
+		t.Fatalf("EnqueueParty failed: %v", err)
+	}
+	if entries[0].PartySize != 3 {
+		t.Errorf("Expected party size 3, got %d", entries[0].PartySize)
+	}
+
+	if ok, err := st.Parties.Leave(ctx, party.ID, users[0].ID); err != nil || !ok {
+		t.Fatalf("Leave = %v, %v; expected true", ok, err)
+	}
+	for _, u := range users[1:] {
+		if _, err := st.Queues.Waiting(ctx, game.ID, u.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Expected the party's entries cancelled, got %v", err)
+		}
+	}
+	got, err := st.Parties.Get(ctx, party.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LeaderID != users[1].ID || len(got.Members) != 2 {
+		t.Errorf("Expected %s to lead the 2 remaining members, got %s with %d", users[1].ID, got.LeaderID, len(got.Members))
+	}
+}
+
+func TestDropStaleWithoutHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	clock := time.Now()
+	st.Sessions.(*sessions).now = func() time.Time { return clock }
+	game := &store.Game{Name: "Chess"}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, name := range []string{"u1", "u2"} {
+		u := &store.User{Email: name + "@example.com", Username: name, DisplayName: name}
+		if err := st.Users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+	session := &store.Session{GameID: game.ID, Status: store.SessionActive}
+	if err := st.Sessions.Create(ctx, session, ids); err != nil {
+		t.Fatal(err)
+	}
+	// Players of pending sessions may still be loading
+	pending := &store.Session{GameID: game.ID}
+	if err := st.Sessions.Create(ctx, pending, ids[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// u1 never connects while u2 keeps sending heartbeats
+	clock = clock.Add(time.Minute)
+	if err := st.Sessions.Presence(ctx, session.ID, store.PresenceHeartbeat, ids[1:]); err != nil {
+		t.Fatalf("Presence failed: %v", err)
+	}
+	if n, err := st.Sessions.DropStale(ctx, clock.Add(-2*time.Minute)); err != nil || n != 0 {
+		t.Errorf("DropStale = %d, %v; expected nobody stale yet", n, err)
+	}
+	if n, err := st.Sessions.DropStale(ctx, clock.Add(-30*time.Second)); err != nil || n != 1 {
+		t.Fatalf("DropStale = %d, %v; expected only u1 dropped from the active session", n, err)
+	}
+	players, err := st.Sessions.Participants(ctx, session.ID)
+	if err != nil || len(players) != 2 {
+		t.Fatalf("Participants = %+v, %v", players, err)
+	}
+	if players[0].LeftAt == nil || players[1].LeftAt != nil {
+		t.Errorf("Expected u1 left and u2 still there, got %+v, %+v", players[0], players[1])
+	}
+	if players, err := st.Sessions.Participants(ctx, pending.ID); err != nil || len(players) != 1 || players[0].LeftAt != nil {
+		t.Errorf("Participants = %+v, %v; expected u1 to stay in the pending session", players, err)
+	}
+}
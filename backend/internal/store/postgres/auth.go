@@ -0,0 +1,365 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+type magicLinks struct{ db *sql.DB }
+
+func (r *magicLinks) Create(ctx context.Context, email, tokenHash string, expiresAt time.Time) error {
+	// Disabled accounts get no link; the insert selects no row for them
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO magic_links (user_id, email, token, expires_at)
+		SELECT u.id, $1, $2, $3
+		FROM (SELECT 1) one
+		LEFT JOIN users u ON u.email = $1
+		WHERE u.id IS NULL OR u.is_active`,
+		email, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store magic link: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return store.ErrDisabled
+	}
+	return nil
+}
+
+func (r *magicLinks) Redeem(ctx context.Context, tokenHash string, newUser func(email string) (*store.User, error)) (*store.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Claiming the row in a single UPDATE keeps concurrent redemptions of the
+	// same token from both succeeding
+	var linkID, email string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE magic_links SET used_at = NOW()
+		WHERE token = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, email`, tokenHash).Scan(&linkID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem magic link: %w", err)
+	}
+
+	fresh, err := newUser(email)
+	if err != nil {
+		return nil, err
+	}
+	// Deactivated accounts are left untouched and select no row; the link
+	// is still used up
+	u, err := scanUser(tx.QueryRowContext(ctx, `
+		INSERT INTO users (email, username, display_name, is_verified, last_login_at)
+		VALUES ($1, $2, $3, true, NOW())
+		ON CONFLICT (email) DO UPDATE SET is_verified = true, last_login_at = NOW()
+		WHERE users.is_active
+		RETURNING `+userColumns, email, fresh.Username, fresh.DisplayName))
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit magic link redemption: %w", err)
+		}
+		return nil, store.ErrDisabled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE magic_links SET user_id = $1 WHERE id = $2`, u.ID, linkID); err != nil {
+		return nil, fmt.Errorf("failed to link magic link to user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit magic link redemption: %w", err)
+	}
+	return u, nil
+}
+
+func (r *magicLinks) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM magic_links WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired magic links: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+type authSessions struct{ db *sql.DB }
+
+func (r *authSessions) Create(ctx context.Context, s *store.AuthSession, tokenHash string) error {
+	if !validID(s.UserID) {
+		return store.ErrNotFound
+	}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO auth_sessions (user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id`,
+		s.UserID, tokenHash, s.UserAgent, s.IP, s.ExpiresAt).Scan(&s.ID)
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *authSessions) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*store.AuthSession, *store.User, error) {
+	s := &store.AuthSession{}
+	u, err := scanUser(r.db.QueryRowContext(ctx, `
+		WITH rotated AS (
+			UPDATE auth_sessions
+			SET refresh_token_hash = $2, last_used_at = NOW(), expires_at = $3
+			WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			RETURNING id AS session_id, user_id, COALESCE(user_agent, '') AS session_user_agent,
+				COALESCE(ip_address, '') AS session_ip, expires_at AS session_expires_at
+		)
+		SELECT `+userColumns+`, r.session_id, r.session_user_agent, r.session_ip, r.session_expires_at
+		FROM rotated r
+		JOIN users ON users.id = r.user_id
+		WHERE users.is_active`, oldHash, newHash, expiresAt),
+		&s.ID, &s.UserAgent, &s.IP, &s.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+	s.UserID = u.ID
+	return s, u, nil
+}
+
+func (r *authSessions) Revoke(ctx context.Context, id string) error {
+	if !validID(id) {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *authSessions) RevokeByToken(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *authSessions) RevokeAllForUser(ctx context.Context, userID string) (int, error) {
+	if !validID(userID) {
+		return 0, nil
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return int(n), nil
+}
+
+func (r *authSessions) IsActive(ctx context.Context, id string) (bool, error) {
+	if !validID(id) {
+		return false, nil
+	}
+	var active bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT revoked_at IS NULL AND expires_at > NOW()
+		FROM auth_sessions WHERE id = $1`, id).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+type roles struct{ db *sql.DB }
+
+func (r *roles) ForUser(ctx context.Context, userID string) ([]string, error) {
+	if !validID(userID) {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to load roles: %w", err)
+		}
+		out = append(out, role)
+	}
+	return out, rows.Err()
+}
+
+func (r *roles) Grant(ctx context.Context, userID, role, grantedBy string) error {
+	if !validID(userID) {
+		return store.ErrNotFound
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role, granted_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role) DO NOTHING`, userID, role, nullID(grantedBy))
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+	return nil
+}
+
+func (r *roles) Revoke(ctx context.Context, userID, role string) error {
+	if !validID(userID) {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+	return nil
+}
+
+type apiKeys struct{ db *sql.DB }
+
+const apiKeyColumns = `id, game_id, name, key_prefix, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*store.APIKey, error) {
+	k := &store.APIKey{}
+	var lastUsed, revoked sql.NullTime
+	err := row.Scan(&k.ID, &k.GameID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.CreatedAt, &lastUsed, &revoked)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return k, nil
+}
+
+func (r *apiKeys) Create(ctx context.Context, k *store.APIKey, keyHash, createdBy string) error {
+	if !validID(k.GameID) {
+		return store.ErrNotFound
+	}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO game_api_keys (game_id, name, key_prefix, key_hash, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		k.GameID, k.Name, k.Prefix, keyHash, pq.Array(k.Scopes), nullID(createdBy)).
+		Scan(&k.ID, &k.CreatedAt)
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeys) Get(ctx context.Context, id string) (*store.APIKey, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM game_api_keys WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+	return k, nil
+}
+
+func (r *apiKeys) List(ctx context.Context, gameID string) ([]*store.APIKey, error) {
+	if !validID(gameID) {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM game_api_keys WHERE game_id = $1
+		ORDER BY created_at DESC, id DESC`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys: %w", err)
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *apiKeys) Revoke(ctx context.Context, id string) error {
+	if !validID(id) {
+		return store.ErrNotFound
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE game_api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (r *apiKeys) Authenticate(ctx context.Context, keyHash string) (*store.APIKey, error) {
+	// Game servers call on every request, so the use is only written once
+	// the recorded one is a minute old
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		WITH k AS (
+			SELECT `+apiKeyColumns+` FROM game_api_keys
+			WHERE key_hash = $1 AND revoked_at IS NULL
+		), used AS (
+			UPDATE game_api_keys SET last_used_at = NOW()
+			WHERE key_hash = $1 AND revoked_at IS NULL
+				AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+			RETURNING `+apiKeyColumns+`
+		)
+		SELECT * FROM used
+		UNION ALL
+		SELECT * FROM k WHERE NOT EXISTS (SELECT 1 FROM used)`, keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check API key: %w", err)
+	}
+	return k, nil
+}
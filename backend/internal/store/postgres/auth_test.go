@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+func TestAuthRejectsMalformedIDs(t *testing.T) {
+	// Malformed ids never reach the database
+	s := New(nil)
+	ctx := context.Background()
+	if _, err := s.APIKeys.Get(ctx, "not-a-uuid"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("APIKeys.Get = %v, want ErrNotFound", err)
+	}
+	if err := s.APIKeys.Revoke(ctx, "not-a-uuid"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("APIKeys.Revoke = %v, want ErrNotFound", err)
+	}
+	if keys, err := s.APIKeys.List(ctx, "not-a-uuid"); err != nil || keys != nil {
+		t.Errorf("APIKeys.List = %v, %v, want no keys", keys, err)
+	}
+	if err := s.Roles.Grant(ctx, "not-a-uuid", "support", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Roles.Grant = %v, want ErrNotFound", err)
+	}
+	if err := s.Roles.Revoke(ctx, "not-a-uuid", "support"); err != nil {
+		t.Errorf("Roles.Revoke = %v, want nil", err)
+	}
+	if active, err := s.AuthSessions.IsActive(ctx, "not-a-uuid"); err != nil || active {
+		t.Errorf("AuthSessions.IsActive = %v, %v, want inactive", active, err)
+	}
+}
+
+func TestMagicLinkRejectsDisabledAccount(t *testing.T) {
+	db := testDB(t)
+	s := New(db)
+	ctx := context.Background()
+
+	suffix := uuid.NewString()[:8]
+	u := &store.User{Email: "disabled-" + suffix + "@example.com", Username: "disabled-" + suffix, DisplayName: "Disabled"}
+	if err := s.Users.Create(ctx, u); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	hash := "disabled-" + uuid.NewString()
+	if err := s.MagicLinks.Create(ctx, u.Email, hash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Create link failed: %v", err)
+	}
+
+	// The account is deactivated while its link is outstanding
+	if _, err := db.ExecContext(ctx, `UPDATE users SET is_active = false WHERE id = $1`, u.ID); err != nil {
+		t.Fatalf("Failed to deactivate user: %v", err)
+	}
+	newUser := func(email string) (*store.User, error) {
+		return &store.User{Email: email, Username: "unused-" + suffix, DisplayName: "Unused"}, nil
+	}
+	if _, err := s.MagicLinks.Redeem(ctx, hash, newUser); !errors.Is(err, store.ErrDisabled) {
+		t.Errorf("Redeem = %v, want ErrDisabled", err)
+	}
+	if _, err := s.MagicLinks.Redeem(ctx, hash, newUser); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Redeem again = %v, want the link used up", err)
+	}
+}
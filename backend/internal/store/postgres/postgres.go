@@ -0,0 +1,1653 @@
+// Package postgres implements the store repositories on the PostgreSQL schema
+// in backend/migrations
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// New returns repositories backed by db
+func New(db *sql.DB) store.Store {
+	return store.Store{
+		Users:     &users{db},
+		Games:     &games{db},
+		Queues:    &queues{db},
+		Parties:   &parties{db},
+		Sessions:  &sessions{db},
+		Ratings:   &ratings{db},
+		Goods:     &goods{db},
+		Inventory: &inventory{db},
+
+		MagicLinks:   &magicLinks{db},
+		AuthSessions: &authSessions{db},
+		Roles:        &roles{db},
+		APIKeys:      &apiKeys{db},
+	}
+}
+
+// validID reports whether id can be compared with a UUID column. Other ids
+// cannot match any row, so callers treat them as not found instead of letting
+// Postgres reject the query.
+func validID(ids ...string) bool {
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// nullID maps "" to NULL for optional UUID filters
+func nullID(id string) any {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// cursorArgs returns the keyset arguments of page: the sort time, sort name
+// and id after which rows start, each NULL without a cursor. ok is false when
+// the cursor cannot match any row.
+func cursorArgs(page store.Page) (t, name, id any, ok bool) {
+	c := page.After
+	if c == nil {
+		return nil, nil, nil, true
+	}
+	if !validID(c.ID) {
+		return nil, nil, nil, false
+	}
+	return c.Time, c.Name, c.ID, true
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+type users struct{ db *sql.DB }
+
+const userColumns = `id, email, username, display_name, COALESCE(avatar_url, ''), created_at`
+
+// scanUser scans userColumns followed by the extra destinations
+func scanUser(row rowScanner, extra ...any) (*store.User, error) {
+	u := &store.User{}
+	dest := []any{&u.ID, &u.Email, &u.Username, &u.DisplayName, &u.AvatarURL, &u.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *users) Get(ctx context.Context, id string) (*store.User, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	u, err := scanUser(r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM users WHERE id = $1 AND is_active`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return u, nil
+}
+
+func (r *users) Create(ctx context.Context, u *store.User) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, username, display_name, avatar_url)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at`,
+		u.Email, u.Username, u.DisplayName, u.AvatarURL).Scan(&u.ID, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+type games struct{ db *sql.DB }
+
+const gameColumns = `id, name, COALESCE(description, ''), COALESCE(version, ''),
+	COALESCE(min_players, 1), COALESCE(max_players, 4), COALESCE(estimated_duration_minutes, 0),
+	COALESCE(category, ''), COALESCE(status, 'active'), COALESCE(owner_id::text, ''),
+	COALESCE(max_queue_wait_seconds, 0), COALESCE(join_url_template, ''), backfill, created_at, updated_at`
+
+// scanGame scans gameColumns followed by the extra destinations
+func scanGame(row rowScanner, extra ...any) (*store.Game, error) {
+	g := &store.Game{}
+	dest := []any{&g.ID, &g.Name, &g.Description, &g.Version, &g.MinPlayers, &g.MaxPlayers,
+		&g.EstimatedDurationMinutes, &g.Category, &g.Status, &g.OwnerID, &g.MaxQueueWaitSeconds, &g.JoinURLTemplate, &g.Backfill, &g.CreatedAt, &g.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *games) Create(ctx context.Context, g *store.Game) error {
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO games (name, description, version, min_players, max_players,
+			estimated_duration_minutes, category, status, owner_id, max_queue_wait_seconds, join_url_template, backfill)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), COALESCE(NULLIF($4, 0), 1), COALESCE(NULLIF($5, 0), 4),
+			NULLIF($6, 0), NULLIF($7, ''), COALESCE(NULLIF($8, ''), 'active'), NULLIF($9, '')::uuid, NULLIF($10, 0),
+			NULLIF($11, ''), $12)
+		RETURNING `+gameColumns,
+		g.Name, g.Description, g.Version, g.MinPlayers, g.MaxPlayers,
+		g.EstimatedDurationMinutes, g.Category, g.Status, g.OwnerID, g.MaxQueueWaitSeconds, g.JoinURLTemplate, g.Backfill)
+	created, err := scanGame(row)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create game: %w", err)
+	}
+	*g = *created
+	return nil
+}
+
+func (r *games) Get(ctx context.Context, id string) (*store.Game, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	g, err := scanGame(r.db.QueryRowContext(ctx, `
+		SELECT `+gameColumns+` FROM games WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game: %w", err)
+	}
+	return g, nil
+}
+
+func (r *games) Update(ctx context.Context, g *store.Game) error {
+	if !validID(g.ID) {
+		return store.ErrNotFound
+	}
+	row := r.db.QueryRowContext(ctx, `
+		UPDATE games SET name = $2, description = NULLIF($3, ''), version = NULLIF($4, ''),
+			min_players = $5, max_players = $6, estimated_duration_minutes = NULLIF($7, 0),
+			category = NULLIF($8, ''), status = $9, max_queue_wait_seconds = NULLIF($10, 0),
+			join_url_template = NULLIF($11, ''), backfill = $12
+		WHERE id = $1
+		RETURNING `+gameColumns,
+		g.ID, g.Name, g.Description, g.Version, g.MinPlayers, g.MaxPlayers,
+		g.EstimatedDurationMinutes, g.Category, g.Status, g.MaxQueueWaitSeconds, g.JoinURLTemplate, g.Backfill)
+	updated, err := scanGame(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update game: %w", err)
+	}
+	*g = *updated
+	return nil
+}
+
+func (r *games) List(ctx context.Context, page store.Page) ([]*store.Game, error) {
+	after, _, afterID, ok := cursorArgs(page)
+	if !ok {
+		return []*store.Game{}, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+gameColumns+` FROM games
+		WHERE $1::timestamptz IS NULL OR (created_at, id) < ($1, $2::uuid)
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($3, 0)`, after, afterID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list games: %w", err)
+	}
+	defer rows.Close()
+
+	out := []*store.Game{}
+	for rows.Next() {
+		g, err := scanGame(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list games: %w", err)
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *games) Search(ctx context.Context, search store.GameSearch, page store.Page) ([]*store.Game, error) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var where []string
+	if q := strings.ToLower(strings.TrimSpace(search.Query)); q != "" {
+		cond := "LOWER(name) LIKE " + arg(likeEscaper.Replace(q)+"%") + ` ESCAPE '\'`
+		if words := store.SearchWords(q); len(words) > 0 {
+			// Every word has to start some word of the game
+			terms := make([]string, len(words))
+			for i, w := range words {
+				terms[i] = w + ":*"
+			}
+			cond += " OR search_vector @@ to_tsquery('simple', " + arg(strings.Join(terms, " & ")) + ")"
+		}
+		where = append(where, "("+cond+")")
+	}
+	if search.Category != "" {
+		where = append(where, "LOWER(category) = LOWER("+arg(search.Category)+")")
+	}
+	if search.Status != "" {
+		where = append(where, "status = "+arg(search.Status))
+	}
+	if search.MinPlayers > 0 {
+		where = append(where, "max_players >= "+arg(search.MinPlayers))
+	}
+	if search.MaxPlayers > 0 {
+		where = append(where, "min_players <= "+arg(search.MaxPlayers))
+	}
+
+	order, popularity := "", "0"
+	c := page.After
+	if c != nil && !validID(c.ID) {
+		return []*store.Game{}, nil
+	}
+	switch search.Sort {
+	case store.SortName:
+		order = "LOWER(name), id"
+		if c != nil {
+			where = append(where, "(LOWER(name), id) > (LOWER("+arg(c.Name)+"), "+arg(c.ID)+"::uuid)")
+		}
+	case store.SortPopular:
+		asOf := search.AsOf
+		if asOf.IsZero() {
+			asOf = time.Now()
+		}
+		// A range scan of idx_game_sessions_game_created per game
+		popularity = `(
+				SELECT COUNT(*) FROM game_sessions s
+				WHERE s.game_id = g.id AND s.created_at > ` + arg(asOf.Add(-store.PopularityWindow)) + ` AND s.created_at <= ` + arg(asOf) + `
+			)`
+		order = "popularity DESC, created_at DESC, id DESC"
+		if c != nil {
+			where = append(where, "(popularity, created_at, id) < ("+arg(c.Count)+", "+arg(c.Time)+", "+arg(c.ID)+"::uuid)")
+		}
+	default:
+		order = "created_at DESC, id DESC"
+		if c != nil {
+			where = append(where, "(created_at, id) < ("+arg(c.Time)+", "+arg(c.ID)+"::uuid)")
+		}
+	}
+
+	query := `
+		SELECT ` + gameColumns + `, popularity FROM (
+			SELECT g.*, ` + popularity + ` AS popularity
+			FROM games g
+		) games`
+	if len(where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	query += "\n\t\tORDER BY " + order + "\n\t\tLIMIT NULLIF(" + arg(page.Limit) + ", 0)"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search games: %w", err)
+	}
+	defer rows.Close()
+
+	out := []*store.Game{}
+	for rows.Next() {
+		var popularity int
+		g, err := scanGame(rows, &popularity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search games: %w", err)
+		}
+		g.Popularity = popularity
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+type queues struct{ db *sql.DB }
+
+const queueColumns = `id, game_id, user_id, status, priority, preferences, joined_at, matched_at, expires_at,
+	COALESCE(session_id::text, ''), COALESCE(party_id::text, ''), party_size`
+
+func scanQueueEntry(row rowScanner) (*store.QueueEntry, error) {
+	e := &store.QueueEntry{}
+	var prefs []byte
+	var matched, expires sql.NullTime
+	err := row.Scan(&e.ID, &e.GameID, &e.UserID, &e.Status, &e.Priority, &prefs, &e.JoinedAt, &matched, &expires,
+		&e.SessionID, &e.PartyID, &e.PartySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefs) > 0 && string(prefs) != "null" {
+		if err := json.Unmarshal(prefs, &e.Preferences); err != nil {
+			return nil, fmt.Errorf("invalid queue preferences: %w", err)
+		}
+	}
+	if matched.Valid {
+		e.MatchedAt = &matched.Time
+	}
+	if expires.Valid {
+		e.ExpiresAt = &expires.Time
+	}
+	return e, nil
+}
+
+func (r *queues) Enqueue(ctx context.Context, e *store.QueueEntry) error {
+	return enqueue(ctx, r.db, e)
+}
+
+func (r *queues) EnqueueParty(ctx context.Context, entries []*store.QueueEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, e := range entries {
+		e.PartySize = len(entries)
+		if err := enqueue(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to enqueue party: %w", err)
+	}
+	return nil
+}
+
+// execer runs queries on a pool or inside a transaction
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// enqueue inserts the waiting entry e through db
+func enqueue(ctx context.Context, db execer, e *store.QueueEntry) error {
+	if !validID(e.GameID, e.UserID) {
+		return store.ErrNotFound
+	}
+	prefs, err := json.Marshal(e.Preferences)
+	if err != nil {
+		return fmt.Errorf("invalid queue preferences: %w", err)
+	}
+	// idx_game_queues_waiting_user allows one waiting entry per user and game
+	row := db.QueryRowContext(ctx, `
+		INSERT INTO game_queues (game_id, user_id, status, priority, preferences, expires_at, party_id, party_size)
+		VALUES ($1, $2, 'waiting', $3, $4, $5, NULLIF($6, '')::uuid, GREATEST($7, 1))
+		ON CONFLICT (game_id, user_id) WHERE status = 'waiting' DO NOTHING
+		RETURNING `+queueColumns,
+		e.GameID, e.UserID, e.Priority, prefs, e.ExpiresAt, e.PartyID, e.PartySize)
+	created, err := scanQueueEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrConflict
+	}
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue: %w", err)
+	}
+	*e = *created
+	return nil
+}
+
+func (r *queues) Waiting(ctx context.Context, gameID, userID string) (*store.QueueEntry, error) {
+	if !validID(gameID, userID) {
+		return nil, store.ErrNotFound
+	}
+	e, err := scanQueueEntry(r.db.QueryRowContext(ctx, `
+		SELECT `+queueColumns+` FROM game_queues
+		WHERE game_id = $1 AND user_id = $2 AND status = 'waiting'`, gameID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queue entry: %w", err)
+	}
+	return e, nil
+}
+
+// withParty matches the waiting entry of user $2 for game $1 together with
+// the entries of the rest of the user's party
+const withParty = `(user_id = $2 OR party_id = (
+	SELECT party_id FROM game_queues WHERE game_id = $1 AND user_id = $2 AND status = 'waiting'
+))`
+
+func (r *queues) Cancel(ctx context.Context, gameID, userID string) (bool, error) {
+	if !validID(gameID, userID) {
+		return false, nil
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE game_queues SET status = 'cancelled'
+		WHERE game_id = $1 AND status = 'waiting' AND `+withParty, gameID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to leave queue: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *queues) SetPriority(ctx context.Context, gameID, userID string, priority int) (bool, error) {
+	if !validID(gameID, userID) {
+		return false, nil
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE game_queues SET priority = $3
+		WHERE game_id = $1 AND status = 'waiting' AND `+withParty, gameID, userID, priority)
+	if err != nil {
+		return false, fmt.Errorf("failed to set queue priority: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// queueOrder sorts waiting entries, matching idx_game_queues_waiting_order
+const queueOrder = `priority DESC, joined_at, id`
+
+func (r *queues) Position(ctx context.Context, e *store.QueueEntry) (int, error) {
+	var ahead int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM game_queues
+		WHERE game_id = $1 AND status = 'waiting' AND (
+			priority > $2 OR (priority = $2 AND (joined_at, id) < ($3, $4::uuid))
+		)`,
+		e.GameID, e.Priority, e.JoinedAt, e.ID).Scan(&ahead)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load queue position: %w", err)
+	}
+	return ahead + 1, nil
+}
+
+func (r *queues) ListWaiting(ctx context.Context, gameID string, limit int) ([]*store.QueueEntry, error) {
+	if !validID(gameID) {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+queueColumns+` FROM game_queues
+		WHERE game_id = $1 AND status = 'waiting'
+		ORDER BY `+queueOrder+`
+		LIMIT NULLIF($2, 0)`, gameID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.QueueEntry
+	for rows.Next() {
+		e, err := scanQueueEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list queue: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *queues) Expire(ctx context.Context, now time.Time) ([]*store.QueueEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE game_queues SET status = 'expired'
+		WHERE status = 'waiting' AND expires_at <= $1
+		RETURNING `+queueColumns, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire queue entries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.QueueEntry
+	for rows.Next() {
+		e, err := scanQueueEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expire queue entries: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *queues) WaitingGames(ctx context.Context) ([]*store.Game, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+gameColumns+` FROM games
+		WHERE status = 'active' AND EXISTS (
+			SELECT 1 FROM game_queues q WHERE q.game_id = games.id AND q.status = 'waiting'
+		)
+		ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waiting games: %w", err)
+	}
+	defer rows.Close()
+
+	out := []*store.Game{}
+	for rows.Next() {
+		g, err := scanGame(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list waiting games: %w", err)
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// maxMatchCandidates bounds the entries one Match locks and considers
+const maxMatchCandidates = 1000
+
+func (r *queues) Match(ctx context.Context, gameID string, pick func([]*store.QueueEntry) []*store.QueueEntry) (*store.Session, error) {
+	if !validID(gameID) {
+		return nil, nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	waiting, err := claimWaiting(ctx, tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	batch := pick(waiting)
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	entryIDs := make([]string, len(batch))
+	userIDs := make([]string, len(batch))
+	for i, e := range batch {
+		entryIDs[i], userIDs[i] = e.ID, e.UserID
+	}
+
+	s, err := scanSession(tx.QueryRowContext(ctx, `
+		INSERT INTO game_sessions (game_id, status) VALUES ($1, 'pending')
+		RETURNING `+sessionColumns, gameID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO game_session_participants (session_id, user_id)
+		SELECT $1, unnest($2::uuid[])`, s.ID, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add participants: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE game_queues SET status = 'matched', matched_at = NOW(), session_id = $1
+		WHERE id = ANY($2::uuid[])`, s.ID, pq.Array(entryIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark queue entries matched: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s, nil
+}
+
+// maxBackfillSessions bounds the sessions one Backfill locks and considers
+const maxBackfillSessions = 100
+
+func (r *queues) Backfill(ctx context.Context, gameID string, pick func(*store.Session, []*store.QueueEntry, []*store.QueueEntry) []*store.QueueEntry) (*store.Session, error) {
+	if !validID(gameID) {
+		return nil, nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM game_sessions
+		WHERE game_id = $1 AND status = 'active' AND open_slots > 0
+		ORDER BY created_at, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, gameID, maxBackfillSessions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim sessions: %w", err)
+	}
+	var open []*store.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to claim sessions: %w", err)
+		}
+		open = append(open, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim sessions: %w", err)
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+	waiting, err := claimWaiting(ctx, tx, gameID)
+	if err != nil || len(waiting) == 0 {
+		return nil, err
+	}
+
+	for _, s := range open {
+		players, err := queryQueueEntries(ctx, tx, `
+			SELECT `+queueColumns+` FROM game_queues
+			WHERE session_id = $1 AND status = 'matched' AND user_id IN (
+				SELECT user_id FROM game_session_participants WHERE session_id = $1 AND left_at IS NULL
+			)`, s.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session players: %w", err)
+		}
+		batch := pick(s, players, waiting)
+		if len(batch) == 0 {
+			continue
+		}
+		entryIDs := make([]string, len(batch))
+		userIDs := make([]string, len(batch))
+		for i, e := range batch {
+			entryIDs[i], userIDs[i] = e.ID, e.UserID
+		}
+
+		// Players who left the session earlier take a fresh place in it
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO game_session_participants (session_id, user_id)
+			SELECT $1, unnest($2::uuid[])
+			ON CONFLICT (session_id, user_id) DO UPDATE SET joined_at = NOW(), last_seen_at = NULL, left_at = NULL`,
+			s.ID, pq.Array(userIDs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to add participants: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE game_queues SET status = 'matched', matched_at = NOW(), session_id = $1
+			WHERE id = ANY($2::uuid[])`, s.ID, pq.Array(entryIDs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark queue entries matched: %w", err)
+		}
+		if err := tx.QueryRowContext(ctx, refreshOpenSlots, pq.Array([]string{s.ID})).Scan(&s.OpenSlots); err != nil {
+			return nil, fmt.Errorf("failed to count open slots: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to backfill session: %w", err)
+		}
+		return s, nil
+	}
+	return nil, nil
+}
+
+// claimWaiting locks the waiting entries of a game in queue order. SKIP
+// LOCKED lets several matchmakers claim disjoint batches. Entries left
+// unused are unlocked again when tx ends.
+func claimWaiting(ctx context.Context, tx *sql.Tx, gameID string) ([]*store.QueueEntry, error) {
+	waiting, err := queryQueueEntries(ctx, tx, `
+		SELECT `+queueColumns+` FROM game_queues
+		WHERE game_id = $1 AND status = 'waiting'
+		ORDER BY `+queueOrder+`
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, gameID, maxMatchCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim queue entries: %w", err)
+	}
+	return waiting, nil
+}
+
+// queryQueueEntries reads the queue entries selected by query within tx
+func queryQueueEntries(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]*store.QueueEntry, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*store.QueueEntry
+	for rows.Next() {
+		e, err := scanQueueEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+type parties struct{ db *sql.DB }
+
+// partyMembers loads the members of the parties ids, leader first, then
+// joined members in joining order and invitees last
+func partyMembers(ctx context.Context, db *sql.DB, ids []string) (map[string][]*store.PartyMember, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT u.id, u.email, u.username, u.display_name, COALESCE(u.avatar_url, ''), u.created_at,
+			m.party_id, m.status, m.invited_at, m.joined_at
+		FROM party_members m
+		JOIN parties p ON p.id = m.party_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.party_id = ANY($1::uuid[])
+		ORDER BY u.id = p.leader_id DESC, m.status = 'joined' DESC, m.joined_at, m.invited_at, u.id`,
+		pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list party members: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string][]*store.PartyMember)
+	for rows.Next() {
+		var partyID string
+		var joined sql.NullTime
+		m := &store.PartyMember{}
+		m.User, err = scanUser(rows, &partyID, &m.Status, &m.InvitedAt, &joined)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list party members: %w", err)
+		}
+		if joined.Valid {
+			m.JoinedAt = &joined.Time
+		}
+		out[partyID] = append(out[partyID], m)
+	}
+	return out, rows.Err()
+}
+
+// loadParties loads the parties ids with their members, in the order of ids
+func (r *parties) loadParties(ctx context.Context, ids []string) ([]*store.Party, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, leader_id, created_at FROM parties WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load parties: %w", err)
+	}
+	byID := make(map[string]*store.Party)
+	for rows.Next() {
+		p := &store.Party{}
+		if err := rows.Scan(&p.ID, &p.LeaderID, &p.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to load parties: %w", err)
+		}
+		byID[p.ID] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load parties: %w", err)
+	}
+
+	members, err := partyMembers(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*store.Party, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			p.Members = members[id]
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *parties) Create(ctx context.Context, p *store.Party) error {
+	if !validID(p.LeaderID) {
+		return store.ErrNotFound
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO parties (leader_id) VALUES ($1)
+		RETURNING id, created_at`, p.LeaderID).Scan(&p.ID, &p.CreatedAt)
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create party: %w", err)
+	}
+	// idx_party_members_joined_user allows one party per user
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO party_members (party_id, user_id, status, joined_at)
+		VALUES ($1, $2, 'joined', NOW())`, p.ID, p.LeaderID)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create party: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create party: %w", err)
+	}
+
+	created, err := r.Get(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	*p = *created
+	return nil
+}
+
+func (r *parties) Get(ctx context.Context, id string) (*store.Party, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	found, err := r.loadParties(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return found[0], nil
+}
+
+func (r *parties) ForUser(ctx context.Context, userID string) (*store.Party, error) {
+	if !validID(userID) {
+		return nil, store.ErrNotFound
+	}
+	var id string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT party_id FROM party_members WHERE user_id = $1 AND status = 'joined'`, userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load party: %w", err)
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *parties) Invites(ctx context.Context, userID string) ([]*store.Party, error) {
+	if !validID(userID) {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT party_id FROM party_members
+		WHERE user_id = $1 AND status = 'invited'
+		ORDER BY invited_at, party_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list party invites: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list party invites: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list party invites: %w", err)
+	}
+	return r.loadParties(ctx, ids)
+}
+
+func (r *parties) Invite(ctx context.Context, partyID, userID string) error {
+	if !validID(partyID, userID) {
+		return store.ErrNotFound
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO party_members (party_id, user_id, status) VALUES ($1, $2, 'invited')`,
+		partyID, userID)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to invite to party: %w", err)
+	}
+	return nil
+}
+
+func (r *parties) Accept(ctx context.Context, partyID, userID string) error {
+	if !validID(partyID, userID) {
+		return store.ErrNotFound
+	}
+	// idx_party_members_joined_user rejects a user already in a party
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE party_members SET status = 'joined', joined_at = NOW()
+		WHERE party_id = $1 AND user_id = $2 AND status = 'invited'`, partyID, userID)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to accept party invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to accept party invite: %w", err)
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (r *parties) Leave(ctx context.Context, partyID, userID string) (bool, error) {
+	if !validID(partyID, userID) {
+		return false, nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the party serialises concurrent leaves over the new leader
+	var leaderID string
+	err = tx.QueryRowContext(ctx, `SELECT leader_id FROM parties WHERE id = $1 FOR UPDATE`, partyID).Scan(&leaderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to leave party: %w", err)
+	}
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM party_members WHERE party_id = $1 AND user_id = $2
+		RETURNING status`, partyID, userID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to leave party: %w", err)
+	}
+
+	if status == store.PartyJoined {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE game_queues SET status = 'cancelled'
+			WHERE party_id = $1 AND status = 'waiting'`, partyID)
+		if err != nil {
+			return false, fmt.Errorf("failed to cancel party queue entries: %w", err)
+		}
+		var next string
+		err = tx.QueryRowContext(ctx, `
+			SELECT user_id FROM party_members
+			WHERE party_id = $1 AND status = 'joined'
+			ORDER BY joined_at, user_id
+			LIMIT 1`, partyID).Scan(&next)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = tx.ExecContext(ctx, `DELETE FROM parties WHERE id = $1`, partyID)
+		case err == nil && userID == leaderID:
+			_, err = tx.ExecContext(ctx, `UPDATE parties SET leader_id = $2 WHERE id = $1`, partyID, next)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to leave party: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to leave party: %w", err)
+	}
+	return true, nil
+}
+
+type sessions struct{ db *sql.DB }
+
+const sessionColumns = `id, game_id, status, created_at, started_at, ended_at, session_data, open_slots`
+
+// freeSlots counts the places left in the game_sessions row s
+const freeSlots = `GREATEST(
+	(SELECT COALESCE(max_players, 4) FROM games WHERE games.id = s.game_id) -
+	(SELECT count(*) FROM game_session_participants p WHERE p.session_id = s.id AND p.left_at IS NULL), 0)`
+
+// refreshOpenSlots recounts the open slots of the sessions $1
+const refreshOpenSlots = `
+	UPDATE game_sessions s SET open_slots = CASE WHEN s.status = 'active' THEN ` + freeSlots + ` ELSE 0 END
+	WHERE s.id = ANY($1::uuid[])
+	RETURNING s.open_slots`
+
+func scanSession(row rowScanner) (*store.Session, error) {
+	s := &store.Session{}
+	var started, ended sql.NullTime
+	var result []byte
+	if err := row.Scan(&s.ID, &s.GameID, &s.Status, &s.CreatedAt, &started, &ended, &result, &s.OpenSlots); err != nil {
+		return nil, err
+	}
+	if started.Valid {
+		s.StartedAt = &started.Time
+	}
+	if ended.Valid {
+		s.EndedAt = &ended.Time
+	}
+	if result != nil {
+		s.Result = &store.SessionResult{}
+		if err := json.Unmarshal(result, s.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode session result: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (r *sessions) Create(ctx context.Context, s *store.Session, userIDs []string) error {
+	if !validID(s.GameID) {
+		return store.ErrNotFound
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	created, err := scanSession(tx.QueryRowContext(ctx, `
+		INSERT INTO game_sessions (game_id, status, started_at)
+		VALUES ($1, COALESCE(NULLIF($2, ''), 'pending'), CASE WHEN $2 = 'active' THEN NOW() END)
+		RETURNING `+sessionColumns, s.GameID, s.Status))
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	if len(userIDs) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO game_session_participants (session_id, user_id)
+			SELECT $1, unnest($2::uuid[])`, created.ID, pq.Array(userIDs))
+		if err != nil {
+			return fmt.Errorf("failed to add participants: %w", err)
+		}
+	}
+	err = tx.QueryRowContext(ctx, refreshOpenSlots, pq.Array([]string{created.ID})).Scan(&created.OpenSlots)
+	if err != nil {
+		return fmt.Errorf("failed to count open slots: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	*s = *created
+	return nil
+}
+
+func (r *sessions) Get(ctx context.Context, id string) (*store.Session, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	s, err := scanSession(r.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM game_sessions WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return s, nil
+}
+
+func (r *sessions) Open(ctx context.Context, gameID, userID string) (*store.Session, error) {
+	if !validID(gameID, userID) {
+		return nil, store.ErrNotFound
+	}
+	s, err := scanSession(r.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM game_sessions
+		WHERE game_id = $1 AND status IN ('pending', 'active')
+			AND id IN (SELECT session_id FROM game_session_participants WHERE user_id = $2 AND left_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`, gameID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return s, nil
+}
+
+// leaveEnded continues a WITH clause whose CTE s returns an updated session,
+// marking the remaining participants of an ended session left
+const leaveEnded = `, left_players AS (
+			UPDATE game_session_participants p SET left_at = s.ended_at
+			FROM s
+			WHERE p.session_id = s.id AND s.ended_at IS NOT NULL AND p.left_at IS NULL
+		)`
+
+func (r *sessions) Transition(ctx context.Context, id, to string) (*store.Session, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	// The status guard makes concurrent transitions of one session serialise
+	s, err := scanSession(r.db.QueryRowContext(ctx, `
+		WITH s AS (
+			UPDATE game_sessions s SET status = $2,
+				started_at = CASE WHEN $2 = 'active' THEN NOW() ELSE started_at END,
+				ended_at = CASE WHEN $2 IN ('completed', 'cancelled') THEN NOW() ELSE ended_at END,
+				open_slots = CASE WHEN $2 = 'active' THEN `+freeSlots+` ELSE 0 END
+			WHERE id = $1 AND status = ANY($3)
+			RETURNING `+sessionColumns+`
+		)`+leaveEnded+`
+		SELECT `+sessionColumns+` FROM s`, id, to, pq.Array(store.TransitionSources(to))))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, store.ErrInvalidTransition
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+	return s, nil
+}
+
+func (r *sessions) Complete(ctx context.Context, id string, result *store.SessionResult, rate store.RatingUpdate) (*store.Session, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode session result: %w", err)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	s, err := scanSession(tx.QueryRowContext(ctx, `
+		WITH s AS (
+			UPDATE game_sessions SET status = 'completed', ended_at = NOW(), session_data = $2, open_slots = 0
+			WHERE id = $1 AND status = ANY($3)
+			RETURNING `+sessionColumns+`
+		)`+leaveEnded+`
+		SELECT `+sessionColumns+` FROM s`, id, data, pq.Array(store.TransitionSources(store.SessionCompleted))))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, store.ErrInvalidTransition
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete session: %w", err)
+	}
+	if rate != nil {
+		if err := updateRatings(ctx, tx, s.GameID, slices.Sorted(maps.Keys(result.Placements())), rate); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to complete session: %w", err)
+	}
+	return s, nil
+}
+
+func (r *sessions) History(ctx context.Context, userID, gameID string, page store.Page) ([]*store.Session, error) {
+	after, _, afterID, ok := cursorArgs(page)
+	if !validID(userID) || (gameID != "" && !validID(gameID)) || !ok {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM game_sessions
+		WHERE status = 'completed' AND ($2::uuid IS NULL OR game_id = $2)
+			AND id IN (SELECT session_id FROM game_session_participants WHERE user_id = $1)
+			AND ($3::timestamptz IS NULL OR (created_at, id) < ($3, $4::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($5, 0)`, userID, nullID(gameID), after, afterID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list match history: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list match history: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *sessions) ListByGame(ctx context.Context, gameID, status string, page store.Page) ([]*store.Session, error) {
+	after, _, afterID, ok := cursorArgs(page)
+	if !validID(gameID) || !ok {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM game_sessions
+		WHERE game_id = $1 AND status = $2
+			AND ($3::timestamptz IS NULL OR (created_at, id) < ($3, $4::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($5, 0)`, gameID, status, after, afterID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *sessions) Participants(ctx context.Context, sessionID string) ([]*store.Participant, error) {
+	if !validID(sessionID) {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.email, u.username, u.display_name, COALESCE(u.avatar_url, ''), u.created_at,
+			COALESCE(p.role, ''), p.joined_at, p.last_seen_at, p.left_at
+		FROM game_session_participants p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.session_id = $1
+		ORDER BY p.joined_at, u.id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list participants: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.Participant
+	for rows.Next() {
+		p := &store.Participant{SessionID: sessionID}
+		var joined, lastSeen, left sql.NullTime
+		p.User, err = scanUser(rows, &p.Role, &joined, &lastSeen, &left)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list participants: %w", err)
+		}
+		p.JoinedAt = joined.Time
+		if lastSeen.Valid {
+			p.LastSeenAt = &lastSeen.Time
+		}
+		if left.Valid {
+			p.LeftAt = &left.Time
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *sessions) Presence(ctx context.Context, sessionID, event string, userIDs []string) error {
+	if !validID(sessionID) {
+		return store.ErrNotFound
+	}
+	// Locking the session keeps it from ending, and its open slots from
+	// being taken, while presence is recorded
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var open int
+	err = tx.QueryRowContext(ctx, `SELECT status, open_slots FROM game_sessions WHERE id = $1 FOR UPDATE`, sessionID).Scan(&status, &open)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record presence: %w", err)
+	}
+	if status != store.SessionPending && status != store.SessionActive {
+		return store.ErrInvalidTransition
+	}
+	if !validID(userIDs...) {
+		return nil
+	}
+
+	if event == store.PresenceJoin && status == store.SessionActive {
+		var returning int
+		err := tx.QueryRowContext(ctx, `
+			SELECT count(*) FROM game_session_participants
+			WHERE session_id = $1 AND user_id = ANY($2) AND left_at IS NOT NULL`, sessionID, pq.Array(userIDs)).Scan(&returning)
+		if err != nil {
+			return fmt.Errorf("failed to record presence: %w", err)
+		}
+		if returning > open {
+			return store.ErrConflict
+		}
+	}
+
+	var query string
+	switch event {
+	case store.PresenceJoin:
+		query = `UPDATE game_session_participants SET last_seen_at = NOW(), left_at = NULL
+			WHERE session_id = $1 AND user_id = ANY($2)`
+	case store.PresenceHeartbeat:
+		query = `UPDATE game_session_participants SET last_seen_at = NOW()
+			WHERE session_id = $1 AND user_id = ANY($2) AND left_at IS NULL`
+	case store.PresenceLeave:
+		query = `UPDATE game_session_participants SET left_at = NOW()
+			WHERE session_id = $1 AND user_id = ANY($2) AND left_at IS NULL`
+	default:
+		return fmt.Errorf("unknown presence event %q", event)
+	}
+	if _, err := tx.ExecContext(ctx, query, sessionID, pq.Array(userIDs)); err != nil {
+		return fmt.Errorf("failed to record presence: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, refreshOpenSlots, pq.Array([]string{sessionID})); err != nil {
+		return fmt.Errorf("failed to count open slots: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record presence: %w", err)
+	}
+	return nil
+}
+
+func (r *sessions) DropStale(ctx context.Context, before time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Players never seen only go stale in active sessions, counting from
+	// when they were placed or the session started, whichever is later
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE game_session_participants p SET left_at = NOW()
+		FROM game_sessions s
+		WHERE p.session_id = s.id AND s.status IN ('pending', 'active')
+			AND p.left_at IS NULL AND COALESCE(p.last_seen_at, p.joined_at) < $1
+			AND (p.last_seen_at IS NOT NULL OR (s.status = 'active' AND COALESCE(s.started_at, p.joined_at) < $1))
+		RETURNING p.session_id`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drop stale participants: %w", err)
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to drop stale participants: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to drop stale participants: %w", err)
+	}
+	if len(sessionIDs) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, refreshOpenSlots, pq.Array(sessionIDs)); err != nil {
+		return 0, fmt.Errorf("failed to count open slots: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to drop stale participants: %w", err)
+	}
+	return len(sessionIDs), nil
+}
+
+type ratings struct{ db *sql.DB }
+
+const ratingColumns = `user_id, game_id, rating, deviation, volatility, games_played, updated_at`
+
+func scanRating(row rowScanner) (*store.Rating, error) {
+	r := &store.Rating{}
+	err := row.Scan(&r.UserID, &r.GameID, &r.Value, &r.Deviation, &r.Volatility, &r.GamesPlayed, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ratings) Get(ctx context.Context, userID, gameID string) (*store.Rating, error) {
+	if !validID(userID, gameID) {
+		return nil, store.ErrNotFound
+	}
+	rating, err := scanRating(r.db.QueryRowContext(ctx, `
+		SELECT `+ratingColumns+` FROM user_ratings WHERE user_id = $1 AND game_id = $2`, userID, gameID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rating: %w", err)
+	}
+	return rating, nil
+}
+
+func (r *ratings) Update(ctx context.Context, gameID string, userIDs []string, update store.RatingUpdate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateRatings(ctx, tx, gameID, userIDs, update); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save ratings: %w", err)
+	}
+	return nil
+}
+
+// updateRatings implements Ratings.Update inside tx
+func updateRatings(ctx context.Context, tx *sql.Tx, gameID string, userIDs []string, update store.RatingUpdate) error {
+	if !validID(append([]string{gameID}, userIDs...)...) {
+		return store.ErrNotFound
+	}
+
+	// Rows are locked in key order so overlapping updates cannot deadlock
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+ratingColumns+` FROM user_ratings
+		WHERE game_id = $1 AND user_id = ANY($2::uuid[])
+		ORDER BY user_id
+		FOR UPDATE`, gameID, pq.Array(userIDs))
+	if err != nil {
+		return fmt.Errorf("failed to load ratings: %w", err)
+	}
+	current := make(map[string]*store.Rating)
+	for rows.Next() {
+		rating, err := scanRating(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to load ratings: %w", err)
+		}
+		current[rating.UserID] = rating
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load ratings: %w", err)
+	}
+
+	for _, rating := range update(current) {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_ratings (user_id, game_id, rating, deviation, volatility, games_played)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, game_id) DO UPDATE SET
+				rating = EXCLUDED.rating, deviation = EXCLUDED.deviation, volatility = EXCLUDED.volatility,
+				games_played = EXCLUDED.games_played, updated_at = NOW()`,
+			rating.UserID, gameID, rating.Value, rating.Deviation, rating.Volatility, rating.GamesPlayed)
+		if isForeignKeyViolation(err) {
+			return store.ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save rating: %w", err)
+		}
+	}
+	return nil
+}
+
+type goods struct{ db *sql.DB }
+
+const goodColumns = `id, COALESCE(game_id::text, ''), COALESCE(code, ''), name, COALESCE(description, ''),
+	COALESCE(category, ''), COALESCE(rarity, 'common'), COALESCE(is_tradeable, true), created_at`
+
+func scanGood(row rowScanner) (*store.Good, error) {
+	g := &store.Good{}
+	err := row.Scan(&g.ID, &g.GameID, &g.Code, &g.Name, &g.Description, &g.Category, &g.Rarity, &g.Tradeable, &g.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *goods) Create(ctx context.Context, g *store.Good) error {
+	if !validID(g.GameID) {
+		return store.ErrNotFound
+	}
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO digital_goods (game_id, code, name, description, category, rarity, is_tradeable)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), COALESCE(NULLIF($6, ''), 'common'), $7)
+		RETURNING `+goodColumns,
+		g.GameID, g.Code, g.Name, g.Description, g.Category, g.Rarity, g.Tradeable)
+	created, err := scanGood(row)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if isForeignKeyViolation(err) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create good: %w", err)
+	}
+	*g = *created
+	return nil
+}
+
+func (r *goods) Get(ctx context.Context, id string) (*store.Good, error) {
+	if !validID(id) {
+		return nil, store.ErrNotFound
+	}
+	g, err := scanGood(r.db.QueryRowContext(ctx, `
+		SELECT `+goodColumns+` FROM digital_goods WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load good: %w", err)
+	}
+	return g, nil
+}
+
+func (r *goods) List(ctx context.Context, gameID string, page store.Page) ([]*store.Good, error) {
+	_, after, afterID, ok := cursorArgs(page)
+	if (gameID != "" && !validID(gameID)) || !ok {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+goodColumns+` FROM digital_goods
+		WHERE ($1::uuid IS NULL OR game_id = $1)
+			AND ($2::text IS NULL OR (name, id) > ($2, $3::uuid))
+		ORDER BY name, id
+		LIMIT NULLIF($4, 0)`, nullID(gameID), after, afterID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goods: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.Good
+	for rows.Next() {
+		g, err := scanGood(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list goods: %w", err)
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+type inventory struct{ db *sql.DB }
+
+func (r *inventory) Grant(ctx context.Context, userID, goodID string, quantity int) (int, error) {
+	if !validID(userID, goodID) {
+		return 0, store.ErrNotFound
+	}
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO user_inventory (user_id, good_id, quantity)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, good_id) DO UPDATE SET quantity = user_inventory.quantity + EXCLUDED.quantity
+		RETURNING quantity`, userID, goodID, quantity).Scan(&total)
+	if isForeignKeyViolation(err) {
+		return 0, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to grant good: %w", err)
+	}
+	return total, nil
+}
+
+func (r *inventory) Revoke(ctx context.Context, userID, goodID string, quantity int) (int, error) {
+	if !validID(userID, goodID) {
+		return 0, store.ErrNotFound
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var left int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE user_inventory SET quantity = quantity - $3
+		WHERE user_id = $1 AND good_id = $2
+		RETURNING quantity`, userID, goodID, quantity).Scan(&left)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke good: %w", err)
+	}
+	if left <= 0 {
+		left = 0
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM user_inventory WHERE user_id = $1 AND good_id = $2`, userID, goodID); err != nil {
+			return 0, fmt.Errorf("failed to revoke good: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to revoke good: %w", err)
+	}
+	return left, nil
+}
+
+func (r *inventory) List(ctx context.Context, userID, gameID string, page store.Page) ([]*store.InventoryItem, error) {
+	after, _, afterID, ok := cursorArgs(page)
+	if !validID(userID) || (gameID != "" && !validID(gameID)) || !ok {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT i.quantity, i.acquired_at, d.id, COALESCE(d.game_id::text, ''), COALESCE(d.code, ''), d.name,
+			COALESCE(d.description, ''), COALESCE(d.category, ''), COALESCE(d.rarity, 'common'),
+			COALESCE(d.is_tradeable, true), d.created_at
+		FROM user_inventory i
+		JOIN digital_goods d ON d.id = i.good_id
+		WHERE i.user_id = $1 AND ($2::uuid IS NULL OR d.game_id = $2)
+			AND ($3::timestamptz IS NULL OR (i.acquired_at, d.id) < ($3, $4::uuid))
+		ORDER BY i.acquired_at DESC, d.id DESC
+		LIMIT NULLIF($5, 0)`, userID, nullID(gameID), after, afterID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*store.InventoryItem
+	for rows.Next() {
+		item := &store.InventoryItem{UserID: userID, Good: &store.Good{}}
+		g := item.Good
+		err := rows.Scan(&item.Quantity, &item.AcquiredAt, &g.ID, &g.GameID, &g.Code, &g.Name,
+			&g.Description, &g.Category, &g.Rarity, &g.Tradeable, &g.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inventory: %w", err)
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/scruffyprodigy/playhub/internal/store/storetest"
+)
+
+// testDB connects to DATABASE_URL and skips the test when it is not set
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set, skipping store database test")
+	}
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, New(testDB(t)))
+}
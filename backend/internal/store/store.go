@@ -0,0 +1,666 @@
+// Package store defines the repositories resolvers use to reach persistent
+// data. Package postgres implements them on the database; package memory keeps
+// everything in process memory for tests and database-less development.
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would violate a uniqueness rule
+	ErrConflict = errors.New("already exists")
+	// ErrInvalidTransition is returned when a session cannot move to the
+	// requested status from its current one
+	ErrInvalidTransition = errors.New("invalid session status transition")
+	// ErrInvalidCode is returned for good codes that are not usable as stable references
+	ErrInvalidCode = errors.New("code must be 1-64 characters of A-Z, 0-9, '_', '-' or '.'")
+	// ErrDisabled is returned when a login is started or completed for a
+	// deactivated account
+	ErrDisabled = errors.New("account is disabled")
+)
+
+// Store bundles the repositories
+type Store struct {
+	Users     Users
+	Games     Games
+	Queues    Queues
+	Parties   Parties
+	Sessions  Sessions
+	Ratings   Ratings
+	Goods     Goods
+	Inventory Inventory
+
+	MagicLinks   MagicLinks
+	AuthSessions AuthSessions
+	Roles        Roles
+	APIKeys      APIKeys
+}
+
+// Cursor is a keyset position in a list: the sort key and id of the last row
+// already returned. Lists ordered by time use Time, lists ordered by name use
+// Name, lists ordered by a count use Count and break ties with Time. Counts
+// change over time, so lists ordered by one also keep AsOf, the moment the
+// first page counted at, for later pages to count at the same moment.
+type Cursor struct {
+	Time  time.Time
+	Name  string
+	Count int
+	AsOf  time.Time
+	ID    string
+}
+
+// Page selects a window of a list. Rows strictly after After are returned, at
+// most Limit of them; Limit <= 0 means no limit.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// User is a row of users
+type User struct {
+	ID          string
+	Email       string
+	Username    string
+	DisplayName string
+	AvatarURL   string
+	CreatedAt   time.Time
+}
+
+// Game is a row of games. Optional columns are zero when unset.
+type Game struct {
+	ID                       string
+	Name                     string
+	Description              string
+	Version                  string
+	MinPlayers               int
+	MaxPlayers               int
+	EstimatedDurationMinutes int
+	Category                 string
+	Status                   string
+	OwnerID                  string
+	MaxQueueWaitSeconds      int    // 0 uses the server default
+	JoinURLTemplate          string // where players take their join ticket; see JoinURL
+	Backfill                 bool   // the matchmaker fills open slots of active sessions
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+
+	// Popularity is the number of sessions created in the PopularityWindow
+	// before GameSearch.AsOf. Only Games.Search sorting by SortPopular fills
+	// it in.
+	Popularity int
+}
+
+// Placeholders of Game.JoinURLTemplate
+const (
+	JoinURLTicket    = "{ticket}"
+	JoinURLSessionID = "{sessionId}"
+	JoinURLGameID    = "{gameId}"
+)
+
+// JoinURL fills the placeholders of the game's join URL template. It
+// returns "" when the game has no template.
+func (g *Game) JoinURL(sessionID, ticket string) string {
+	if g.JoinURLTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		JoinURLTicket, url.QueryEscape(ticket),
+		JoinURLSessionID, url.QueryEscape(sessionID),
+		JoinURLGameID, url.QueryEscape(g.ID),
+	).Replace(g.JoinURLTemplate)
+}
+
+// PopularityWindow is how far back sessions count towards a game's popularity
+const PopularityWindow = 30 * 24 * time.Hour
+
+// GameSearch filters and orders Games.Search. Zero fields do not filter.
+type GameSearch struct {
+	// Query matches name prefixes and word prefixes in the name, description
+	// and category, ignoring case
+	Query    string
+	Category string
+	Status   string
+	// MinPlayers and MaxPlayers keep games playable by some group size in
+	// between
+	MinPlayers int
+	MaxPlayers int
+	Sort       GameSort
+	// AsOf is the moment popularity is counted at; zero means now. Every
+	// page of a search sorted by SortPopular passes the same AsOf so that
+	// sessions created in between do not reorder the games.
+	AsOf time.Time
+}
+
+// GameSort orders game search results
+type GameSort string
+
+// Game search orders. Cursors hold the sort key of the order: the creation
+// time, the name, or the popularity, creation time and AsOf.
+const (
+	SortNewest  GameSort = "newest"
+	SortName    GameSort = "name"
+	SortPopular GameSort = "popular"
+)
+
+// Game statuses
+const (
+	GameActive      = "active"
+	GameInactive    = "inactive"
+	GameMaintenance = "maintenance"
+)
+
+// QueueEntry is a row of game_queues
+type QueueEntry struct {
+	ID          string
+	GameID      string
+	UserID      string
+	Status      string
+	Priority    int
+	Preferences QueuePreferences
+	JoinedAt    time.Time
+	MatchedAt   *time.Time
+	ExpiresAt   *time.Time
+	SessionID   string // session the entry was matched into
+
+	// A party queues as one entry per member sharing PartyID. The entries
+	// are matched together, and only once all PartySize of them are present.
+	PartyID   string
+	PartySize int
+}
+
+// QueuePreferences is the preferences JSON of a queue entry. Empty fields
+// match anyone.
+type QueuePreferences struct {
+	Region      string `json:"region,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	SkillRating *int   `json:"skillRating,omitempty"`
+}
+
+// Queue entry statuses
+const (
+	QueueWaiting   = "waiting"
+	QueueMatched   = "matched"
+	QueueCancelled = "cancelled"
+	QueueExpired   = "expired"
+)
+
+// Party is a row of parties with its members, leader first and then in
+// joining order, invited users last
+type Party struct {
+	ID        string
+	LeaderID  string
+	CreatedAt time.Time
+	Members   []*PartyMember
+}
+
+// Joined returns the members who accepted their invite
+func (p *Party) Joined() []*PartyMember {
+	var out []*PartyMember
+	for _, m := range p.Members {
+		if m.Status == PartyJoined {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Member returns the member or invitee userID, or nil
+func (p *Party) Member(userID string) *PartyMember {
+	for _, m := range p.Members {
+		if m.User.ID == userID {
+			return m
+		}
+	}
+	return nil
+}
+
+// PartyMember is a row of party_members with its user
+type PartyMember struct {
+	User      *User
+	Status    string
+	InvitedAt time.Time
+	JoinedAt  *time.Time
+}
+
+// Party member statuses
+const (
+	PartyInvited = "invited"
+	PartyJoined  = "joined"
+)
+
+// Rating is a row of user_ratings: a user's Glicko-2 rating for a game
+type Rating struct {
+	UserID      string
+	GameID      string
+	Value       float64
+	Deviation   float64
+	Volatility  float64
+	GamesPlayed int
+	UpdatedAt   time.Time
+}
+
+// Session is a row of game_sessions. StartedAt is set when the session goes
+// active and EndedAt when it completes or is cancelled.
+type Session struct {
+	ID        string
+	GameID    string
+	Status    string
+	CreatedAt time.Time
+	StartedAt *time.Time
+	EndedAt   *time.Time
+	Result    *SessionResult // set once the game server reports the outcome
+	// OpenSlots is how many more players an active session has room for:
+	// the game's maximum less the participants who have not left. It is 0
+	// while the session is not active.
+	OpenSlots int
+}
+
+// Participant is a row of game_session_participants. The game server reports
+// when the player connects, sends heartbeats while they stay and reports
+// when they leave.
+type Participant struct {
+	SessionID  string
+	User       *User
+	Role       string
+	JoinedAt   time.Time  // when the player was placed in the session
+	LastSeenAt *time.Time // the last join or heartbeat
+	LeftAt     *time.Time
+}
+
+// Participant statuses
+const (
+	ParticipantMatched   = "matched" // not seen by the game server yet
+	ParticipantConnected = "connected"
+	ParticipantLeft      = "left"
+)
+
+// Status derives the participant's status from their presence times
+func (p *Participant) Status() string {
+	switch {
+	case p.LeftAt != nil:
+		return ParticipantLeft
+	case p.LastSeenAt == nil:
+		return ParticipantMatched
+	}
+	return ParticipantConnected
+}
+
+// Presence events reported by game servers
+const (
+	PresenceJoin      = "join"
+	PresenceHeartbeat = "heartbeat"
+	PresenceLeave     = "leave"
+)
+
+// SessionResult is the outcome a game server reports for a session, kept in
+// game_sessions.session_data
+type SessionResult struct {
+	Players []PlayerResult `json:"players"` // best placement first
+	Stats   map[string]any `json:"stats,omitempty"`
+}
+
+// PlayerResult is one participant's outcome. Placements start at 1 for the
+// winner and tied players share one.
+type PlayerResult struct {
+	UserID    string         `json:"userId"`
+	Placement int            `json:"placement"`
+	Score     *float64       `json:"score,omitempty"`
+	Stats     map[string]any `json:"stats,omitempty"`
+}
+
+// Placements maps each player of the result to their placement
+func (r *SessionResult) Placements() map[string]int {
+	out := make(map[string]int, len(r.Players))
+	for _, p := range r.Players {
+		out[p.UserID] = p.Placement
+	}
+	return out
+}
+
+// Session statuses. A session is created pending, goes active when its game
+// server starts it and ends completed or cancelled; see CanTransition.
+const (
+	SessionPending   = "pending"
+	SessionActive    = "active"
+	SessionCompleted = "completed"
+	SessionCancelled = "cancelled"
+)
+
+// sessionTransitions lists the statuses each session status may move to
+var sessionTransitions = map[string][]string{
+	SessionPending: {SessionActive, SessionCancelled},
+	SessionActive:  {SessionCompleted, SessionCancelled},
+}
+
+// CanTransition reports whether a session may move from status from to to
+func CanTransition(from, to string) bool {
+	return slices.Contains(sessionTransitions[from], to)
+}
+
+// TransitionSources returns the statuses a session may move to status to from
+func TransitionSources(to string) []string {
+	var out []string
+	for from, targets := range sessionTransitions {
+		if slices.Contains(targets, to) {
+			out = append(out, from)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Good is a row of digital_goods
+type Good struct {
+	ID          string
+	GameID      string
+	Code        string
+	Name        string
+	Description string
+	Category    string
+	Rarity      string
+	Tradeable   bool
+	CreatedAt   time.Time
+}
+
+// InventoryItem is a row of user_inventory together with its good
+type InventoryItem struct {
+	UserID     string
+	Good       *Good
+	Quantity   int
+	AcquiredAt time.Time
+}
+
+// AuthSession is a row of auth_sessions: a login session that its refresh
+// token, stored hashed, keeps alive until it expires or is revoked
+type AuthSession struct {
+	ID        string
+	UserID    string
+	UserAgent string
+	IP        string
+	ExpiresAt time.Time
+}
+
+// APIKey is a row of game_api_keys: a game server credential. Only the hash
+// of the raw key is stored.
+type APIKey struct {
+	ID         string
+	GameID     string
+	Name       string
+	Prefix     string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Users reads accounts
+type Users interface {
+	// Get returns the active user with the given id
+	Get(ctx context.Context, id string) (*User, error)
+	// Create inserts u and fills in its id and creation time
+	Create(ctx context.Context, u *User) error
+}
+
+// Games stores the game catalog
+type Games interface {
+	// Create inserts g and fills in its generated columns. It fails with
+	// ErrConflict when another game has the same name, ignoring case.
+	Create(ctx context.Context, g *Game) error
+	Get(ctx context.Context, id string) (*Game, error)
+	// Update writes the editable columns of g and refreshes its update time.
+	// It fails with ErrNotFound for unknown games and ErrConflict when another
+	// game has the same name, ignoring case.
+	Update(ctx context.Context, g *Game) error
+	// List returns games ordered by creation time, newest first. Cursors
+	// hold the creation time.
+	List(ctx context.Context, page Page) ([]*Game, error)
+	// Search returns the games matching search in its order
+	Search(ctx context.Context, search GameSearch, page Page) ([]*Game, error)
+}
+
+// Queues stores players waiting for a match
+type Queues interface {
+	// Enqueue inserts a waiting entry. It fails with ErrConflict when the user
+	// is already waiting for the game.
+	Enqueue(ctx context.Context, e *QueueEntry) error
+	// EnqueueParty inserts the entries of a party's members in one
+	// transaction, setting their PartySize. It fails with ErrConflict when
+	// any member is already waiting for the game.
+	EnqueueParty(ctx context.Context, entries []*QueueEntry) error
+	// Waiting returns the user's waiting entry for the game
+	Waiting(ctx context.Context, gameID, userID string) (*QueueEntry, error)
+	// Cancel marks the user's waiting entry cancelled, with those of the rest
+	// of their party, and reports whether there was one
+	Cancel(ctx context.Context, gameID, userID string) (bool, error)
+	// SetPriority changes the priority of the user's waiting entry, and of
+	// the rest of their party, and reports whether there was one
+	SetPriority(ctx context.Context, gameID, userID string, priority int) (bool, error)
+	// Position returns the 1-based place of the waiting entry e in its game's queue
+	Position(ctx context.Context, e *QueueEntry) (int, error)
+	// ListWaiting returns the waiting entries of a game in queue order:
+	// highest priority first, then longest waiting
+	ListWaiting(ctx context.Context, gameID string, limit int) ([]*QueueEntry, error)
+	// Expire marks the waiting entries whose expiry time is not after now
+	// expired and returns them
+	Expire(ctx context.Context, now time.Time) ([]*QueueEntry, error)
+	// WaitingGames returns the active games that have waiting entries
+	WaitingGames(ctx context.Context) ([]*Game, error)
+	// Match claims the waiting entries of a game in queue order, skipping
+	// those claimed by a concurrent Match, and passes them to pick. When pick
+	// returns entries, it creates a pending session of their users and marks
+	// them matched in one transaction. It returns nil when pick returns none.
+	Match(ctx context.Context, gameID string, pick func(waiting []*QueueEntry) []*QueueEntry) (*Session, error)
+	// Backfill claims the active sessions of a game with open slots, oldest
+	// first, and the waiting entries as Match does, skipping those claimed
+	// concurrently. It passes each session in turn to pick with the matched
+	// entries of its remaining players. For the first session pick returns
+	// entries for, it adds their users to the session, marks them matched
+	// and updates the open slots in one transaction. It returns nil when
+	// pick accepts no session.
+	Backfill(ctx context.Context, gameID string, pick func(s *Session, players, waiting []*QueueEntry) []*QueueEntry) (*Session, error)
+}
+
+// Parties stores groups of friends who queue together. A user belongs to at
+// most one party at a time.
+type Parties interface {
+	// Create inserts p with its leader as the only member. It fails with
+	// ErrConflict when the leader is already in a party.
+	Create(ctx context.Context, p *Party) error
+	Get(ctx context.Context, id string) (*Party, error)
+	// ForUser returns the party the user joined
+	ForUser(ctx context.Context, userID string) (*Party, error)
+	// Invites returns the parties that invited the user, oldest invite first
+	Invites(ctx context.Context, userID string) ([]*Party, error)
+	// Invite adds userID to the party as invited. It fails with ErrConflict
+	// when the user is already a member or invitee, and ErrNotFound when the
+	// party or user does not exist.
+	Invite(ctx context.Context, partyID, userID string) error
+	// Accept turns the user's invite into membership. It fails with
+	// ErrNotFound without an invite and ErrConflict when the user is already
+	// in a party.
+	Accept(ctx context.Context, partyID, userID string) error
+	// Leave removes the member or invitee and reports whether there was one.
+	// A member leaving cancels the party's waiting queue entries and passes
+	// the lead to the longest standing member; the party is deleted when no
+	// member is left.
+	Leave(ctx context.Context, partyID, userID string) (bool, error)
+}
+
+// Sessions stores matched game sessions and their participants
+type Sessions interface {
+	// Create inserts s with the given participants. Sessions are pending
+	// unless s says otherwise.
+	Create(ctx context.Context, s *Session, userIDs []string) error
+	Get(ctx context.Context, id string) (*Session, error)
+	// Open returns the user's newest pending or active session of the game
+	// that they have not left
+	Open(ctx context.Context, gameID, userID string) (*Session, error)
+	// Transition moves the session to status to and stamps its start or end
+	// time. Ending a session marks its remaining participants left. It fails
+	// with ErrInvalidTransition when CanTransition forbids the move from the
+	// current status.
+	Transition(ctx context.Context, id, to string) (*Session, error)
+	// Complete moves an active session to completed with its result. When
+	// rate is not nil, the ratings of the players placed in result are
+	// updated with it, as Ratings.Update does, in the same transaction. It
+	// fails with ErrInvalidTransition when the session is not active.
+	Complete(ctx context.Context, id string, result *SessionResult, rate RatingUpdate) (*Session, error)
+	// History returns the completed sessions the user took part in, of
+	// gameID or of every game when it is empty, newest first. Cursors hold
+	// the creation time.
+	History(ctx context.Context, userID, gameID string, page Page) ([]*Session, error)
+	// ListByGame returns sessions of a game in the given status, newest
+	// first. Cursors hold the creation time.
+	ListByGame(ctx context.Context, gameID, status string, page Page) ([]*Session, error)
+	// Participants returns the players placed in the session, in joining
+	// order
+	Participants(ctx context.Context, sessionID string) ([]*Participant, error)
+	// Presence records event for the listed participants of a pending or
+	// active session and updates its open slots. A join marks the players
+	// connected, also after they left; a heartbeat refreshes players who
+	// have not left and a leave marks them left. It fails with
+	// ErrInvalidTransition once the session has ended, and with ErrConflict
+	// when players who left would rejoin an active session without room for
+	// them.
+	Presence(ctx context.Context, sessionID, event string, userIDs []string) error
+	// DropStale marks the participants of pending and active sessions last
+	// seen before the given time as left and returns how many it marked.
+	// Players the game server never reported stay in pending sessions and
+	// count as seen in active ones when they were placed or the session
+	// started, whichever is later.
+	DropStale(ctx context.Context, before time.Time) (int, error)
+}
+
+// RatingUpdate computes new ratings of players for a game from their current
+// ratings, keyed by user and leaving out unrated players
+type RatingUpdate func(current map[string]*Rating) []*Rating
+
+// Ratings stores per-game skill ratings
+type Ratings interface {
+	// Get returns the user's rating for the game. It fails with ErrNotFound
+	// while the user is unrated.
+	Get(ctx context.Context, userID, gameID string) (*Rating, error)
+	// Update passes the current ratings of the users for the game to update
+	// and saves the ratings it returns. Concurrent updates of the same
+	// ratings are serialised.
+	Update(ctx context.Context, gameID string, userIDs []string, update RatingUpdate) error
+}
+
+// Goods stores digital goods
+type Goods interface {
+	// Create inserts g. It fails with ErrNotFound when the game does not exist
+	// and ErrConflict when the game already has a good with the same code.
+	Create(ctx context.Context, g *Good) error
+	Get(ctx context.Context, id string) (*Good, error)
+	// List returns the goods of gameID, or of every game when gameID is "",
+	// ordered by name. Cursors hold the name.
+	List(ctx context.Context, gameID string, page Page) ([]*Good, error)
+}
+
+// Inventory stores the goods owned by users
+type Inventory interface {
+	// Grant adds quantity of a good to a user and returns the new quantity
+	Grant(ctx context.Context, userID, goodID string, quantity int) (int, error)
+	// Revoke removes up to quantity of a good from a user and returns what is
+	// left. It fails with ErrNotFound when the user does not own the good.
+	Revoke(ctx context.Context, userID, goodID string, quantity int) (int, error)
+	// List returns a user's items, optionally restricted to one game, most
+	// recently acquired first. Cursors hold the acquisition time and the good id.
+	List(ctx context.Context, userID, gameID string, page Page) ([]*InventoryItem, error)
+}
+
+// MagicLinks stores single-use login tokens by their hash
+type MagicLinks interface {
+	// Create stores a link for email that expires at expiresAt. It fails
+	// with ErrDisabled when the address belongs to a deactivated account.
+	Create(ctx context.Context, email, tokenHash string, expiresAt time.Time) error
+	// Redeem marks the unused, unexpired link with the hash used and returns
+	// the account of its address, verified and with its login time updated.
+	// The account is created from newUser when the address has none. It
+	// fails with ErrNotFound when no link can be redeemed, and with
+	// ErrDisabled, using up the link, when its account was deactivated.
+	Redeem(ctx context.Context, tokenHash string, newUser func(email string) (*User, error)) (*User, error)
+	// DeleteExpired deletes the links that expired before cutoff, used or
+	// not, and returns how many it deleted
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// AuthSessions stores login sessions by the hash of their refresh token
+type AuthSessions interface {
+	// Create inserts s with its refresh token hash and fills in its id. It
+	// fails with ErrNotFound when the user does not exist.
+	Create(ctx context.Context, s *AuthSession, tokenHash string) error
+	// Rotate swaps the refresh token hash of the live session holding
+	// oldHash for newHash, extends it to expiresAt and returns it with its
+	// active user. It fails with ErrNotFound when no live session holds
+	// oldHash.
+	Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*AuthSession, *User, error)
+	// Revoke ends the session with the given id
+	Revoke(ctx context.Context, id string) error
+	// RevokeByToken ends the session holding the refresh token hash
+	RevokeByToken(ctx context.Context, tokenHash string) error
+	// RevokeAllForUser ends every live session of the user and returns how
+	// many it ended
+	RevokeAllForUser(ctx context.Context, userID string) (int, error)
+	// IsActive reports whether the session exists and is neither revoked
+	// nor expired
+	IsActive(ctx context.Context, id string) (bool, error)
+}
+
+// Roles stores the roles granted to users
+type Roles interface {
+	// ForUser returns the roles of the user in name order
+	ForUser(ctx context.Context, userID string) ([]string, error)
+	// Grant gives role to the user; granting a role the user holds does
+	// nothing. It fails with ErrNotFound when the user does not exist.
+	Grant(ctx context.Context, userID, role, grantedBy string) error
+	// Revoke takes role away from the user
+	Revoke(ctx context.Context, userID, role string) error
+}
+
+// APIKeys stores game API keys by their hash
+type APIKeys interface {
+	// Create inserts k with the hash of its raw key and fills in its id and
+	// creation time. It fails with ErrNotFound when the game does not exist.
+	Create(ctx context.Context, k *APIKey, keyHash, createdBy string) error
+	// Get returns the key with the given id. It fails with ErrNotFound when
+	// there is none.
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// List returns the keys of a game, newest first, revoked ones included
+	List(ctx context.Context, gameID string) ([]*APIKey, error)
+	// Revoke disables the key with the given id; revoking a revoked key
+	// does nothing. It fails with ErrNotFound when there is no such key.
+	Revoke(ctx context.Context, id string) error
+	// Authenticate returns the unrevoked key with the hash and records its
+	// use when the last recorded one is over a minute old. It fails with
+	// ErrNotFound when there is none.
+	Authenticate(ctx context.Context, keyHash string) (*APIKey, error)
+}
+
+var searchWordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// SearchWords splits a search query into lower-case words of letters and digits
+func SearchWords(query string) []string {
+	return searchWordPattern.FindAllString(strings.ToLower(query), -1)
+}
+
+var goodCodePattern = regexp.MustCompile(`^[A-Z0-9_.-]{1,64}$`)
+
+// NormalizeGoodCode upper-cases code and checks that it is a valid good code
+func NormalizeGoodCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !goodCodePattern.MatchString(code) {
+		return "", ErrInvalidCode
+	}
+	return code, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeGoodCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"skin_001", "SKIN_001", nil},
+		{"  Weapon-1.v2 ", "WEAPON-1.V2", nil},
+		{"", "", ErrInvalidCode},
+		{"has space", "", ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeGoodCode(tt.in)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("NormalizeGoodCode(%q) = %q, %v; expected %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Deep  Space", "deep space"},
+		{"co-op & (shooter):*", "co op shooter"},
+		{"Échecs 2", "échecs 2"},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(SearchWords(tt.in), " "); got != tt.want {
+			t.Errorf("SearchWords(%q) = %q; expected %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	for _, c := range []struct {
+		from, to string
+		want     bool
+	}{
+		{SessionPending, SessionActive, true},
+		{SessionPending, SessionCancelled, true},
+		{SessionPending, SessionCompleted, false},
+		{SessionActive, SessionCompleted, true},
+		{SessionActive, SessionCancelled, true},
+		{SessionActive, SessionPending, false},
+		{SessionCompleted, SessionActive, false},
+		{SessionCancelled, SessionCancelled, false},
+	} {
+		if got := CanTransition(c.from, c.to); got != c.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+	if got := TransitionSources(SessionCancelled); len(got) != 2 || got[0] != SessionActive || got[1] != SessionPending {
+		t.Errorf("TransitionSources(cancelled) = %v, want [active pending]", got)
+	}
+}
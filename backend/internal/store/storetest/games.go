@@ -0,0 +1,169 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// word returns a random lower-case word for full-text searches that only
+// the rows of one test match
+func word() string {
+	return "zq" + strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return 'g' + r - '0'
+		}
+		return r
+	}, uuid.NewString()[:8])
+}
+
+// gameIDs returns the ids of games in order
+func gameIDs(games []*store.Game) []string {
+	out := make([]string, len(games))
+	for i, g := range games {
+		out[i] = g.ID
+	}
+	return out
+}
+
+func testGameSearch(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	tag := word()
+	alpha := &store.Game{Name: tag + " Alpha", Description: "An ancient strategy game", MinPlayers: 2, MaxPlayers: 2}
+	beta := &store.Game{Name: tag + " Beta", Category: "Puzzle", MinPlayers: 1, MaxPlayers: 1}
+	gamma := &store.Game{Name: tag + " Gamma", MinPlayers: 3, MaxPlayers: 8, Status: store.GameInactive}
+	for _, g := range []*store.Game{alpha, beta, gamma} {
+		if err := st.Games.Create(ctx, g); err != nil {
+			t.Fatalf("Create game failed: %v", err)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		search store.GameSearch
+		want   []*store.Game
+	}{
+		"a name prefix":       {store.GameSearch{Query: strings.ToUpper(tag) + " al"}, []*store.Game{alpha}},
+		"word prefixes":       {store.GameSearch{Query: tag + " strat"}, []*store.Game{alpha}},
+		"a category":          {store.GameSearch{Query: tag, Category: "puzzle"}, []*store.Game{beta}},
+		"a status":            {store.GameSearch{Query: tag, Status: store.GameInactive}, []*store.Game{gamma}},
+		"a group size":        {store.GameSearch{Query: tag, MinPlayers: 2, MaxPlayers: 2}, []*store.Game{alpha}},
+		"newest first":        {store.GameSearch{Query: tag}, []*store.Game{gamma, beta, alpha}},
+		"by name":             {store.GameSearch{Query: tag, Sort: store.SortName}, []*store.Game{alpha, beta, gamma}},
+		"an unknown word":     {store.GameSearch{Query: tag + " " + word()}, []*store.Game{}},
+		"another name prefix": {store.GameSearch{Query: tag + " Gamm"}, []*store.Game{gamma}},
+	} {
+		got, err := st.Games.Search(ctx, tc.search, store.Page{})
+		if err != nil {
+			t.Fatalf("Search %s failed: %v", name, err)
+		}
+		if !slices.Equal(gameIDs(got), gameIDs(tc.want)) {
+			t.Errorf("Search %s = %v, expected %v", name, gameIDs(got), gameIDs(tc.want))
+		}
+	}
+
+	byName := store.GameSearch{Query: tag, Sort: store.SortName}
+	first, err := st.Games.Search(ctx, byName, store.Page{Limit: 2})
+	if err != nil || !slices.Equal(gameIDs(first), []string{alpha.ID, beta.ID}) {
+		t.Fatalf("Search = %v, %v; expected the first two by name", gameIDs(first), err)
+	}
+	rest, err := st.Games.Search(ctx, byName, store.Page{After: &store.Cursor{Name: first[1].Name, ID: first[1].ID}})
+	if err != nil || !slices.Equal(gameIDs(rest), []string{gamma.ID}) {
+		t.Errorf("Search after %s = %v, %v; expected the last by name", beta.Name, gameIDs(rest), err)
+	}
+
+	// Popularity counts the sessions of each game up to AsOf, which is a
+	// minute ahead to allow for a database clock running ahead of ours
+	for _, g := range []*store.Game{alpha, beta, beta} {
+		if err := st.Sessions.Create(ctx, &store.Session{GameID: g.ID}, nil); err != nil {
+			t.Fatalf("Create session failed: %v", err)
+		}
+	}
+	popular := store.GameSearch{Query: tag, Sort: store.SortPopular, AsOf: time.Now().Add(time.Minute)}
+	first, err = st.Games.Search(ctx, popular, store.Page{Limit: 2})
+	if err != nil || !slices.Equal(gameIDs(first), []string{beta.ID, alpha.ID}) {
+		t.Fatalf("Search = %v, %v; expected the most played first", gameIDs(first), err)
+	}
+	if first[0].Popularity != 2 || first[1].Popularity != 1 {
+		t.Errorf("Expected popularities 2 and 1, got %d and %d", first[0].Popularity, first[1].Popularity)
+	}
+	last := first[1]
+	after := &store.Cursor{Count: last.Popularity, Time: last.CreatedAt, AsOf: popular.AsOf, ID: last.ID}
+	rest, err = st.Games.Search(ctx, popular, store.Page{After: after})
+	if err != nil || !slices.Equal(gameIDs(rest), []string{gamma.ID}) || rest[0].Popularity != 0 {
+		t.Errorf("Search after %s = %v, %v; expected the unplayed game", last.Name, gameIDs(rest), err)
+	}
+
+	popular.AsOf = time.Now().Add(-time.Hour)
+	got, err := st.Games.Search(ctx, popular, store.Page{})
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Search = %v, %v; expected all three games", gameIDs(got), err)
+	}
+	for _, g := range got {
+		if g.Popularity != 0 {
+			t.Errorf("Expected sessions after AsOf not to count, got %d for %s", g.Popularity, g.Name)
+		}
+	}
+}
+
+func testInventory(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game, other := newGame(t, st), newGame(t, st)
+	user := newUser(t, st)
+	var goods []*store.Good
+	for i, g := range []*store.Game{game, game, other} {
+		good := &store.Good{GameID: g.ID, Code: "ITEM_" + string(rune('A'+i)), Name: unique("good")}
+		if err := st.Goods.Create(ctx, good); err != nil {
+			t.Fatalf("Create good failed: %v", err)
+		}
+		goods = append(goods, good)
+	}
+
+	for _, grant := range []struct {
+		good     *store.Good
+		quantity int
+		want     int
+	}{{goods[0], 2, 2}, {goods[0], 1, 3}, {goods[1], 1, 1}, {goods[2], 1, 1}} {
+		if n, err := st.Inventory.Grant(ctx, user.ID, grant.good.ID, grant.quantity); err != nil || n != grant.want {
+			t.Fatalf("Grant = %d, %v; expected %d", n, err, grant.want)
+		}
+	}
+
+	goodIDs := func(items []*store.InventoryItem) []string {
+		out := make([]string, len(items))
+		for i, item := range items {
+			out[i] = item.Good.ID
+		}
+		return out
+	}
+	first, err := st.Inventory.List(ctx, user.ID, "", store.Page{Limit: 2})
+	if err != nil || !slices.Equal(goodIDs(first), []string{goods[2].ID, goods[1].ID}) {
+		t.Fatalf("List = %v, %v; expected the latest two goods", goodIDs(first), err)
+	}
+	after := &store.Cursor{Time: first[1].AcquiredAt, ID: first[1].Good.ID}
+	rest, err := st.Inventory.List(ctx, user.ID, "", store.Page{After: after})
+	if err != nil || !slices.Equal(goodIDs(rest), []string{goods[0].ID}) || rest[0].Quantity != 3 {
+		t.Errorf("List after %s = %v, %v; expected the first good, three times", goods[1].ID, goodIDs(rest), err)
+	}
+	if items, err := st.Inventory.List(ctx, user.ID, game.ID, store.Page{}); err != nil || !slices.Equal(goodIDs(items), []string{goods[1].ID, goods[0].ID}) {
+		t.Errorf("List of %s = %v, %v; expected only its goods", game.ID, goodIDs(items), err)
+	}
+
+	if n, err := st.Inventory.Revoke(ctx, user.ID, goods[0].ID, 2); err != nil || n != 1 {
+		t.Errorf("Revoke = %d, %v; expected 1 left", n, err)
+	}
+	if n, err := st.Inventory.Revoke(ctx, user.ID, goods[0].ID, 5); err != nil || n != 0 {
+		t.Errorf("Revoke = %d, %v; expected none left", n, err)
+	}
+	if _, err := st.Inventory.Revoke(ctx, user.ID, goods[0].ID, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a good the user no longer owns, got %v", err)
+	}
+	if items, err := st.Inventory.List(ctx, user.ID, game.ID, store.Page{}); err != nil || !slices.Equal(goodIDs(items), []string{goods[1].ID}) {
+		t.Errorf("List = %v, %v; expected the revoked good gone", goodIDs(items), err)
+	}
+}
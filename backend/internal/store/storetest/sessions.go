@@ -0,0 +1,382 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// userIDs returns the ids of the participants' users in order
+func userIDs(participants []*store.Participant) []string {
+	out := make([]string, len(participants))
+	for i, p := range participants {
+		out[i] = p.User.ID
+	}
+	return out
+}
+
+// statuses returns the participants' statuses keyed by user id
+func statuses(t *testing.T, st store.Store, sessionID string) map[string]string {
+	t.Helper()
+	participants, err := st.Sessions.Participants(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("Participants failed: %v", err)
+	}
+	out := make(map[string]string, len(participants))
+	for _, p := range participants {
+		out[p.User.ID] = p.Status()
+	}
+	return out
+}
+
+func newSession(t *testing.T, st store.Store, s *store.Session, users ...*store.User) *store.Session {
+	t.Helper()
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	if err := st.Sessions.Create(context.Background(), s, ids); err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+	return s
+}
+
+func enqueue(t *testing.T, st store.Store, e *store.QueueEntry) *store.QueueEntry {
+	t.Helper()
+	if err := st.Queues.Enqueue(context.Background(), e); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	return e
+}
+
+func testMatch(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	a, b := newUser(t, st), newUser(t, st)
+	first := enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: a.ID})
+	second := enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: b.ID, Priority: 5})
+
+	waiting, err := st.Queues.ListWaiting(ctx, game.ID, 0)
+	if err != nil || len(waiting) != 2 || waiting[0].ID != second.ID || waiting[1].ID != first.ID {
+		t.Fatalf("ListWaiting = %v, %v; expected the higher priority first", waiting, err)
+	}
+	if pos, err := st.Queues.Position(ctx, first); err != nil || pos != 2 {
+		t.Errorf("Position = %d, %v; expected 2", pos, err)
+	}
+
+	s, err := st.Queues.Match(ctx, game.ID, func([]*store.QueueEntry) []*store.QueueEntry { return nil })
+	if err != nil || s != nil {
+		t.Fatalf("Match = %+v, %v; expected no session when pick takes nobody", s, err)
+	}
+	var offered []string
+	s, err = st.Queues.Match(ctx, game.ID, func(waiting []*store.QueueEntry) []*store.QueueEntry {
+		for _, e := range waiting {
+			offered = append(offered, e.ID)
+		}
+		return waiting
+	})
+	if err != nil || s == nil {
+		t.Fatalf("Match = %+v, %v; expected a session", s, err)
+	}
+	if !slices.Equal(offered, []string{second.ID, first.ID}) {
+		t.Errorf("Expected pick to get the entries in queue order, got %v", offered)
+	}
+	if s.GameID != game.ID || s.Status != store.SessionPending {
+		t.Errorf("Expected a pending session of %s, got %+v", game.ID, s)
+	}
+	participants, err := st.Sessions.Participants(ctx, s.ID)
+	if err != nil || len(participants) != 2 || !slices.Contains(userIDs(participants), a.ID) || !slices.Contains(userIDs(participants), b.ID) {
+		t.Errorf("Participants = %v, %v; expected both players", participants, err)
+	}
+	if _, err := st.Queues.Waiting(ctx, game.ID, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected matched players to stop waiting, got %v", err)
+	}
+	if open, err := st.Sessions.Open(ctx, game.ID, b.ID); err != nil || open.ID != s.ID {
+		t.Errorf("Open = %+v, %v; expected session %s", open, err, s.ID)
+	}
+}
+
+func testBackfill(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := &store.Game{Name: unique("game"), MinPlayers: 1, MaxPlayers: 2}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatalf("Create game failed: %v", err)
+	}
+	a, b, c := newUser(t, st), newUser(t, st), newUser(t, st)
+	first := enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: a.ID})
+	enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: b.ID})
+	s, err := st.Queues.Match(ctx, game.ID, func(waiting []*store.QueueEntry) []*store.QueueEntry { return waiting })
+	if err != nil || s == nil {
+		t.Fatalf("Match = %+v, %v; expected a session", s, err)
+	}
+	if _, err := st.Sessions.Transition(ctx, s.ID, store.SessionActive); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if err := st.Sessions.Presence(ctx, s.ID, store.PresenceLeave, []string{b.ID}); err != nil {
+		t.Fatalf("Presence failed: %v", err)
+	}
+	if got, err := st.Sessions.Get(ctx, s.ID); err != nil || got.OpenSlots != 1 {
+		t.Fatalf("Get = %+v, %v; expected the slot b left open", got, err)
+	}
+
+	late := enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: c.ID})
+	var players, offered []string
+	got, err := st.Queues.Backfill(ctx, game.ID, func(s *store.Session, present, waiting []*store.QueueEntry) []*store.QueueEntry {
+		for _, e := range present {
+			players = append(players, e.ID)
+		}
+		for _, e := range waiting {
+			offered = append(offered, e.ID)
+		}
+		return waiting[:s.OpenSlots]
+	})
+	if err != nil || got == nil || got.ID != s.ID {
+		t.Fatalf("Backfill = %+v, %v; expected session %s", got, err, s.ID)
+	}
+	if !slices.Equal(players, []string{first.ID}) || !slices.Equal(offered, []string{late.ID}) {
+		t.Errorf("Expected pick to get [%s] playing and [%s] waiting, got %v and %v", first.ID, late.ID, players, offered)
+	}
+	if got.OpenSlots != 0 {
+		t.Errorf("Expected the open slot taken, got %d", got.OpenSlots)
+	}
+	want := map[string]string{a.ID: store.ParticipantMatched, b.ID: store.ParticipantLeft, c.ID: store.ParticipantMatched}
+	if got := statuses(t, st, s.ID); !maps.Equal(got, want) {
+		t.Errorf("Expected participants %v, got %v", want, got)
+	}
+
+	// b left for good now that c has the slot
+	if err := st.Sessions.Presence(ctx, s.ID, store.PresenceJoin, []string{b.ID}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict rejoining a full session, got %v", err)
+	}
+	enqueue(t, st, &store.QueueEntry{GameID: game.ID, UserID: b.ID})
+	got, err = st.Queues.Backfill(ctx, game.ID, func(s *store.Session, _, waiting []*store.QueueEntry) []*store.QueueEntry {
+		t.Errorf("Expected no session with open slots, got %+v", s)
+		return waiting
+	})
+	if err != nil || got != nil {
+		t.Errorf("Backfill = %+v, %v; expected none", got, err)
+	}
+}
+
+func testParties(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	leader, friend, other := newUser(t, st), newUser(t, st), newUser(t, st)
+
+	p := &store.Party{LeaderID: leader.ID}
+	if err := st.Parties.Create(ctx, p); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.ID == "" || len(p.Members) != 1 || p.Members[0].User.ID != leader.ID || p.Members[0].Status != store.PartyJoined {
+		t.Errorf("Expected the leader as the only member, got %+v", p)
+	}
+	if err := st.Parties.Create(ctx, &store.Party{LeaderID: leader.ID}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict creating a second party, got %v", err)
+	}
+
+	if err := st.Parties.Invite(ctx, p.ID, friend.ID); err != nil {
+		t.Fatalf("Invite failed: %v", err)
+	}
+	if err := st.Parties.Invite(ctx, p.ID, friend.ID); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict inviting twice, got %v", err)
+	}
+	if err := st.Parties.Invite(ctx, p.ID, unknownID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound inviting an unknown user, got %v", err)
+	}
+	if err := st.Parties.Invite(ctx, unknownID, other.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound inviting to an unknown party, got %v", err)
+	}
+	if invites, err := st.Parties.Invites(ctx, friend.ID); err != nil || len(invites) != 1 || invites[0].ID != p.ID {
+		t.Errorf("Invites = %v, %v; expected party %s", invites, err, p.ID)
+	}
+	if err := st.Parties.Accept(ctx, p.ID, other.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound accepting without an invite, got %v", err)
+	}
+	if err := st.Parties.Accept(ctx, p.ID, friend.ID); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	got, err := st.Parties.ForUser(ctx, friend.ID)
+	if err != nil || got.ID != p.ID || len(got.Joined()) != 2 || got.Members[0].User.ID != leader.ID {
+		t.Fatalf("ForUser = %+v, %v; expected party %s led by %s", got, err, p.ID, leader.ID)
+	}
+
+	entries := []*store.QueueEntry{
+		{GameID: game.ID, UserID: leader.ID, PartyID: p.ID},
+		{GameID: game.ID, UserID: friend.ID, PartyID: p.ID},
+	}
+	if err := st.Queues.EnqueueParty(ctx, entries); err != nil {
+		t.Fatalf("EnqueueParty failed: %v", err)
+	}
+	if e, err := st.Queues.Waiting(ctx, game.ID, friend.ID); err != nil || e.PartyID != p.ID || e.PartySize != 2 {
+		t.Errorf("Waiting = %+v, %v; expected an entry of the party of 2", e, err)
+	}
+
+	// The leader leaving passes the lead on and takes the party out of the queue
+	if ok, err := st.Parties.Leave(ctx, p.ID, leader.ID); err != nil || !ok {
+		t.Fatalf("Leave = %v, %v; expected true", ok, err)
+	}
+	if got, err := st.Parties.Get(ctx, p.ID); err != nil || got.LeaderID != friend.ID {
+		t.Errorf("Get = %+v, %v; expected %s to lead", got, err, friend.ID)
+	}
+	if _, err := st.Queues.Waiting(ctx, game.ID, friend.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the party's entries cancelled, got %v", err)
+	}
+	if ok, err := st.Parties.Leave(ctx, p.ID, leader.ID); err != nil || ok {
+		t.Errorf("Leave = %v, %v; expected false for a former member", ok, err)
+	}
+	if ok, err := st.Parties.Leave(ctx, p.ID, friend.ID); err != nil || !ok {
+		t.Fatalf("Leave = %v, %v; expected true", ok, err)
+	}
+	if _, err := st.Parties.Get(ctx, p.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the empty party deleted, got %v", err)
+	}
+}
+
+func testSessionLifecycle(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := &store.Game{Name: unique("game"), MinPlayers: 1, MaxPlayers: 3}
+	if err := st.Games.Create(ctx, game); err != nil {
+		t.Fatalf("Create game failed: %v", err)
+	}
+	a, b := newUser(t, st), newUser(t, st)
+	s := newSession(t, st, &store.Session{GameID: game.ID}, a, b)
+	if s.Status != store.SessionPending || s.StartedAt != nil || s.OpenSlots != 0 {
+		t.Errorf("Expected a pending session without open slots, got %+v", s)
+	}
+
+	if _, err := st.Sessions.Transition(ctx, s.ID, store.SessionCompleted); !errors.Is(err, store.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition completing a pending session, got %v", err)
+	}
+	if _, err := st.Sessions.Transition(ctx, unknownID, store.SessionActive); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown session, got %v", err)
+	}
+	got, err := st.Sessions.Transition(ctx, s.ID, store.SessionActive)
+	if err != nil || got.Status != store.SessionActive || got.StartedAt == nil || got.OpenSlots != 1 {
+		t.Fatalf("Transition = %+v, %v; expected an active session with a slot open", got, err)
+	}
+
+	for _, step := range []struct {
+		event string
+		users []*store.User
+		want  map[string]string
+		open  int
+	}{
+		{store.PresenceJoin, []*store.User{a}, map[string]string{a.ID: store.ParticipantConnected, b.ID: store.ParticipantMatched}, 1},
+		{store.PresenceHeartbeat, []*store.User{a, b}, map[string]string{a.ID: store.ParticipantConnected, b.ID: store.ParticipantConnected}, 1},
+		{store.PresenceLeave, []*store.User{b}, map[string]string{a.ID: store.ParticipantConnected, b.ID: store.ParticipantLeft}, 2},
+		{store.PresenceHeartbeat, []*store.User{b}, map[string]string{a.ID: store.ParticipantConnected, b.ID: store.ParticipantLeft}, 2},
+		{store.PresenceJoin, []*store.User{b}, map[string]string{a.ID: store.ParticipantConnected, b.ID: store.ParticipantConnected}, 1},
+	} {
+		ids := make([]string, len(step.users))
+		for i, u := range step.users {
+			ids[i] = u.ID
+		}
+		if err := st.Sessions.Presence(ctx, s.ID, step.event, ids); err != nil {
+			t.Fatalf("Presence %s failed: %v", step.event, err)
+		}
+		if got := statuses(t, st, s.ID); !maps.Equal(got, step.want) {
+			t.Errorf("After %s of %v expected %v, got %v", step.event, ids, step.want, got)
+		}
+		if got, err := st.Sessions.Get(ctx, s.ID); err != nil || got.OpenSlots != step.open {
+			t.Errorf("After %s of %v expected %d open slots, got %+v, %v", step.event, ids, step.open, got, err)
+		}
+	}
+
+	result := &store.SessionResult{Players: []store.PlayerResult{{UserID: a.ID, Placement: 1}, {UserID: b.ID, Placement: 2}}}
+	got, err = st.Sessions.Complete(ctx, s.ID, result, nil)
+	if err != nil || got.Status != store.SessionCompleted || got.EndedAt == nil || got.Result == nil || len(got.Result.Players) != 2 {
+		t.Fatalf("Complete = %+v, %v; expected a completed session with its result", got, err)
+	}
+	if _, err := st.Sessions.Complete(ctx, s.ID, result, nil); !errors.Is(err, store.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition completing twice, got %v", err)
+	}
+	if err := st.Sessions.Presence(ctx, s.ID, store.PresenceHeartbeat, []string{a.ID}); !errors.Is(err, store.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition for presence after the end, got %v", err)
+	}
+	want := map[string]string{a.ID: store.ParticipantLeft, b.ID: store.ParticipantLeft}
+	if got := statuses(t, st, s.ID); !maps.Equal(got, want) {
+		t.Errorf("Expected every participant left once the session ended, got %v", got)
+	}
+	if _, err := st.Sessions.Open(ctx, game.ID, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected no open session after the end, got %v", err)
+	}
+}
+
+func testSessionHistory(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game, other := newGame(t, st), newGame(t, st)
+	user := newUser(t, st)
+	var completed []*store.Session
+	for _, g := range []*store.Game{game, other, game} {
+		s := newSession(t, st, &store.Session{GameID: g.ID, Status: store.SessionActive}, user)
+		if _, err := st.Sessions.Complete(ctx, s.ID, &store.SessionResult{}, nil); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		completed = append(completed, s)
+	}
+	newSession(t, st, &store.Session{GameID: game.ID, Status: store.SessionActive}, user)
+
+	ids := func(sessions []*store.Session) []string {
+		out := make([]string, len(sessions))
+		for i, s := range sessions {
+			out[i] = s.ID
+		}
+		return out
+	}
+	first, err := st.Sessions.History(ctx, user.ID, "", store.Page{Limit: 2})
+	if err != nil || !slices.Equal(ids(first), []string{completed[2].ID, completed[1].ID}) {
+		t.Fatalf("History = %v, %v; expected the two latest completed sessions", ids(first), err)
+	}
+	after := &store.Cursor{Time: first[1].CreatedAt, ID: first[1].ID}
+	rest, err := st.Sessions.History(ctx, user.ID, "", store.Page{After: after})
+	if err != nil || !slices.Equal(ids(rest), []string{completed[0].ID}) {
+		t.Errorf("History after %s = %v, %v; expected the first session", first[1].ID, ids(rest), err)
+	}
+	if got, err := st.Sessions.History(ctx, user.ID, game.ID, store.Page{}); err != nil || !slices.Equal(ids(got), []string{completed[2].ID, completed[0].ID}) {
+		t.Errorf("History of %s = %v, %v; expected only its sessions", game.ID, ids(got), err)
+	}
+	if got, err := st.Sessions.History(ctx, newUser(t, st).ID, "", store.Page{}); err != nil || len(got) != 0 {
+		t.Errorf("History = %v, %v; expected none for a user who never played", ids(got), err)
+	}
+}
+
+// testDropStale drops everything seen before an hour from now, so it also
+// drops the players of sessions other tests left open in a shared database
+func testDropStale(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	connected, silent, loading := newUser(t, st), newUser(t, st), newUser(t, st)
+	active := newSession(t, st, &store.Session{GameID: game.ID, Status: store.SessionActive}, connected, silent)
+	pending := newSession(t, st, &store.Session{GameID: game.ID}, loading)
+	if err := st.Sessions.Presence(ctx, active.ID, store.PresenceJoin, []string{connected.ID}); err != nil {
+		t.Fatalf("Presence failed: %v", err)
+	}
+
+	if _, err := st.Sessions.DropStale(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("DropStale failed: %v", err)
+	}
+	want := map[string]string{connected.ID: store.ParticipantConnected, silent.ID: store.ParticipantMatched}
+	if got := statuses(t, st, active.ID); !maps.Equal(got, want) {
+		t.Errorf("Expected players seen within the hour kept, got %v", got)
+	}
+
+	n, err := st.Sessions.DropStale(ctx, time.Now().Add(time.Hour))
+	if err != nil || n < 2 {
+		t.Fatalf("DropStale = %d, %v; expected at least the 2 active players", n, err)
+	}
+	want = map[string]string{connected.ID: store.ParticipantLeft, silent.ID: store.ParticipantLeft}
+	if got := statuses(t, st, active.ID); !maps.Equal(got, want) {
+		t.Errorf("Expected both active players dropped, got %v", got)
+	}
+	if got := statuses(t, st, pending.ID); got[loading.ID] != store.ParticipantMatched {
+		t.Errorf("Expected a player still loading into a pending session kept, got %v", got)
+	}
+	if got, err := st.Sessions.Get(ctx, active.ID); err != nil || got.OpenSlots != game.MaxPlayers {
+		t.Errorf("Get = %+v, %v; expected every slot open again", got, err)
+	}
+}
@@ -0,0 +1,336 @@
+// Package storetest checks that a store implementation behaves as the store
+// interfaces document. The memory and postgres packages run it against their
+// own stores so that both keep the same contract.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/scruffyprodigy/playhub/internal/store"
+)
+
+// unknownID is a well-formed id that names no row
+const unknownID = "00000000-0000-0000-0000-000000000000"
+
+// Run checks st against the store contract. Every row it creates has fresh
+// unique names, so st may be a shared database that already holds data.
+func Run(t *testing.T, st store.Store) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, st) })
+	t.Run("GameSearch", func(t *testing.T) { testGameSearch(t, st) })
+	t.Run("Queues", func(t *testing.T) { testQueues(t, st) })
+	t.Run("Match", func(t *testing.T) { testMatch(t, st) })
+	t.Run("Backfill", func(t *testing.T) { testBackfill(t, st) })
+	t.Run("Parties", func(t *testing.T) { testParties(t, st) })
+	t.Run("SessionLifecycle", func(t *testing.T) { testSessionLifecycle(t, st) })
+	t.Run("SessionHistory", func(t *testing.T) { testSessionHistory(t, st) })
+	t.Run("DropStale", func(t *testing.T) { testDropStale(t, st) })
+	t.Run("Inventory", func(t *testing.T) { testInventory(t, st) })
+	t.Run("MagicLinks", func(t *testing.T) { testMagicLinks(t, st) })
+	t.Run("AuthSessions", func(t *testing.T) { testAuthSessions(t, st) })
+	t.Run("Roles", func(t *testing.T) { testRoles(t, st) })
+	t.Run("APIKeys", func(t *testing.T) { testAPIKeys(t, st) })
+	t.Run("SessionRatings", func(t *testing.T) { testSessionRatings(t, st) })
+}
+
+// unique returns prefix followed by a random suffix
+func unique(prefix string) string {
+	return prefix + "-" + uuid.NewString()[:8]
+}
+
+func newUser(t *testing.T, st store.Store) *store.User {
+	t.Helper()
+	name := unique("user")
+	u := &store.User{Email: name + "@example.com", Username: name, DisplayName: name}
+	if err := st.Users.Create(context.Background(), u); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	return u
+}
+
+func newGame(t *testing.T, st store.Store) *store.Game {
+	t.Helper()
+	g := &store.Game{Name: unique("game")}
+	if err := st.Games.Create(context.Background(), g); err != nil {
+		t.Fatalf("Create game failed: %v", err)
+	}
+	return g
+}
+
+func testUsers(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	if u.ID == "" || u.CreatedAt.IsZero() {
+		t.Errorf("Expected Create to fill in id and creation time, got %+v", u)
+	}
+
+	got, err := st.Users.Get(ctx, u.ID)
+	if err != nil || got.Email != u.Email || got.Username != u.Username {
+		t.Errorf("Get = %+v, %v; expected %+v", got, err, u)
+	}
+	if _, err := st.Users.Get(ctx, unknownID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown user, got %v", err)
+	}
+
+	dup := &store.User{Email: u.Email, Username: unique("other"), DisplayName: "Other"}
+	if err := st.Users.Create(ctx, dup); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict for duplicate email, got %v", err)
+	}
+}
+
+func testQueues(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	user := newUser(t, st)
+
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: user.ID}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := st.Queues.Enqueue(ctx, &store.QueueEntry{GameID: game.ID, UserID: user.ID}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a second entry, got %v", err)
+	}
+	if ok, err := st.Queues.Cancel(ctx, game.ID, user.ID); err != nil || !ok {
+		t.Fatalf("Cancel = %v, %v; expected true", ok, err)
+	}
+	if _, err := st.Queues.Waiting(ctx, game.ID, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected no waiting entry after cancel, got %v", err)
+	}
+}
+
+func testMagicLinks(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	email := unique("magic") + "@example.com"
+	hash := unique("link")
+	if err := st.MagicLinks.Create(ctx, email, hash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	created := 0
+	newUser := func(email string) (*store.User, error) {
+		created++
+		return &store.User{Email: email, Username: unique("magic"), DisplayName: "Magic"}, nil
+	}
+	user, err := st.MagicLinks.Redeem(ctx, hash, newUser)
+	if err != nil {
+		t.Fatalf("Redeem failed: %v", err)
+	}
+	if user.ID == "" || user.Email != email || created != 1 {
+		t.Errorf("Expected a new user for %s, got %+v after %d calls", email, user, created)
+	}
+	if _, err := st.MagicLinks.Redeem(ctx, hash, newUser); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a used link, got %v", err)
+	}
+
+	// A second link for the address resolves to the same account
+	again := unique("link")
+	if err := st.MagicLinks.Create(ctx, email, again, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if u, err := st.MagicLinks.Redeem(ctx, again, newUser); err != nil || u.ID != user.ID {
+		t.Errorf("Redeem = %+v, %v; expected user %s", u, err, user.ID)
+	}
+
+	expired := unique("link")
+	if err := st.MagicLinks.Create(ctx, email, expired, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := st.MagicLinks.Redeem(ctx, expired, newUser); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an expired link, got %v", err)
+	}
+	if n, err := st.MagicLinks.DeleteExpired(ctx, time.Now()); err != nil || n < 1 {
+		t.Errorf("DeleteExpired = %d, %v; expected the expired link to be deleted", n, err)
+	}
+}
+
+func testAuthSessions(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	user := newUser(t, st)
+
+	s := &store.AuthSession{UserID: user.ID, UserAgent: "test-agent", IP: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+	hash := unique("refresh")
+	if err := st.AuthSessions.Create(ctx, s, hash); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if active, err := st.AuthSessions.IsActive(ctx, s.ID); err != nil || !active {
+		t.Errorf("IsActive = %v, %v; expected a new session to be active", active, err)
+	}
+	if err := st.AuthSessions.Create(ctx, &store.AuthSession{UserID: unknownID, ExpiresAt: time.Now().Add(time.Hour)}, unique("refresh")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown user, got %v", err)
+	}
+
+	rotated := unique("refresh")
+	expiresAt := time.Now().Add(2 * time.Hour)
+	got, u, err := st.AuthSessions.Rotate(ctx, hash, rotated, expiresAt)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if got.ID != s.ID || got.UserID != user.ID || got.UserAgent != "test-agent" || got.IP != "127.0.0.1" || u.ID != user.ID {
+		t.Errorf("Rotate = %+v, %+v; expected session %s of user %s", got, u, s.ID, user.ID)
+	}
+	if got.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("Expected expiry %v, got %v", expiresAt, got.ExpiresAt)
+	}
+	if _, _, err := st.AuthSessions.Rotate(ctx, hash, unique("refresh"), expiresAt); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a rotated-out token, got %v", err)
+	}
+
+	if err := st.AuthSessions.RevokeByToken(ctx, rotated); err != nil {
+		t.Fatalf("RevokeByToken failed: %v", err)
+	}
+	if active, err := st.AuthSessions.IsActive(ctx, s.ID); err != nil || active {
+		t.Errorf("IsActive = %v, %v; expected a revoked session to be inactive", active, err)
+	}
+	if _, _, err := st.AuthSessions.Rotate(ctx, rotated, unique("refresh"), expiresAt); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a revoked session, got %v", err)
+	}
+
+	for range 2 {
+		other := &store.AuthSession{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := st.AuthSessions.Create(ctx, other, unique("refresh")); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if n, err := st.AuthSessions.RevokeAllForUser(ctx, user.ID); err != nil || n != 2 {
+		t.Errorf("RevokeAllForUser = %d, %v; expected the 2 live sessions", n, err)
+	}
+	if active, err := st.AuthSessions.IsActive(ctx, unknownID); err != nil || active {
+		t.Errorf("IsActive = %v, %v; expected an unknown session to be inactive", active, err)
+	}
+}
+
+func testRoles(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	user := newUser(t, st)
+
+	for _, role := range []string{"support", "publisher", "support"} {
+		if err := st.Roles.Grant(ctx, user.ID, role, ""); err != nil {
+			t.Fatalf("Grant %s failed: %v", role, err)
+		}
+	}
+	if roles, err := st.Roles.ForUser(ctx, user.ID); err != nil || !slices.Equal(roles, []string{"publisher", "support"}) {
+		t.Errorf("ForUser = %v, %v; expected [publisher support]", roles, err)
+	}
+	if err := st.Roles.Grant(ctx, unknownID, "support", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown user, got %v", err)
+	}
+
+	if err := st.Roles.Revoke(ctx, user.ID, "support"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if roles, err := st.Roles.ForUser(ctx, user.ID); err != nil || !slices.Equal(roles, []string{"publisher"}) {
+		t.Errorf("ForUser = %v, %v; expected [publisher]", roles, err)
+	}
+}
+
+func testAPIKeys(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	user := newUser(t, st)
+
+	first := &store.APIKey{GameID: game.ID, Name: "first", Prefix: "phk_first", Scopes: []string{"goods:write"}}
+	firstHash := unique("key")
+	if err := st.APIKeys.Create(ctx, first, firstHash, user.ID); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if first.ID == "" || first.CreatedAt.IsZero() {
+		t.Errorf("Expected Create to fill in id and creation time, got %+v", first)
+	}
+	second := &store.APIKey{GameID: game.ID, Name: "second", Prefix: "phk_second"}
+	if err := st.APIKeys.Create(ctx, second, unique("key"), ""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := st.APIKeys.Create(ctx, &store.APIKey{GameID: unknownID, Name: "x", Prefix: "phk_x"}, unique("key"), ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown game, got %v", err)
+	}
+
+	if k, err := st.APIKeys.Get(ctx, first.ID); err != nil || k.Name != "first" || !slices.Equal(k.Scopes, first.Scopes) {
+		t.Errorf("Get = %+v, %v; expected %+v", k, err, first)
+	}
+	if _, err := st.APIKeys.Get(ctx, unknownID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown key, got %v", err)
+	}
+
+	k, err := st.APIKeys.Authenticate(ctx, firstHash)
+	if err != nil || k.ID != first.ID || k.LastUsedAt == nil {
+		t.Fatalf("Authenticate = %+v, %v; expected %s with its use recorded", k, err, first.ID)
+	}
+	// Uses within a minute of the recorded one are not written
+	if again, err := st.APIKeys.Authenticate(ctx, firstHash); err != nil || again.LastUsedAt == nil || !again.LastUsedAt.Equal(*k.LastUsedAt) {
+		t.Errorf("Authenticate = %+v, %v; expected the use at %v kept", again, err, k.LastUsedAt)
+	}
+
+	if err := st.APIKeys.Revoke(ctx, first.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := st.APIKeys.Revoke(ctx, first.ID); err != nil {
+		t.Errorf("Expected revoking twice to succeed, got %v", err)
+	}
+	if err := st.APIKeys.Revoke(ctx, unknownID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound revoking an unknown key, got %v", err)
+	}
+	if _, err := st.APIKeys.Authenticate(ctx, firstHash); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a revoked key, got %v", err)
+	}
+
+	keys, err := st.APIKeys.List(ctx, game.ID)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("List = %v, %v; expected both keys", keys, err)
+	}
+	if keys[0].ID != second.ID || keys[1].ID != first.ID || keys[1].RevokedAt == nil {
+		t.Errorf("Expected newest key first and the revoked one included, got %+v, %+v", keys[0], keys[1])
+	}
+}
+
+func testSessionRatings(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	game := newGame(t, st)
+	winner, loser := newUser(t, st), newUser(t, st)
+	start := func() *store.Session {
+		t.Helper()
+		s := &store.Session{GameID: game.ID}
+		if err := st.Sessions.Create(ctx, s, []string{winner.ID, loser.ID}); err != nil {
+			t.Fatalf("Create session failed: %v", err)
+		}
+		if _, err := st.Sessions.Transition(ctx, s.ID, store.SessionActive); err != nil {
+			t.Fatalf("Transition failed: %v", err)
+		}
+		return s
+	}
+	// rate gives every placed player the value 1 more than their placement
+	rate := func(players ...string) store.RatingUpdate {
+		return func(map[string]*store.Rating) []*store.Rating {
+			out := make([]*store.Rating, len(players))
+			for i, id := range players {
+				out[i] = &store.Rating{UserID: id, Value: float64(i + 2), Deviation: 1, Volatility: 1, GamesPlayed: 1}
+			}
+			return out
+		}
+	}
+	result := &store.SessionResult{Players: []store.PlayerResult{
+		{UserID: winner.ID, Placement: 1}, {UserID: loser.ID, Placement: 2},
+	}}
+
+	s := start()
+	if _, err := st.Sessions.Complete(ctx, s.ID, result, rate(winner.ID, loser.ID)); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if r, err := st.Ratings.Get(ctx, loser.ID, game.ID); err != nil || r.Value != 3 {
+		t.Errorf("Ratings.Get = %+v, %v; expected the rating saved with the result", r, err)
+	}
+
+	// A rating that cannot be saved leaves the session active
+	s = start()
+	if _, err := st.Sessions.Complete(ctx, s.ID, result, rate(winner.ID, unknownID)); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound rating an unknown user, got %v", err)
+	}
+	if got, err := st.Sessions.Get(ctx, s.ID); err != nil || got.Status != store.SessionActive || got.Result != nil {
+		t.Errorf("Get = %+v, %v; expected the session left active without a result", got, err)
+	}
+	if r, err := st.Ratings.Get(ctx, winner.ID, game.ID); err != nil || r.Value != 2 {
+		t.Errorf("Ratings.Get = %+v, %v; expected the earlier rating kept", r, err)
+	}
+}
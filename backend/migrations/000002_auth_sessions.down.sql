@@ -0,0 +1,3 @@
+-- Rollback for auth sessions migration
+
+DROP TABLE IF EXISTS auth_sessions;
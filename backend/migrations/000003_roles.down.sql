@@ -0,0 +1,9 @@
+-- Rollback for roles migration
+
+DROP INDEX IF EXISTS idx_digital_goods_game_code;
+ALTER TABLE digital_goods DROP COLUMN IF EXISTS code;
+
+DROP INDEX IF EXISTS idx_games_owner_id;
+ALTER TABLE games DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS user_roles;
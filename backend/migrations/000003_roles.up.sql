@@ -0,0 +1,18 @@
+-- Role-based authorization
+-- Every signed-in user is implicitly a player; elevated roles are granted here
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('player', 'publisher', 'support', 'admin')),
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    granted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+-- Publishers own the games they manage
+ALTER TABLE games ADD COLUMN owner_id UUID REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX idx_games_owner_id ON games(owner_id);
+
+-- Stable code for 3P references to goods
+ALTER TABLE digital_goods ADD COLUMN code VARCHAR(64);
+CREATE UNIQUE INDEX idx_digital_goods_game_code ON digital_goods(game_id, code);
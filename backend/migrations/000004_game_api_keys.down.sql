@@ -0,0 +1,3 @@
+-- Rollback for game API keys migration
+
+DROP TABLE IF EXISTS game_api_keys;
@@ -0,0 +1,3 @@
+-- Rollback for rate limits migration
+
+DROP TABLE IF EXISTS rate_limit_hits;
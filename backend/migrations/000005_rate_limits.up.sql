@@ -0,0 +1,9 @@
+-- Sliding-window rate limit log shared by all backend replicas
+
+CREATE TABLE rate_limit_hits (
+    key VARCHAR(320) NOT NULL,
+    hit_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_hits_key_hit_at ON rate_limit_hits(key, hit_at);
+CREATE INDEX idx_rate_limit_hits_hit_at ON rate_limit_hits(hit_at);
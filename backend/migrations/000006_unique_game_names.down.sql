@@ -0,0 +1,3 @@
+-- Rollback for unique game names migration
+
+DROP INDEX IF EXISTS idx_games_name_lower;
@@ -0,0 +1,12 @@
+-- Game names are unique regardless of case so players can tell games apart.
+-- Earlier duplicates keep the oldest game's name; the others get the start
+-- of their id appended, trimmed to fit the column.
+
+UPDATE games g SET name = LEFT(g.name, 89) || ' (' || LEFT(g.id::text, 8) || ')', updated_at = NOW()
+WHERE EXISTS (
+    SELECT 1 FROM games o
+    WHERE LOWER(o.name) = LOWER(g.name)
+      AND (COALESCE(o.created_at, '-infinity'), o.id) < (COALESCE(g.created_at, '-infinity'), g.id)
+);
+
+CREATE UNIQUE INDEX idx_games_name_lower ON games(LOWER(name));
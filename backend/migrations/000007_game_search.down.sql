@@ -0,0 +1,6 @@
+-- Rollback for game search migration
+
+DROP INDEX IF EXISTS idx_game_sessions_game_started;
+DROP INDEX IF EXISTS idx_games_name_prefix;
+DROP INDEX IF EXISTS idx_games_search_vector;
+ALTER TABLE games DROP COLUMN IF EXISTS search_vector;
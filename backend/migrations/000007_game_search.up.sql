@@ -0,0 +1,14 @@
+-- Game catalog search
+-- search_vector holds the words of name, description and category for full-text
+-- matching; the 'simple' configuration keeps words unstemmed so prefixes match
+
+ALTER TABLE games ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(description, '') || ' ' || coalesce(category, ''))
+    ) STORED;
+
+CREATE INDEX idx_games_search_vector ON games USING GIN (search_vector);
+CREATE INDEX idx_games_name_prefix ON games (LOWER(name) text_pattern_ops);
+
+-- Popularity counts recent sessions per game
+CREATE INDEX idx_game_sessions_game_started ON game_sessions(game_id, started_at);
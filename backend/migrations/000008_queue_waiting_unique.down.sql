@@ -0,0 +1,4 @@
+-- Rollback for unique waiting queue entries migration
+
+DROP INDEX IF EXISTS idx_game_queues_waiting_joined;
+DROP INDEX IF EXISTS idx_game_queues_waiting_user;
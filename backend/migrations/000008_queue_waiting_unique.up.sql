@@ -0,0 +1,13 @@
+-- A user waits at most once per game. Earlier duplicates keep the oldest entry.
+
+UPDATE game_queues q SET status = 'cancelled'
+WHERE status = 'waiting' AND EXISTS (
+    SELECT 1 FROM game_queues o
+    WHERE o.game_id = q.game_id AND o.user_id = q.user_id AND o.status = 'waiting'
+      AND (o.joined_at, o.id) < (q.joined_at, q.id)
+);
+
+CREATE UNIQUE INDEX idx_game_queues_waiting_user ON game_queues(game_id, user_id) WHERE status = 'waiting';
+
+-- Queue order for matching and positions
+CREATE INDEX idx_game_queues_waiting_joined ON game_queues(game_id, joined_at, id) WHERE status = 'waiting';
@@ -0,0 +1,3 @@
+-- Rollback for queue sessions migration
+
+ALTER TABLE game_queues DROP COLUMN IF EXISTS session_id;
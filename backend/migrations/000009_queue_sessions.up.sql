@@ -0,0 +1,3 @@
+-- Links matched queue entries to the session the matchmaker created for them
+
+ALTER TABLE game_queues ADD COLUMN session_id UUID REFERENCES game_sessions(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+-- Rollback for queue priority migration
+
+DROP INDEX IF EXISTS idx_game_queues_waiting_order;
+CREATE INDEX idx_game_queues_waiting_joined ON game_queues(game_id, joined_at, id) WHERE status = 'waiting';
+
+ALTER TABLE game_queues ALTER COLUMN priority DROP NOT NULL;
@@ -0,0 +1,7 @@
+-- Matching reads queue priority, so it always has a value and leads the queue order
+
+UPDATE game_queues SET priority = 0 WHERE priority IS NULL;
+ALTER TABLE game_queues ALTER COLUMN priority SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_game_queues_waiting_joined;
+CREATE INDEX idx_game_queues_waiting_order ON game_queues(game_id, priority DESC, joined_at, id) WHERE status = 'waiting';
@@ -0,0 +1,4 @@
+-- Rollback for queue expiry migration
+
+DROP INDEX IF EXISTS idx_game_queues_waiting_expires;
+ALTER TABLE games DROP COLUMN IF EXISTS max_queue_wait_seconds;
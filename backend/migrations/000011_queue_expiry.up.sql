@@ -0,0 +1,10 @@
+-- Queue entries expire after the game's maximum wait, or the server default
+-- when the game has none
+
+ALTER TABLE games ADD COLUMN max_queue_wait_seconds INTEGER CHECK (max_queue_wait_seconds > 0);
+
+-- Entries queued before expiry existed get the default wait of 10 minutes
+UPDATE game_queues SET expires_at = joined_at + INTERVAL '10 minutes'
+WHERE status = 'waiting' AND expires_at IS NULL;
+
+CREATE INDEX idx_game_queues_waiting_expires ON game_queues(expires_at) WHERE status = 'waiting';
@@ -0,0 +1,8 @@
+-- Rollback for parties migration
+
+DROP INDEX IF EXISTS idx_game_queues_party_id;
+ALTER TABLE game_queues DROP COLUMN IF EXISTS party_size;
+ALTER TABLE game_queues DROP COLUMN IF EXISTS party_id;
+
+DROP TABLE IF EXISTS party_members;
+DROP TABLE IF EXISTS parties;
@@ -0,0 +1,25 @@
+-- Parties let friends queue together as one unit
+
+CREATE TABLE parties (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    leader_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE party_members (
+    party_id UUID NOT NULL REFERENCES parties(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'joined')),
+    invited_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    joined_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (party_id, user_id)
+);
+
+-- A user belongs to at most one party
+CREATE UNIQUE INDEX idx_party_members_joined_user ON party_members(user_id) WHERE status = 'joined';
+CREATE INDEX idx_party_members_user_id ON party_members(user_id);
+
+-- Queue entries of a party's members share the party and its size
+ALTER TABLE game_queues ADD COLUMN party_id UUID REFERENCES parties(id) ON DELETE SET NULL;
+ALTER TABLE game_queues ADD COLUMN party_size INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX idx_game_queues_party_id ON game_queues(party_id) WHERE status = 'waiting';
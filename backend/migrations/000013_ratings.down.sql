@@ -0,0 +1,3 @@
+-- Rollback for ratings migration
+
+DROP TABLE IF EXISTS user_ratings;
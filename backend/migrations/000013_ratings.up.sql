@@ -0,0 +1,14 @@
+-- Per-game Glicko-2 skill ratings, updated from session results
+
+CREATE TABLE user_ratings (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    rating DOUBLE PRECISION NOT NULL DEFAULT 1500,
+    deviation DOUBLE PRECISION NOT NULL DEFAULT 350 CHECK (deviation > 0),
+    volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06 CHECK (volatility > 0),
+    games_played INTEGER NOT NULL DEFAULT 0 CHECK (games_played >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, game_id)
+);
+
+CREATE INDEX idx_user_ratings_game_id ON user_ratings(game_id);
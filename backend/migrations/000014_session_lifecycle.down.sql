@@ -0,0 +1,16 @@
+-- Rollback for session lifecycle migration
+
+DROP INDEX IF EXISTS idx_game_sessions_game_status_created;
+DROP INDEX IF EXISTS idx_game_sessions_game_created;
+CREATE INDEX IF NOT EXISTS idx_game_sessions_game_started ON game_sessions(game_id, started_at);
+
+ALTER TABLE game_sessions DROP CONSTRAINT IF EXISTS game_sessions_lifecycle_check;
+ALTER TABLE game_sessions DROP CONSTRAINT IF EXISTS game_sessions_status_check;
+UPDATE game_sessions SET status = 'cancelled' WHERE status = 'pending';
+UPDATE game_sessions SET started_at = created_at WHERE started_at IS NULL;
+ALTER TABLE game_sessions ALTER COLUMN started_at SET DEFAULT NOW();
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS created_at;
+ALTER TABLE game_sessions ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE game_sessions ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE game_sessions ADD CONSTRAINT game_sessions_status_check
+    CHECK (status IN ('active', 'completed', 'cancelled'));
@@ -0,0 +1,31 @@
+-- Sessions are created pending, go active when their game server starts them
+-- and end completed or cancelled
+
+ALTER TABLE game_sessions DROP CONSTRAINT IF EXISTS game_sessions_status_check;
+UPDATE game_sessions SET status = 'active' WHERE status IS NULL;
+ALTER TABLE game_sessions ALTER COLUMN status SET NOT NULL;
+ALTER TABLE game_sessions ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE game_sessions ADD CONSTRAINT game_sessions_status_check
+    CHECK (status IN ('pending', 'active', 'completed', 'cancelled'));
+
+-- created_at records when the session was formed; started_at is only set
+-- once it goes active
+ALTER TABLE game_sessions ADD COLUMN created_at TIMESTAMP WITH TIME ZONE;
+UPDATE game_sessions SET created_at = COALESCE(started_at, NOW());
+ALTER TABLE game_sessions ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE game_sessions ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE game_sessions ALTER COLUMN started_at DROP DEFAULT;
+
+UPDATE game_sessions SET started_at = created_at WHERE status = 'active' AND started_at IS NULL;
+UPDATE game_sessions SET ended_at = COALESCE(started_at, created_at)
+WHERE status IN ('completed', 'cancelled') AND ended_at IS NULL;
+ALTER TABLE game_sessions ADD CONSTRAINT game_sessions_lifecycle_check CHECK (
+    (status = 'pending' AND started_at IS NULL AND ended_at IS NULL) OR
+    (status = 'active' AND started_at IS NOT NULL AND ended_at IS NULL) OR
+    (status IN ('completed', 'cancelled') AND ended_at IS NOT NULL)
+);
+
+-- Sessions are listed and counted for popularity by creation time
+DROP INDEX IF EXISTS idx_game_sessions_game_started;
+CREATE INDEX idx_game_sessions_game_created ON game_sessions(game_id, created_at);
+CREATE INDEX idx_game_sessions_game_status_created ON game_sessions(game_id, status, created_at DESC, id DESC);
@@ -0,0 +1,4 @@
+-- Rollback for game join URLs migration
+
+DROP INDEX IF EXISTS idx_game_session_participants_user_id;
+ALTER TABLE games DROP COLUMN IF EXISTS join_url_template;
@@ -0,0 +1,7 @@
+-- Where players take the join ticket of a matched session, e.g.
+-- https://play.example.com/join?ticket={ticket}
+
+ALTER TABLE games ADD COLUMN join_url_template VARCHAR(500);
+
+-- Finds a player's open sessions
+CREATE INDEX idx_game_session_participants_user_id ON game_session_participants(user_id);
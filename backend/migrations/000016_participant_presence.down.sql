@@ -0,0 +1,4 @@
+-- Rollback for participant presence migration
+
+DROP INDEX IF EXISTS idx_game_session_participants_last_seen;
+ALTER TABLE game_session_participants DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,13 @@
+-- Game servers report when players connect and send heartbeats while they
+-- stay; last_seen_at holds the latest and stays NULL until the first
+
+ALTER TABLE game_session_participants ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE;
+
+-- Players of ended sessions have left
+UPDATE game_session_participants p SET left_at = s.ended_at
+FROM game_sessions s
+WHERE p.session_id = s.id AND s.ended_at IS NOT NULL AND p.left_at IS NULL;
+
+-- Finds connected players who stopped sending heartbeats
+CREATE INDEX idx_game_session_participants_last_seen ON game_session_participants(last_seen_at)
+    WHERE left_at IS NULL;
@@ -0,0 +1,5 @@
+-- Rollback for session backfill migration
+
+DROP INDEX IF EXISTS idx_game_sessions_backfill;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS open_slots;
+ALTER TABLE games DROP COLUMN IF EXISTS backfill;
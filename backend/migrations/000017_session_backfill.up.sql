@@ -0,0 +1,14 @@
+-- Games can let the matchmaker fill the places players leave in active
+-- sessions; open_slots counts those places and stays 0 unless active
+
+ALTER TABLE games ADD COLUMN backfill BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE game_sessions ADD COLUMN open_slots INTEGER NOT NULL DEFAULT 0 CHECK (open_slots >= 0);
+
+UPDATE game_sessions s SET open_slots = GREATEST(
+    (SELECT COALESCE(max_players, 4) FROM games WHERE games.id = s.game_id) -
+    (SELECT count(*) FROM game_session_participants p WHERE p.session_id = s.id AND p.left_at IS NULL), 0)
+WHERE s.status = 'active';
+
+-- Finds the sessions the matchmaker can backfill
+CREATE INDEX idx_game_sessions_backfill ON game_sessions(game_id, created_at)
+    WHERE status = 'active' AND open_slots > 0;
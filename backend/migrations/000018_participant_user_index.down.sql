@@ -0,0 +1,4 @@
+-- Rollback for participant user index migration. The index belongs to
+-- 000015, which drops it on its own rollback.
+
+SELECT 1;
@@ -0,0 +1,5 @@
+-- 000015 indexes session participants by user to find a player's open
+-- sessions. Databases that ran a revision of 000015 without the index get
+-- it here; everywhere else this does nothing.
+
+CREATE INDEX IF NOT EXISTS idx_game_session_participants_user_id ON game_session_participants(user_id);
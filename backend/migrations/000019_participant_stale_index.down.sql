@@ -0,0 +1,6 @@
+-- Rollback for participant stale index migration
+
+DROP INDEX IF EXISTS idx_game_session_participants_stale;
+
+CREATE INDEX idx_game_session_participants_last_seen ON game_session_participants(last_seen_at)
+    WHERE left_at IS NULL;
@@ -0,0 +1,8 @@
+-- Players the game server never reported are stale once they have been
+-- placed for longer than the heartbeat timeout, so stale players are found
+-- by their last heartbeat or else their joining time
+
+DROP INDEX IF EXISTS idx_game_session_participants_last_seen;
+
+CREATE INDEX idx_game_session_participants_stale ON game_session_participants((COALESCE(last_seen_at, joined_at)))
+    WHERE left_at IS NULL;
@@ -1,63 +1,94 @@
 package main
 
 import (
-	"crypto/ed25519"
-	"crypto/rand"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/scruffyprodigy/playhub/internal/auth"
 )
 
 const yamlFormat = `apiVersion: v1
 kind: Secret
 metadata:
-	name: %s
-	namespace: %s
+  name: %s
+  namespace: %s
 type: Opaque
 stringData:
-	KID: %s
-	PUB_X: %s
-	PRIV_PEM: |
+  KID: %s
+  PUB_X: %s
+  PRIV_PEM: |
+%s
+  keyset.json: |
 %s`
 
 func main() {
 	// Flags
+	mode := flag.String("mode", "generate", "generate: new key set with one active key; rotate: add a key to an existing set")
 	name := flag.String("name", "jwks", "Kubernetes Secret name")
 	ns := flag.String("namespace", "lobby", "Kubernetes namespace")
-	kid := flag.String("kid", "lobby-dev", "JWKS key id (kid)")
+	kid := flag.String("kid", "lobby-dev", "JWKS key id (kid) for the new key")
+	in := flag.String("in", "", "Existing keyset.json to rotate (required for -mode=rotate)")
+	grace := flag.Duration("grace", auth.DefaultRetiredGrace, "How long retired keys keep verifying before they are pruned")
+	keysetOut := flag.String("keysetout", "", "Write keyset.json to file (default: only embedded in the Secret)")
 	yamlOut := flag.String("yamlout", "", "Write Secret YAML to file (default stdout)")
 	jwksOut := flag.String("jwksout", "", "Write Public JSON to file (default stdout)")
 	flag.Parse()
 
-	pubX, privPEM, err := genKeyPair()
+	// A fixed default kid would collide with the key being rotated out
+	kidSet := false
+	flag.Visit(func(f *flag.Flag) { kidSet = kidSet || f.Name == "kid" })
+	if *mode == "rotate" && !kidSet {
+		*kid = "lobby-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	var (
+		keys *auth.KeySet
+		err  error
+	)
+	switch *mode {
+	case "generate":
+		keys, err = generate(*kid, *grace)
+	case "rotate":
+		keys, err = rotate(*in, *kid, *grace)
+	default:
+		err = fmt.Errorf("unknown mode %q (use generate or rotate)", *mode)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	keysetJSON, err := keys.MarshalJSON()
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal error: %v\n", err)
 		os.Exit(1)
 	}
+	if *keysetOut != "" {
+		if err := writeToFile(keysetOut, string(keysetJSON)+"\n"); err != nil {
+			os.Exit(1)
+		}
+	}
 
-	// Build Secret YAML (uses stringData so you can read it in env vars)
-	yaml := fmt.Sprintf(yamlFormat, *name, *ns, *kid, pubX, indent(privPEM, "    "))
+	// Build Secret YAML (uses stringData so you can read it in env vars).
+	// KID, PUB_X and PRIV_PEM mirror the active key for single-key consumers.
+	active := keys.Active()
+	pubX, privPEM, err := encodeKey(active)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal error: %v\n", err)
+		os.Exit(1)
+	}
+	yaml := fmt.Sprintf(yamlFormat, *name, *ns, active.KID, pubX, indent(privPEM, "    "), indent(string(keysetJSON)+"\n", "    "))
 	err = writeToFile(yamlOut, yaml)
 	if err != nil {
 		os.Exit(1)
 	}
 
 	jwks, _ := json.MarshalIndent(map[string]any{
-		"keys": []map[string]string{
-			{
-				"kty": "OKP",
-				"crv": "Ed25519",
-				"use": "sig",
-				"alg": "EdDSA",
-				"kid": *kid,
-				"x":   pubX,
-			},
-		},
+		"keys": keys.JWKS(),
 		// Not part of spec, but handy for humans:
 		"_generated": time.Now().UTC().Format(time.RFC3339),
 	}, "", "  ")
@@ -67,36 +98,52 @@ func main() {
 	}
 
 	// Helpful reminder
-	fmt.Fprintln(os.Stderr, "\nNOTE: Keep PRIV_PEM secret. PUB_X and KID are safe to expose in JWKS.")
+	fmt.Fprintln(os.Stderr, "\nNOTE: Keep PRIV_PEM and keyset.json secret. PUB_X and KID are safe to expose in JWKS.")
+	if *mode == "rotate" {
+		fmt.Fprintf(os.Stderr, "Active key: %s. Run rotate again once JWKS caches have picked up %s to promote it.\n", active.KID, *kid)
+	}
 }
 
-func b64url(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
-
-func genKeyPair() (string, string, error) {
-		// Generate Ed25519 keypair
-		pub, priv, err := ed25519.GenerateKey(rand.Reader)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "keygen error: %v\n", err)
-			return "", "", err
-		}
-
-		// Public key (x) for JWKS
-		pubX := b64url(pub)
+func generate(kid string, grace time.Duration) (*auth.KeySet, error) {
+	key, err := auth.GenerateKey(kid, auth.KeyActive)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewKeySet([]*auth.Key{key}, grace)
+}
 
-		// Private key (PKCS#8) in PEM for signing
-		privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "marshal error: %v\n", err)
-			return "", "", err
+func rotate(in, kid string, grace time.Duration) (*auth.KeySet, error) {
+	if in == "" {
+		return nil, fmt.Errorf("-in is required for rotate; export it with: kubectl get secret jwks -o jsonpath='{.data.keyset\\.json}' | base64 -d > keyset.json")
+	}
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", in, err)
+	}
+	keys, err := auth.ParseKeySet(data, grace)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", in, err)
+	}
+	for _, k := range keys.Keys() {
+		if k.KID == kid {
+			return nil, fmt.Errorf("kid %q already exists in %s", kid, in)
 		}
-		privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
+	}
+	return keys.Rotate(kid)
+}
 
-		// Make sure PEM ends with newline
-		if !strings.HasSuffix(string(privPEM), "\n") {
-			privPEM = append(privPEM, '\n')
-		}
+// encodeKey returns the JWKS x value and the PKCS#8 PEM private key for k
+func encodeKey(k *auth.Key) (string, string, error) {
+	privPEM, err := k.PrivatePEM()
+	if err != nil {
+		return "", "", err
+	}
 
-		return pubX, string(privPEM), nil
+	// Make sure PEM ends with newline
+	if !strings.HasSuffix(privPEM, "\n") {
+		privPEM += "\n"
+	}
+	return k.PublicX(), privPEM, nil
 }
 
 func writeToFile(out *string, data string) error {
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -12,24 +16,154 @@ import (
 	"github.com/scruffyprodigy/playhub/database"
 	"github.com/scruffyprodigy/playhub/graph"
 	"github.com/scruffyprodigy/playhub/graph/generated"
+	"github.com/scruffyprodigy/playhub/internal/auth"
+	"github.com/scruffyprodigy/playhub/internal/jobs"
+	"github.com/scruffyprodigy/playhub/internal/mail"
+	"github.com/scruffyprodigy/playhub/internal/matchmaker"
+	"github.com/scruffyprodigy/playhub/internal/notify"
+	"github.com/scruffyprodigy/playhub/internal/ratelimit"
+	"github.com/scruffyprodigy/playhub/internal/store/memory"
+	"github.com/scruffyprodigy/playhub/internal/store/postgres"
 )
 
 func main() {
+	mailer, err := mail.New(mail.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	templates, err := mail.TemplatesFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load mail templates: %v", err)
+	}
+
+	queueMaxWait, err := queueMaxWait()
+	if err != nil {
+		log.Fatalf("Failed to configure queues: %v", err)
+	}
+	refreshTTL, err := refreshTokenTTL()
+	if err != nil {
+		log.Fatalf("Failed to configure sessions: %v", err)
+	}
+	cleanupEvery, err := cleanupInterval()
+	if err != nil {
+		log.Fatalf("Failed to configure cleanup: %v", err)
+	}
+	resolver := &graph.Resolver{Mailer: mailer, MailTemplates: templates, Events: notify.NewHub(), QueueMaxWait: queueMaxWait}
+
 	// Initialize database connection with migrations
-	if err := database.InitWithMigrations(); err != nil {
+	db, err := database.OpenWithMigrations()
+	if err != nil {
 		log.Printf("Warning: Database connection or migrations failed: %v", err)
-		log.Println("Continuing with mock data...")
+		log.Println("Continuing with an in-memory store...")
+		resolver.Store = memory.New()
 	} else {
-		defer database.Close()
+		defer db.Close()
+		resolver.Store = postgres.New(db)
+	}
+	resolver.MagicLinks = auth.NewMagicLinks(resolver.Store.MagicLinks, auth.DefaultMagicLinkTTL, magicLinkBaseURL())
+	resolver.AuthSessions = auth.NewSessions(resolver.Store.AuthSessions, refreshTTL)
+	resolver.Roles = auth.NewRoleStore(resolver.Store.Roles)
+	resolver.GameKeys = auth.NewAPIKeys(resolver.Store.APIKeys)
+
+	limits, err := newRateLimitStore(db)
+	if err != nil {
+		log.Fatalf("Failed to configure rate limits: %v", err)
+	}
+	ipLimit, err := ratelimit.ConfigFromEnv("LOGIN_RATE_IP", ratelimit.Config{Limit: 20, Window: time.Hour})
+	if err != nil {
+		log.Fatalf("Failed to configure rate limits: %v", err)
+	}
+	emailLimit, err := ratelimit.ConfigFromEnv("LOGIN_RATE_EMAIL", ratelimit.Config{Limit: 5, Window: time.Hour})
+	if err != nil {
+		log.Fatalf("Failed to configure rate limits: %v", err)
+	}
+	resolver.LoginIPLimiter = ratelimit.New(limits, "login-ip", ipLimit.Limit, ipLimit.Window)
+	resolver.LoginEmailLimiter = ratelimit.New(limits, "login-email", emailLimit.Limit, emailLimit.Window)
+
+	// Expired magic links and rate limit hits are only kept as long as needed
+	maxWindow := max(ipLimit.Window, emailLimit.Window)
+	go jobs.Every(context.Background(), "cleanup", cleanupEvery, func(ctx context.Context) error {
+		n, err := resolver.MagicLinks.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("cleanup: deleted %d expired magic links", n)
+		}
+		_, err = limits.Prune(ctx, time.Now().Add(-maxWindow))
+		return err
+	})
+
+	// Events reach subscribers on every replica through the database
+	var events notify.Publisher = resolver.Events
+	if db != nil {
+		events = notify.NewPostgresPublisher(db)
+		go func() {
+			if err := notify.Listen(context.Background(), os.Getenv("DATABASE_URL"), resolver.Events); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+
+	// MATCHMAKER=off leaves matching, queue expiry and presence sweeps to
+	// cmd/matchmaker
+	if os.Getenv("MATCHMAKER") != "off" {
+		interval, err := matchmaker.IntervalFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to configure matchmaker: %v", err)
+		}
+		sweepInterval, err := matchmaker.SweepIntervalFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to configure matchmaker: %v", err)
+		}
+		heartbeatTimeout, err := matchmaker.HeartbeatTimeoutFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to configure matchmaker: %v", err)
+		}
+		m := matchmaker.New(resolver.Store, events)
+		go jobs.Every(context.Background(), "matchmaker", interval, func(ctx context.Context) error {
+			_, err := m.Run(ctx)
+			return err
+		})
+		go jobs.Every(context.Background(), "queue sweep", sweepInterval, func(ctx context.Context) error {
+			_, err := m.Sweep(ctx)
+			return err
+		})
+		go jobs.Every(context.Background(), "presence sweep", sweepInterval, func(ctx context.Context) error {
+			_, err := m.SweepPresence(ctx, heartbeatTimeout)
+			return err
+		})
+	}
+
+	tokenConfig, err := auth.TokenConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure tokens: %v", err)
+	}
+	keys, err := auth.KeySetFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	var verifier *auth.Verifier
+	if keys != nil {
+		active := keys.Active()
+		resolver.Signer = auth.NewSigner(active.KID, active.Private, tokenConfig)
+		verifier = auth.NewVerifier(keys, tokenConfig.Issuer, tokenConfig.Audience)
+	} else {
+		log.Println("Warning: no signing keys configured, magic link logins cannot be completed and bearer tokens will be rejected")
 	}
 
 	mux := http.NewServeMux()
 
-	gql := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
-	mux.Handle("/graphql", withAuth(gql))
+	gql := handler.NewDefaultServer(generated.NewExecutableSchema(graph.NewConfig(resolver)))
+	proxies, err := auth.TrustedProxiesFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure proxies: %v", err)
+	}
+	mux.Handle("/graphql", auth.TrustProxies(proxies, withAuth(verifier, resolver.AuthSessions, resolver.GameKeys, gql)))
 	mux.Handle("/", playground.Handler("GraphQL", "/graphql"))
 
-	mux.HandleFunc("/.well-known/jwks.json", jwksHandler)
+	mux.HandleFunc("/.well-known/jwks.json", jwksHandler(keys))
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
 
 	srv := &http.Server{Addr: ":8080", Handler: mux, ReadHeaderTimeout: 5 * time.Second}
@@ -37,17 +171,97 @@ func main() {
 	log.Fatal(srv.ListenAndServe())
 }
 
-func withAuth(next http.Handler) http.Handler {
-	// TODO: read JWT from cookie, verify Ed25519 w/ env JWKS_PUB_X, set user in context
-	return next
+// magicLinkBaseURL returns the frontend page that completes a magic link login
+func magicLinkBaseURL() string {
+	if u := os.Getenv("MAGIC_LINK_BASE_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:5173/auth/magic"
+}
+
+// pruningStore is a rate limit store whose old hits can be deleted
+type pruningStore interface {
+	ratelimit.Store
+	Prune(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// newRateLimitStore selects the rate limit store from RATE_LIMIT_STORE
+// (memory or postgres). It defaults to postgres when a database is available.
+func newRateLimitStore(db *sql.DB) (pruningStore, error) {
+	kind := os.Getenv("RATE_LIMIT_STORE")
+	if kind == "" {
+		kind = "memory"
+		if db != nil {
+			kind = "postgres"
+		}
+	}
+	switch kind {
+	case "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "postgres":
+		if db == nil {
+			return nil, errors.New("RATE_LIMIT_STORE=postgres requires a database")
+		}
+		return ratelimit.NewPostgresStore(db), nil
+	}
+	return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", kind)
+}
+
+// cleanupInterval returns how often expired rows are deleted, from CLEANUP_INTERVAL
+func cleanupInterval() (time.Duration, error) {
+	if v := os.Getenv("CLEANUP_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid CLEANUP_INTERVAL %q", v)
+		}
+		return d, nil
+	}
+	return time.Hour, nil
+}
+
+// queueMaxWait returns how long players wait in the queue of games without
+// their own limit, from QUEUE_MAX_WAIT
+func queueMaxWait() (time.Duration, error) {
+	if v := os.Getenv("QUEUE_MAX_WAIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid QUEUE_MAX_WAIT %q", v)
+		}
+		return d, nil
+	}
+	return graph.DefaultQueueMaxWait, nil
+}
+
+// withAuth verifies the caller's EdDSA access token, taken from the
+// Authorization header or the access cookie, or a game server's API key,
+// rejects tokens of revoked login sessions and stores the principal in the
+// request context
+func withAuth(v *auth.Verifier, sessions auth.SessionValidator, keys auth.APIKeyAuthenticator, next http.Handler) http.Handler {
+	return auth.Middleware(v, sessions, keys, next)
+}
+
+// refreshTokenTTL returns the refresh token lifetime from JWT_REFRESH_TTL
+func refreshTokenTTL() (time.Duration, error) {
+	if v := os.Getenv("JWT_REFRESH_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return 0, fmt.Errorf("invalid JWT_REFRESH_TTL %q", v)
+		}
+		return ttl, nil
+	}
+	return auth.DefaultRefreshTokenTTL, nil
 }
 
-func jwksHandler(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	jwk := map[string]string{
-		"kty": "OKP", "crv": "Ed25519", "alg": "EdDSA",
-		"kid": os.Getenv("JWKS_KID"),
-		"x":   os.Getenv("JWKS_PUB_X"),
+// jwksHandler publishes every key that can still verify tokens, including the
+// next key ahead of rotation and retired keys within their grace period
+func jwksHandler(keys *auth.KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		jwks := []auth.JWK{}
+		if keys != nil {
+			jwks = keys.JWKS()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300, stale-while-revalidate=60")
+		json.NewEncoder(w).Encode(map[string]any{"keys": jwks})
 	}
-	json.NewEncoder(w).Encode(map[string]any{"keys": []any{jwk}})
 }